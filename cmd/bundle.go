@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/clindet/bget/urlpool"
+	vers "github.com/clindet/bget/versions"
+	cio "github.com/openbiox/ligo/io"
+	cnet "github.com/openbiox/ligo/net"
+	"github.com/spf13/cobra"
+)
+
+// bundleChannel is the channel name of --add-channel
+var bundleChannel string
+
+// BundleCmd is the cobra command object to run bget bundle
+var BundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Create and install offline bundles for air-gapped machines.",
+	Long:  `Create one tarball of keys, URLs, accessions, conda packages and images with their resolved versions, checksums and meta data, and restore it on a machine without network. More see here https://github.com/clindet/bget.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+// BundleCreateCmd is the cobra command object to run bget bundle create
+var BundleCreateCmd = &cobra.Command{
+	Use:   "create [bundle.tar] [key[@version] | url | accession | conda:pkg | docker://image ...]",
+	Short: "Download items into an offline bundle.",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		bundleCreateCmdRunOptions(cmd, args)
+	},
+}
+
+// BundleInstallCmd is the cobra command object to run bget bundle install
+var BundleInstallCmd = &cobra.Command{
+	Use:   "install [bundle.tar]",
+	Short: "Restore the files and meta data of an offline bundle.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		bundleInstallCmdRunOptions(cmd, args)
+	},
+}
+
+// bundleItems returns the items of args (name=value args are env) and --list-file
+func bundleItems(args []string) (items []string) {
+	for _, v := range args {
+		if !envArgRe.MatchString(v) {
+			items = append(items, v)
+		}
+	}
+	if bgetClis.ListFile != "" {
+		items = append(items, cio.ReadLines(bgetClis.ListFile)...)
+	}
+	return urlpool.JoinCondaConstraints(items)
+}
+
+func bundleCreateCmdRunOptions(cmd *cobra.Command, args []string) {
+	initCmd(cmd, args)
+	checkArgs(cmd, "bundle")
+	setPlatform()
+	items := bundleItems(cmd.Flags().Args()[1:])
+	if len(items) == 0 {
+		cmd.Help()
+		return
+	}
+	bundleFile, err := filepath.Abs(cmd.Flags().Args()[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cio.CreateDir(filepath.Dir(bundleFile)); err != nil {
+		log.Fatal(err)
+	}
+	stageDir, err := ioutil.TempDir(filepath.Dir(bundleFile), ".bget-bundle-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(stageDir)
+	bgetClis.DownloadDir = path.Join(stageDir, "files")
+	if err := cio.CreateDir(bgetClis.DownloadDir); err != nil {
+		log.Fatal(err)
+	}
+	manifest := urlpool.BundleManifest{Format: urlpool.BundleFormat, Created: time.Now().UTC(),
+		OS: bgetClis.Env["osType"], Arch: bgetClis.Env["arch"], AutoPath: bgetClis.AutoPath}
+	groups := make(map[string][]string)
+	for _, item := range items {
+		t := urlpool.BundleItemType(item)
+		groups[t] = append(groups[t], strings.TrimSpace(item))
+	}
+	failed := 0
+	if len(groups["key"]) > 0 {
+		keyItems, resolved, n := bundleKeys(groups["key"])
+		manifest.Items, failed = append(manifest.Items, keyItems...), failed+n
+		if err := writeBundleCatalog(path.Join(stageDir, "meta"), resolved); err != nil {
+			os.RemoveAll(stageDir)
+			log.Fatal(err)
+		}
+	}
+	if len(groups["url"]) > 0 {
+		urls, destDirArray := groups["url"], []string{}
+		for range urls {
+			destDirArray = append(destDirArray, bgetClis.DownloadDir)
+		}
+		done := make(map[string]bool)
+		for _, dest := range cnet.HTTPGetURLs(urls, destDirArray, setNetParams(&bgetClis)) {
+			done[dest] = true
+		}
+		for _, u := range urls {
+			if !done[path.Join(bgetClis.DownloadDir, path.Base(u))] {
+				log.Errorf("Failed to download %s.", u)
+				failed++
+				continue
+			}
+			manifest.Items = append(manifest.Items, urlpool.BundleItem{Input: u, Type: "url", URLs: []string{u}})
+		}
+	}
+	if len(groups["accession"]) > 0 {
+		bgetClis.Seqs = strings.Join(groups["accession"], bgetClis.Seperator)
+		downloadSeq()
+		for _, id := range groups["accession"] {
+			manifest.Items = append(manifest.Items, urlpool.BundleItem{Input: id, Type: "accession", Key: id})
+		}
+	}
+	if len(groups["conda"]) > 0 {
+		verified := downloadCondaKeys(groups["conda"])
+		for _, key := range groups["conda"] {
+			pkg, ok := verified[key]
+			if !ok {
+				failed++
+				continue
+			}
+			manifest.Items = append(manifest.Items, urlpool.BundleItem{Input: key, Type: "conda", Key: pkg.Name,
+				Version: pkg.Version + "-" + pkg.Build, Channel: pkg.Channel, URLs: []string{pkg.URL}})
+		}
+	}
+	if len(groups["image"]) > 0 {
+		pulled := downloadImages(groups["image"])
+		for _, key := range groups["image"] {
+			img, ok := pulled[key]
+			if !ok {
+				failed++
+				continue
+			}
+			manifest.Items = append(manifest.Items, urlpool.BundleItem{Input: key, Type: "image",
+				Key: img.Ref.Registry + "/" + img.Ref.Repository, Version: img.Digest, URLs: []string{img.Ref.String()}})
+		}
+	}
+	if failed > 0 {
+		os.RemoveAll(stageDir)
+		log.Fatalf("%d of %d items failed, %s is not created.", failed, len(items), bundleFile)
+	}
+	if manifest.Files, err = urlpool.BundleFiles(bgetClis.DownloadDir); err != nil {
+		os.RemoveAll(stageDir)
+		log.Fatal(err)
+	}
+	data, _ := urlpool.IndentJSON(manifest)
+	if err := ioutil.WriteFile(path.Join(stageDir, "bundle.json"), data, 0644); err != nil {
+		os.RemoveAll(stageDir)
+		log.Fatal(err)
+	}
+	if err := urlpool.WriteBundle(stageDir, bundleFile); err != nil {
+		os.RemoveAll(stageDir)
+		log.Fatal(err)
+	}
+	log.Infof("Created %s (%d items, %d files).", bundleFile, len(manifest.Items), len(manifest.Files))
+}
+
+// bundleKeys downloads keys (with Requires) without post commands, it
+// returns the items, the resolved versions and the number of failed keys
+func bundleKeys(keys []string) (items []urlpool.BundleItem, resolved map[string]string, failed int) {
+	initLinks()
+	plan := planKeys(keys)
+	urls, _, _, versions := vers.QueryKeysInfo(plan, &bgetClis.Env, &toolLinks, &fileLinks)
+	resolved = make(map[string]string)
+	for i, key := range planNames(plan) {
+		if len(urls[key]) == 0 {
+			log.Errorf("No URLs of key %s.", key)
+			failed++
+			continue
+		}
+		destDirArray := []string{}
+		for _, u := range urls[key] {
+			destDirArray = append(destDirArray, keyDestDir(key, u))
+		}
+		done, _ := fetchKeyURLs(key, urls[key], destDirArray)
+		if len(done) < len(urls[key]) {
+			log.Errorf("Failed to download %s (%d of %d files).", key, len(done), len(urls[key]))
+			failed++
+			continue
+		}
+		resolved[key] = versions[key]
+		items = append(items, urlpool.BundleItem{Input: plan[i], Type: "key", Key: key, Version: versions[key],
+			Channel: keyChannel(key), URLs: urls[key]})
+	}
+	return items, resolved, failed
+}
+
+// writeBundleCatalog writes the meta data of the resolved keys (Versions
+// pinned) as a local channel: default.json, tools.json and files.json
+func writeBundleCatalog(dir string, resolved map[string]string) error {
+	tools, files := urlpool.PinCatalog(resolved, &toolLinks, &fileLinks)
+	if tools == nil {
+		tools = []urlpool.BgetToolsURLType{}
+	}
+	if files == nil {
+		files = []urlpool.BgetFilesURLType{}
+	}
+	if err := cio.CreateDir(dir); err != nil {
+		return err
+	}
+	for fn, v := range map[string]interface{}{
+		"default.json": map[string][]string{"tools": {"tools.json"}, "files": {"files.json"}},
+		"tools.json":   tools,
+		"files.json":   files,
+	} {
+		data, err := urlpool.IndentJSON(v)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path.Join(dir, fn), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bundleInstallCmdRunOptions(cmd *cobra.Command, args []string) {
+	initCmd(cmd, args)
+	destDir, err := filepath.Abs(bgetClis.DownloadDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	m, err := urlpool.ExtractBundle(args[0], destDir, bgetClis.Overwrite)
+	if err != nil {
+		log.Fatal(err)
+	}
+	keys := []string{}
+	for _, item := range m.Items {
+		v := item.Version
+		if v == "" {
+			v = "-"
+		}
+		fmt.Printf("%s> %s (%s)\n", item.Type, item.Input, v)
+		if item.Type == "key" && item.Version != "" {
+			keys = append(keys, item.Key+"@"+item.Version)
+		} else if item.Type == "key" {
+			keys = append(keys, item.Key)
+		}
+	}
+	log.Infof("Installed %s (%d items, %d files, %s/%s) => %s", args[0], len(m.Items), len(m.Files), m.OS, m.Arch, destDir)
+	entry := path.Join(destDir, urlpool.BundleMetaDir, "meta", "default.json")
+	if hasEntry, _ := cio.PathExists(entry); !hasEntry {
+		return
+	}
+	channel := entry
+	if bundleChannel != "" {
+		addChannel(bundleChannel, entry, cmd.Flags().Changed("priority"))
+		channel = bundleChannel
+	}
+	autoPath := ""
+	if m.AutoPath {
+		autoPath = " --autopath"
+	}
+	log.Infof("Run post commands of keys offline: bget i %s -c %s -o %s%s",
+		strings.Join(keys, bgetClis.Seperator), channel, destDir, autoPath)
+}
+
+func init() {
+	BundleCreateCmd.Flags().StringVarP(&entryLink, "channel", "c", "", "Only use this channel (channel name or entry meta file of bget).")
+	BundleCreateCmd.Flags().BoolVarP(&(bgetClis.AllowUnsigned), "allow-unsigned", "", false, "Accept unsigned meta data of channels (signed ones are still verified).")
+	BundleCreateCmd.Flags().BoolVar(&(bgetClis.AutoPath), "autopath", false, "Place the files of keys in <key>/ dirs (bget i --autopath).")
+	BundleCreateCmd.Flags().BoolVarP(&(bgetClis.NoDeps), "no-deps", "", false, "Do not bundle the required keys (Requires) of keys.")
+	BundleCreateCmd.Flags().BoolVarP(&(bgetClis.Prerelease), "pre", "", false, "Include pre-release versions (e.g. rc, beta) of keys.")
+	BundleCreateCmd.Flags().BoolVarP(&(bgetClis.WithAssets), "with-assets", "", false, "Bundle the associated release assets of keys.")
+	BundleCreateCmd.Flags().StringVarP(&(bgetClis.AssetsInclude), "assets-include", "", "", "Only bundle the assets matched these patterns (comma separated globs, or regexes with ~ prefix).")
+	BundleCreateCmd.Flags().StringVarP(&(bgetClis.AssetsExclude), "assets-exclude", "", "", "Skip the assets matched these patterns (comma separated globs, or regexes with ~ prefix).")
+	BundleCreateCmd.Flags().StringVarP(&(bgetClis.ImageFormat), "image-format", "", imageDockerArchive, "Format of docker:// images: docker-archive (docker load) or oci (OCI layout tarball).")
+	BundleCreateCmd.Flags().StringVarP(&(bgetClis.OS), "os", "", "", "Bundle the files of this OS (linux, mac, windows), default is the current OS.")
+	BundleCreateCmd.Flags().StringVarP(&(bgetClis.Arch), "arch", "", "", "Bundle the files of this arch (amd64, arm64, ...), default is the current arch.")
+	setGlobalFlag(BundleCreateCmd, &bgetClis)
+	setKeyListFlag(BundleCreateCmd, &bgetClis, "keys, URLs or accessions")
+	BundleInstallCmd.Flags().StringVarP(&(bgetClis.DownloadDir), "outdir", "o", wd, "Restore the files into this dir.")
+	BundleInstallCmd.Flags().BoolVarP(&(bgetClis.Overwrite), "overwrite", "f", false, "Overwrite existing files with another sha256.")
+	BundleInstallCmd.Flags().StringVarP(&bundleChannel, "add-channel", "", "", "Add the meta data of the bundle as a local channel with this name.")
+	BundleInstallCmd.Flags().IntVarP(&channelPriority, "priority", "p", 0, "Priority of --add-channel (default is after all channels).")
+	BundleCmd.AddCommand(BundleCreateCmd)
+	BundleCmd.AddCommand(BundleInstallCmd)
+	BundleCreateCmd.Example = `  # on a machine with network (keys are resolved with their Requires)
+  bget bundle create tools.tar samtools@1.10 bwa https://ftp.ncbi.nlm.nih.gov/pub/clinvar/README.txt SRR8400200
+  bget bundle create images.tar conda:bioconda/htslib=1.10 docker://quay.io/biocontainers/bwa:0.7.17--h5bf99c6_8
+  # bundle the Linux files on a Mac
+  bget bundle create tools.tar samtools@1.10 --os linux --arch amd64 -g wget`
+	BundleInstallCmd.Example = `  # on the air-gapped machine
+  bget bundle install tools.tar -o /data/bget
+  # use the meta data of the bundle (existing files are not downloaded again)
+  bget i samtools@1.10 -c /data/bget/.bget-bundle/meta/default.json -o /data/bget
+  bget bundle install tools.tar -o /data/bget --add-channel offline
+  bget i samtools@1.10 -c offline -o /data/bget`
+}
@@ -0,0 +1,413 @@
+package cmd
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/clindet/bget/meta"
+	"github.com/olekukonko/tablewriter"
+	cio "github.com/openbiox/ligo/io"
+	cnet "github.com/openbiox/ligo/net"
+	"github.com/spf13/cobra"
+)
+
+// channelT is one meta data channel of bget: URL is the entry file (http or
+// local path), an empty URL is the builtin default channel
+type channelT struct {
+	Name     string
+	URL      string
+	Priority int
+}
+
+const defaultChannel = "default"
+
+var channelPriority int
+var channelPurge bool
+
+// localChannels records the names of loaded channels from local files
+var localChannels = make(map[string]bool)
+
+// channelList returns the configured channels ordered by priority
+func channelList() (channels []channelT) {
+	channels = append(channels, bgetConfig.Channels...)
+	if len(channels) == 0 {
+		channels = []channelT{{Name: defaultChannel}}
+	}
+	sort.SliceStable(channels, func(i, j int) bool {
+		return channels[i].Priority < channels[j].Priority
+	})
+	return channels
+}
+
+func findChannel(name string) int {
+	for i := range bgetConfig.Channels {
+		if bgetConfig.Channels[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// channelNameRe matches the names of channels, they are the cache dirs of
+// channels in ~/.config/bget/meta
+var channelNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+func validChannelName(name string) bool {
+	return channelNameRe.MatchString(name) && name != "." && name != ".."
+}
+
+func channelCacheDir(name string) string {
+	return path.Join(configDir(), "meta", name)
+}
+
+func isLocalChannel(ch channelT) bool {
+	return ch.URL != "" && !strings.Contains(ch.URL, "://")
+}
+
+// cliChannel returns the channel of --channel (a channel name or an entry file)
+func cliChannel(link string) channelT {
+	for _, ch := range channelList() {
+		if ch.Name == link {
+			return ch
+		}
+	}
+	sum := sha1.Sum([]byte(link))
+	return channelT{Name: "c-" + hex.EncodeToString(sum[:4]), URL: link}
+}
+
+func readEntry(fn string) (entry map[string][]string, err error) {
+	jsData, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	entry = make(map[string][]string)
+	if err = json.Unmarshal(jsData, &entry); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	return entry, nil
+}
+
+// loadChannel loads the meta data of ch from its cache (or updates it)
+func loadChannel(ch channelT) {
+	cacheDir := channelCacheDir(ch.Name)
+	var entry map[string][]string
+	var err error
+	if ch.URL == "" {
+		migrateDefaultCache(path.Dir(cacheDir), cacheDir)
+		entry = make(map[string][]string)
+		for k, v := range defaultEntry {
+			entry[k] = append([]string{}, v...)
+		}
+		if hasFile, _ := cio.PathExists(defaultEntry["entry"][0]); hasFile {
+			if tmp, err := readEntry(defaultEntry["entry"][0]); err == nil {
+				entry = tmp
+			} else {
+				log.Warn(err)
+			}
+		}
+	} else if isLocalChannel(ch) {
+		entry, err = readEntry(ch.URL)
+		if err != nil {
+			log.Warnf("Failed to load channel %s: %v", ch.Name, err)
+			return
+		}
+		cacheDir = filepath.Dir(ch.URL)
+		localChannels[ch.Name] = true
+	} else {
+		destFn := path.Join(cacheDir, path.Base(ch.URL))
+		if hasFile, _ := cio.PathExists(destFn); !hasFile || updateCache {
+			fetchChannelEntry(ch, cacheDir)
+		}
+		entry, err = readEntry(destFn)
+		if err != nil {
+			log.Warnf("Failed to load channel %s: %v", ch.Name, err)
+			return
+		}
+	}
+	if len(entry["baseURL"]) == 0 {
+		entry["baseURL"] = []string{""}
+	}
+	entry["baseURL"][0] = cacheDir
+	ok, err := loadLocalCache(&entry, ch.Name)
+	if err != nil && localChannels[ch.Name] {
+		log.Fatalf("Channel %s: %v (check it with 'bget meta lint %s').", ch.Name, err, cacheDir)
+	} else if err != nil {
+		log.Errorf("Channel %s: %v, updating the cache.", ch.Name, err)
+	}
+	if ok && err == nil && (!updateCache || localChannels[ch.Name]) {
+		return
+	}
+	if localChannels[ch.Name] {
+		log.Warnf("No meta data found in local channel %s.", ch.URL)
+		return
+	}
+	loadEntryData(&entry, ch.Name, ch.URL == "")
+}
+
+// migrateDefaultCache copies the meta data of the default channel cached
+// by older versions (~/.config/bget/meta) into its channel dir, the old
+// files are kept
+func migrateDefaultCache(legacyDir string, cacheDir string) {
+	files := []string{}
+	for _, k := range []string{"entry", "tools", "files"} {
+		files = append(files, defaultEntry[k]...)
+	}
+	for _, v := range files {
+		if hasFile, _ := cio.PathExists(path.Join(cacheDir, v)); hasFile {
+			return
+		}
+	}
+	if hasFile, _ := cio.PathExists(path.Join(legacyDir, defaultEntry["entry"][0])); !hasFile {
+		return
+	}
+	log.Infof("Migrating the meta data cache of %s into %s.", legacyDir, cacheDir)
+	for _, v := range append(files, meta.SumsFile, meta.SigFile) {
+		if hasFile, _ := cio.PathExists(path.Join(legacyDir, v)); !hasFile {
+			continue
+		}
+		cio.CreateDir(path.Dir(path.Join(cacheDir, v)))
+		if _, err := cio.CopyFile(path.Join(cacheDir, v), path.Join(legacyDir, v)); err != nil {
+			log.Warn(err)
+		}
+	}
+}
+
+// fetchChannelEntry downloads the entry file of ch with the signature of its
+// dir, the entry (and its baseURL) is only used if it is verified
+func fetchChannelEntry(ch channelT, cacheDir string) {
+	stageDir := cacheDir + ".entry"
+	defer os.RemoveAll(stageDir)
+	os.RemoveAll(stageDir)
+	name := path.Base(ch.URL)
+	if len(cnet.HTTPGetURLs([]string{ch.URL}, []string{stageDir}, netOpt)) == 0 {
+		return
+	}
+	for _, v := range []string{meta.SumsFile, meta.SigFile} {
+		link := strings.Replace(path.Join(path.Dir(ch.URL), v), ":/", "://", 1)
+		if err := fetchChannelFile(link, path.Join(stageDir, v)); err != nil {
+			log.Infof("No %s of channel %s: %v", v, ch.Name, err)
+		}
+	}
+	if !verifyMetaDir(stageDir, []string{name}, false) {
+		log.Errorf("Channel %s: the entry %s is not verified, the cache is kept.", ch.Name, ch.URL)
+		return
+	}
+	cio.CreateDir(cacheDir)
+	if _, err := cio.CopyFile(path.Join(cacheDir, name), path.Join(stageDir, name)); err != nil {
+		log.Warn(err)
+	}
+}
+
+// dropShadowedKeys keeps the keys of the channel with the highest priority,
+// channels is the loaded channels in order of priority
+func dropShadowedKeys(channels []channelT) {
+	order := make(map[string]int)
+	for i, ch := range channels {
+		order[ch.Name] = i
+	}
+	winner := make(map[string]string)
+	setWinner := func(name string, channel string) {
+		key := formatKeyName(name)
+		if v, ok := winner[key]; !ok || order[channel] < order[v] {
+			winner[key] = channel
+		}
+	}
+	for i := range toolLinks {
+		setWinner(toolLinks[i].Name, toolLinks[i].Channel)
+	}
+	for i := range fileLinks {
+		setWinner(fileLinks[i].Name, fileLinks[i].Channel)
+	}
+	tools := toolLinks[:0]
+	for i := range toolLinks {
+		if winner[formatKeyName(toolLinks[i].Name)] == toolLinks[i].Channel {
+			tools = append(tools, toolLinks[i])
+		}
+	}
+	toolLinks = tools
+	files := fileLinks[:0]
+	for i := range fileLinks {
+		if winner[formatKeyName(fileLinks[i].Name)] == fileLinks[i].Channel {
+			files = append(files, fileLinks[i])
+		}
+	}
+	fileLinks = files
+}
+
+// keyChannel returns the channel name of key
+func keyChannel(key string) string {
+	for i := range toolLinks {
+		if formatKeyName(toolLinks[i].Name) == key {
+			return toolLinks[i].Channel
+		}
+	}
+	for i := range fileLinks {
+		if formatKeyName(fileLinks[i].Name) == key {
+			return fileLinks[i].Channel
+		}
+	}
+	return ""
+}
+
+func formatKeyName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// ChannelCmd is the cobra command object to run bget channel
+var ChannelCmd = &cobra.Command{
+	Use:   "channel",
+	Short: "Manage meta data channels of bget i.",
+	Long:  `Manage meta data channels of bget i. Keys are resolved across channels by priority (lower value first). More see here https://github.com/clindet/bget.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+// ChannelAddCmd is the cobra command object to run bget channel add
+var ChannelAddCmd = &cobra.Command{
+	Use:   "add [name] [entry-url-or-file]",
+	Short: "Add a channel.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		initCmd(cmd, args)
+		addChannel(args[0], args[1], cmd.Flags().Changed("priority"))
+	},
+}
+
+// addChannel saves a channel into the config, the priority is after all
+// channels unless --priority is set
+func addChannel(name string, link string, hasPriority bool) {
+	if !validChannelName(name) {
+		log.Fatalf("Invalid channel name %q (use letters, digits, '_', '.' and '-').", name)
+	}
+	seedChannels()
+	if findChannel(name) >= 0 {
+		log.Fatalf("Channel %s existed.", name)
+	}
+	ch := channelT{Name: name, URL: link}
+	if isLocalChannel(ch) {
+		if absPath, err := filepath.Abs(link); err == nil {
+			ch.URL = absPath
+		}
+	}
+	ch.Priority = channelPriority
+	if !hasPriority {
+		for _, v := range bgetConfig.Channels {
+			if v.Priority >= ch.Priority {
+				ch.Priority = v.Priority + 1
+			}
+		}
+	}
+	bgetConfig.Channels = append(bgetConfig.Channels, ch)
+	if err := saveConfig(); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Added channel %s (priority %d).", name, ch.Priority)
+}
+
+// ChannelRemoveCmd is the cobra command object to run bget channel remove
+var ChannelRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a channel.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		initCmd(cmd, args)
+		seedChannels()
+		idx := findChannel(args[0])
+		if idx < 0 {
+			log.Fatalf("Channel %s not found.", args[0])
+		}
+		bgetConfig.Channels = append(bgetConfig.Channels[:idx], bgetConfig.Channels[idx+1:]...)
+		if err := saveConfig(); err != nil {
+			log.Fatal(err)
+		}
+		if channelPurge && !validChannelName(args[0]) {
+			log.Warnf("Skip purging the cache of the invalid channel name %q.", args[0])
+		} else if channelPurge {
+			if err := os.RemoveAll(channelCacheDir(args[0])); err != nil {
+				log.Warn(err)
+			}
+		}
+		log.Infof("Removed channel %s.", args[0])
+	},
+}
+
+// ChannelListCmd is the cobra command object to run bget channel list
+var ChannelListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List channels by priority.",
+	Run: func(cmd *cobra.Command, args []string) {
+		initCmd(cmd, args)
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Name", "Priority", "URL", "Cache"})
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		for _, ch := range channelList() {
+			link, cacheDir := ch.URL, channelCacheDir(ch.Name)
+			if link == "" {
+				link = "(builtin)"
+			}
+			if isLocalChannel(ch) {
+				cacheDir = filepath.Dir(ch.URL)
+			}
+			table.Append([]string{ch.Name, strconv.Itoa(ch.Priority), link, cacheDir})
+		}
+		table.Render()
+	},
+}
+
+// ChannelPriorityCmd is the cobra command object to run bget channel priority
+var ChannelPriorityCmd = &cobra.Command{
+	Use:   "priority [name] [priority]",
+	Short: "Set the priority of a channel (lower value first).",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		initCmd(cmd, args)
+		seedChannels()
+		idx := findChannel(args[0])
+		if idx < 0 {
+			log.Fatalf("Channel %s not found.", args[0])
+		}
+		priority, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		bgetConfig.Channels[idx].Priority = priority
+		if err := saveConfig(); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Set priority of channel %s to %d.", args[0], priority)
+	},
+}
+
+// seedChannels adds the builtin default channel to an empty config
+func seedChannels() {
+	if len(bgetConfig.Channels) == 0 {
+		bgetConfig.Channels = []channelT{{Name: defaultChannel}}
+	}
+}
+
+func init() {
+	ChannelAddCmd.Flags().IntVarP(&channelPriority, "priority", "p", 0, "Priority of the channel (default is after all channels).")
+	ChannelRemoveCmd.Flags().BoolVarP(&channelPurge, "purge", "", false, "Remove the cache dir of the channel.")
+	ChannelCmd.AddCommand(ChannelAddCmd)
+	ChannelCmd.AddCommand(ChannelRemoveCmd)
+	ChannelCmd.AddCommand(ChannelListCmd)
+	ChannelCmd.AddCommand(ChannelPriorityCmd)
+	ChannelCmd.Example = `  bget channel add lab https://lab.example.org/bget/_meta/default.json
+  bget channel add local /share/bget/_meta/default.json -p -1
+  bget channel list
+  bget channel priority lab -1
+  bget channel remove lab --purge
+  bget i --update
+  bget i -a`
+}
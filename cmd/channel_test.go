@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/clindet/bget/urlpool"
+)
+
+func TestValidChannelName(t *testing.T) {
+	for name, want := range map[string]bool{"ok-name": true, "lab_1.0": true, "default": true,
+		"": false, ".": false, "..": false, "../x": false, "a/b": false, "a b": false} {
+		if got := validChannelName(name); got != want {
+			t.Errorf("validChannelName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestDropShadowedKeys(t *testing.T) {
+	oldTools, oldFiles := toolLinks, fileLinks
+	defer func() { toolLinks, fileLinks = oldTools, oldFiles }()
+	toolLinks = []urlpool.BgetToolsURLType{
+		{Name: "samtools", Channel: defaultChannel},
+		{Name: "samtools", Channel: "lab"},
+		{Name: "bwa", Channel: defaultChannel},
+		{Name: "my_tool", Channel: "lab"},
+		{Name: "My-Tool", Channel: "local"},
+	}
+	fileLinks = []urlpool.BgetFilesURLType{
+		{Name: "db/annovar", Channel: defaultChannel},
+		{Name: "db/annovar", Channel: "local"},
+		{Name: "reffa/defuse", Channel: "local"},
+	}
+	// local is before lab and default in order of priority
+	dropShadowedKeys([]channelT{{Name: "local"}, {Name: "lab", Priority: 1}, {Name: defaultChannel, Priority: 2}})
+	got := map[string]string{}
+	for _, v := range toolLinks {
+		got[v.Name] = v.Channel
+	}
+	for _, v := range fileLinks {
+		got[v.Name] = v.Channel
+	}
+	want := map[string]string{"samtools": "lab", "bwa": defaultChannel, "My-Tool": "local",
+		"db/annovar": "local", "reffa/defuse": "local"}
+	if len(toolLinks)+len(fileLinks) != len(want) {
+		t.Errorf("dropShadowedKeys kept %d keys, want %d: %v", len(toolLinks)+len(fileLinks), len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: channel %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestMigrateDefaultCache(t *testing.T) {
+	legacyDir, err := ioutil.TempDir("", "bget-meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(legacyDir)
+	for _, v := range []string{"default.json", "tools/main.json", "files/db.json"} {
+		os.MkdirAll(path.Dir(path.Join(legacyDir, v)), 0755)
+		ioutil.WriteFile(path.Join(legacyDir, v), []byte("[]"), 0644)
+	}
+	cacheDir := path.Join(legacyDir, defaultChannel)
+	migrateDefaultCache(legacyDir, cacheDir)
+	for _, v := range []string{"default.json", "tools/main.json", "files/db.json"} {
+		if _, err := os.Stat(path.Join(cacheDir, v)); err != nil {
+			t.Errorf("%s is not migrated: %v", v, err)
+		}
+		if _, err := os.Stat(path.Join(legacyDir, v)); err != nil {
+			t.Errorf("legacy %s is removed: %v", v, err)
+		}
+	}
+	// an existing cache is not overwritten
+	ioutil.WriteFile(path.Join(legacyDir, "tools/main.json"), []byte("[1]"), 0644)
+	migrateDefaultCache(legacyDir, cacheDir)
+	if data, _ := ioutil.ReadFile(path.Join(cacheDir, "tools/main.json")); string(data) != "[]" {
+		t.Errorf("cache is overwritten: %s", data)
+	}
+}
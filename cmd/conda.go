@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/clindet/bget/urlpool"
+	cio "github.com/openbiox/ligo/io"
+	cnet "github.com/openbiox/ligo/net"
+)
+
+// downloadCondaKeys resolves conda: keys against the repodata.json of the
+// current platform, downloads and verifies the packages, and extracts them
+// into --conda-prefix if set. It returns the verified (and extracted)
+// packages of keys, corrupt packages are removed.
+func downloadCondaKeys(keys []string) (verified map[string]urlpool.CondaPackage) {
+	verified = make(map[string]urlpool.CondaPackage)
+	pkgs, pkgKeys := []urlpool.CondaPackage{}, []string{}
+	urls, destDirArray := []string{}, []string{}
+	for _, key := range keys {
+		spec, err := urlpool.ParseCondaSpec(key)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		pkg, err := urlpool.ResolveCondaPackage(spec, bgetClis.Env["osType"], bgetClis.Env["arch"])
+		if err != nil {
+			log.Errorf("%s: %v", key, err)
+			continue
+		}
+		destDir := bgetClis.DownloadDir
+		if bgetClis.AutoPath {
+			destDir = path.Join(destDir, "conda", pkg.Name)
+		}
+		if bgetClis.DryRun {
+			printCondaPackage(key, pkg, destDir)
+			continue
+		}
+		log.Infof("Resolved %s => %s/%s/%s (depends: %v)", key, pkg.Channel, pkg.Subdir, pkg.FileName, pkg.Depends)
+		pkgs, pkgKeys = append(pkgs, pkg), append(pkgKeys, key)
+		urls = append(urls, pkg.URL)
+		destDirArray = append(destDirArray, destDir)
+	}
+	if len(urls) == 0 {
+		return verified
+	}
+	netOpt := setNetParams(&bgetClis)
+	cnet.HTTPGetURLs(urls, destDirArray, netOpt)
+	for i, pkg := range pkgs {
+		fn := path.Join(destDirArray[i], pkg.FileName)
+		if hasFile, _ := cio.PathExists(fn); !hasFile {
+			log.Errorf("Failed to download %s.", pkg.URL)
+			continue
+		}
+		if err := urlpool.VerifyCondaPackage(fn, pkg); err != nil {
+			log.Errorf("Failed to verify conda package (removed): %v", err)
+			os.Remove(fn)
+			continue
+		}
+		log.Infof("Verified %s (sha256:%s).", fn, pkg.Sha256)
+		if bgetClis.CondaPrefix != "" {
+			files, err := urlpool.ExtractCondaPackage(fn, pkg, bgetClis.CondaPrefix)
+			if err != nil {
+				log.Errorf("Failed to extract %s: %v", fn, err)
+				continue
+			}
+			log.Infof("Extracted %s (%d files) => %s", pkg.FileName, len(files), bgetClis.CondaPrefix)
+		}
+		verified[pkgKeys[i]] = pkg
+	}
+	return verified
+}
+
+func printCondaPackage(key string, pkg urlpool.CondaPackage, destDir string) {
+	fmt.Printf("key> %s\n", key)
+	fmt.Printf("url> %s => %s\n", pkg.URL, path.Join(destDir, pkg.FileName))
+	fmt.Printf("pkg> %s %s %s (sha256:%s, %d bytes)\n", pkg.Name, pkg.Version, pkg.Build, pkg.Sha256, pkg.Size)
+	if len(pkg.Depends) > 0 {
+		fmt.Printf("dep> %s\n", strings.Join(pkg.Depends, ", "))
+	}
+	fmt.Println("-----------")
+}
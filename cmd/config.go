@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	neturl "net/url"
+	"os"
+	"os/user"
+	"path"
+	"time"
+
+	"github.com/clindet/bget/urlpool"
+	cio "github.com/openbiox/ligo/io"
+)
+
+// bgetConfigT is the user config of bget stored in ~/.config/bget/config.json
+type bgetConfigT struct {
+	// PostCmdAllow is the list of keys (path.Match patterns) allowed to run
+	// PostShellCmd from non-local channels
+	PostCmdAllow []string
+	// TrustedKeys is the list of base64 ed25519 public keys used to verify
+	// signed channels
+	TrustedKeys []string
+	// AllowUnsigned accepts unsigned channels (like --allow-unsigned), the
+	// signed ones are still verified
+	AllowUnsigned bool
+	// Channels is the list of meta data channels
+	Channels []channelT
+	// VersionsTTL is the duration (e.g. 6h, 30m) of cached version lists
+	// from APIs, 0 always revalidates them
+	VersionsTTL string `json:",omitempty"`
+	// GitHubEnterprise maps the hosts of GitHub Enterprise to their API base
+	// URLs (empty is https://<host>/api/v3/)
+	GitHubEnterprise map[string]string `json:",omitempty"`
+	// CondaBaseURL is the base URL (or mirror) of conda channels, default
+	// is https://conda.anaconda.org
+	CondaBaseURL string `json:",omitempty"`
+}
+
+var bgetConfig bgetConfigT
+
+func configDir() string {
+	us, err := user.Current()
+	if err != nil {
+		return path.Join(wd, ".bget")
+	}
+	return path.Join(us.HomeDir, ".config", "bget")
+}
+
+func configPath() string {
+	return path.Join(configDir(), "config.json")
+}
+
+func loadConfig() {
+	fn := configPath()
+	if hasFile, _ := cio.PathExists(fn); !hasFile {
+		return
+	}
+	jsData, err := ioutil.ReadFile(fn)
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+	if err := json.Unmarshal(jsData, &bgetConfig); err != nil {
+		log.Warnf("Failed to parse %s: %v", fn, err)
+	}
+}
+
+// setVersionCache sets the cache dir and TTL of version lists (--versions-ttl
+// overrides VersionsTTL of config)
+func setVersionCache() {
+	urlpool.VersionCache.Dir = path.Join(configDir(), "cache", "versions")
+	ttl := bgetClis.VersionsTTL
+	if ttl == "" {
+		ttl = bgetConfig.VersionsTTL
+	}
+	if ttl == "" {
+		return
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil || d < 0 {
+		log.Warnf("Invalid TTL of versions %q (e.g. 6h, 30m), using %s.", ttl, urlpool.VersionCache.TTL)
+		return
+	}
+	urlpool.VersionCache.TTL = d
+}
+
+// setGitHubHosts registers the GitHub Enterprise hosts of config and
+// GITHUB_ENTERPRISE_URL (e.g. https://git.example.com/api/v3/)
+func setGitHubHosts() {
+	for host, apiURL := range bgetConfig.GitHubEnterprise {
+		urlpool.AddGitHubHost(host, apiURL)
+	}
+	if apiURL := os.Getenv("GITHUB_ENTERPRISE_URL"); apiURL != "" {
+		u, err := neturl.Parse(apiURL)
+		if err != nil || u.Host == "" {
+			log.Warnf("Invalid GITHUB_ENTERPRISE_URL %q.", apiURL)
+			return
+		}
+		urlpool.AddGitHubHost(u.Host, apiURL)
+	}
+}
+
+// setCondaBaseURL sets the base URL of conda channels by config
+func setCondaBaseURL() {
+	if bgetConfig.CondaBaseURL != "" {
+		urlpool.CondaBaseURL = bgetConfig.CondaBaseURL
+	}
+}
+
+func saveConfig() error {
+	if err := cio.CreateDir(configDir()); err != nil {
+		return err
+	}
+	jsData, err := json.MarshalIndent(bgetConfig, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(configPath(), jsData, 0644)
+}
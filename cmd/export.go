@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/clindet/bget/urlpool"
+	vers "github.com/clindet/bget/versions"
+	"github.com/spf13/cobra"
+)
+
+var exportFormat string
+var exportQuery string
+var exportTags string
+var exportFile string
+
+// KeyExportCmd is the cobra command object to run bget i export
+var KeyExportCmd = &cobra.Command{
+	Use:   "export [key[@version]...]",
+	Short: "Export keys (meta data and resolved URLs) as a markdown table, CSV, Snakemake or Nextflow config.",
+	Run: func(cmd *cobra.Command, args []string) {
+		keyExportCmdRunOptions(cmd, args)
+	},
+}
+
+func keyExportCmdRunOptions(cmd *cobra.Command, args []string) {
+	initCmd(cmd, args)
+	checkArgs(cmd, "key")
+	setPlatform()
+	if !inStrings(urlpool.ExportFormats, exportFormat) {
+		log.Fatalf("Unknown format %s (use %s).", exportFormat, strings.Join(urlpool.ExportFormats, ", "))
+	}
+	initLinks()
+	keys := parseKeys()
+	tags := []string{}
+	for _, t := range strings.Split(exportTags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	if exportQuery != "" || len(tags) > 0 {
+		for _, v := range urlpool.SearchKeys(exportQuery, tags, toolLinks, fileLinks) {
+			if !inStrings(planNames(keys), v.Key) {
+				keys = append(keys, v.Key)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		log.Fatal("No keys to export (set keys, --query or --tag).")
+	}
+	plan := planKeys(keys)
+	urls, _, _, resolved := vers.QueryKeysInfo(plan, &bgetClis.Env, &toolLinks, &fileLinks)
+	entries := []urlpool.ExportEntry{}
+	for _, key := range planNames(plan) {
+		info := queryKeyInfo(key)
+		if info == nil {
+			log.Warnf("Key %s not found (try 'bget i search %s').", key, key)
+			continue
+		}
+		e := urlpool.ExportEntry{Key: key, Version: resolved[key], Channel: info.Channel,
+			Description: info.Description, Tags: info.Tags}
+		for _, u := range urls[key] {
+			e.URLs = append(e.URLs, u)
+			e.Files = append(e.Files, path.Join(keyDestDir(key, u), path.Base(u)))
+		}
+		if len(e.URLs) == 0 {
+			log.Warnf("No URLs of key %s.", key)
+		}
+		entries = append(entries, e)
+	}
+	var w io.Writer = os.Stdout
+	if exportFile != "" {
+		f, err := os.Create(exportFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := urlpool.Export(w, exportFormat, entries); err != nil {
+		log.Fatal(err)
+	}
+	if exportFile != "" {
+		log.Infof("Exported %d keys to %s.", len(entries), exportFile)
+	}
+	bgetClis.HelpFlags = false
+}
+
+func init() {
+	KeyExportCmd.Flags().StringVarP(&entryLink, "channel", "c", "", "Only use this channel (channel name or entry meta file of bget).")
+	KeyExportCmd.Flags().StringVarP(&exportFormat, "format", "", "markdown", "Export format ("+strings.Join(urlpool.ExportFormats, ", ")+").")
+	KeyExportCmd.Flags().StringVarP(&exportQuery, "query", "q", "", "Also export the keys matched this query (bget i search).")
+	KeyExportCmd.Flags().StringVarP(&exportTags, "tag", "", "", "Also export the keys with these tags (comma separated).")
+	KeyExportCmd.Flags().StringVarP(&exportFile, "export-file", "w", "", "Write the export to this file (default is stdout).")
+	KeyExportCmd.Flags().StringVarP(&(bgetClis.DownloadDir), "outdir", "o", wd, "Download dir of the local paths (files) of URLs.")
+	KeyExportCmd.Flags().BoolVar(&(bgetClis.AutoPath), "autopath", false, "Local paths of URLs are in <key>/ dirs (bget i --autopath).")
+	KeyExportCmd.Flags().StringVarP(&(bgetClis.Seperator), "seperator", "s", ",", "Optional 'key1{seperator}key2' for multiple keys.")
+	KeyExportCmd.Flags().BoolVarP(&(bgetClis.AllowUnsigned), "allow-unsigned", "", false, "Accept unsigned meta data of channels (signed ones are still verified).")
+	KeyExportCmd.Flags().BoolVarP(&(bgetClis.NoDeps), "no-deps", "", false, "Do not export the required keys (Requires) of keys.")
+	KeyExportCmd.Flags().BoolVarP(&(bgetClis.Prerelease), "pre", "", false, "Include pre-release versions (e.g. rc, beta) of keys.")
+	KeyExportCmd.Flags().StringVarP(&(bgetClis.OS), "os", "", "", "Export the URLs of this OS (linux, mac, windows), default is the current OS.")
+	KeyExportCmd.Flags().StringVarP(&(bgetClis.Arch), "arch", "", "", "Export the URLs of this arch (amd64, arm64, ...), default is the current arch.")
+	setKeyListFlag(KeyExportCmd, &bgetClis, "keys")
+	KeyCmd.AddCommand(KeyExportCmd)
+	KeyExportCmd.Example = `  # documentation table of keys (and their Requires)
+  bget i export samtools bwa@0.7.17 > docs/resources.md
+  # keys with tags as CSV
+  bget i export --tag aligner --format csv -w resources.csv
+  # Snakemake config: configfile: "bget.yaml", config["bget"]["reffa_defuse"]["files"]
+  bget i export "reffa/defuse@GRCh38" release=97 --format snakemake -o /data/bget --autopath -w bget.yaml
+  # Nextflow config: includeConfig 'bget.config', params.bget.samtools.files
+  bget i export samtools --query "rna seq" --format nextflow --os linux -w bget.config`
+}
@@ -7,24 +7,24 @@ import (
 	"io/ioutil"
 	"net/url"
 	"os"
-	"os/exec"
 	"os/signal"
-	"os/user"
 	"path"
+	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
+	"github.com/clindet/bget/meta"
 	"github.com/clindet/bget/spider"
 	"github.com/clindet/bget/urlpool"
 	vers "github.com/clindet/bget/versions"
 	"github.com/openbiox/ligo/archive"
-	bexec "github.com/openbiox/ligo/exec"
 	cio "github.com/openbiox/ligo/io"
 	cnet "github.com/openbiox/ligo/net"
-	"github.com/openbiox/ligo/slice"
 	"github.com/spf13/cobra"
 )
 
@@ -35,6 +35,7 @@ var netOpt *cnet.Params
 
 var entryLink string
 var updateCache bool
+var linksLoaded bool
 
 var defaultEntry = map[string][]string{
 	"baseURL": []string{
@@ -68,6 +69,7 @@ var KeyCmd = &cobra.Command{
 func keyCmdRunOptions(cmd *cobra.Command, args []string) {
 	initCmd(cmd, args)
 	checkArgs(cmd, "key")
+	setPlatform()
 	if updateCache || bgetClis.ShowVersions || bgetClis.KeysAll || bgetClis.Keys != "" || bgetClis.ListFile != "" {
 		initLinks()
 	}
@@ -96,6 +98,28 @@ func keyCmdRunOptions(cmd *cobra.Command, args []string) {
 	}
 }
 
+// setPlatform sets the OS and arch of tools URLs by --os and --arch
+func setPlatform() {
+	if bgetClis.OS != "" {
+		if urlpool.NormOS(bgetClis.OS) == "" {
+			log.Fatalf("Unknown OS %s (use linux, mac or windows).", bgetClis.OS)
+		}
+		bgetClis.Env["osType"] = bgetClis.OS
+	}
+	if bgetClis.Arch != "" {
+		if urlpool.NormArch(bgetClis.Arch) == "" {
+			log.Fatalf("Unknown arch %s (use %s).", bgetClis.Arch, strings.Join(urlpool.ArchKeys, ", "))
+		}
+		bgetClis.Env["arch"] = bgetClis.Arch
+	}
+}
+
+// crossPlatform returns true if --os or --arch is not the current platform
+func crossPlatform() bool {
+	return urlpool.NormOS(bgetClis.Env["osType"]) != urlpool.NormOS(runtime.GOOS) ||
+		urlpool.NormArch(bgetClis.Env["arch"]) != urlpool.NormArch(runtime.GOARCH)
+}
+
 func parseKeys() (keys []string) {
 	if bgetClis.Keys != "" && strings.Contains(bgetClis.Keys, bgetClis.Seperator) {
 		keys = strings.Split(bgetClis.Keys, bgetClis.Seperator)
@@ -104,17 +128,82 @@ func parseKeys() (keys []string) {
 	} else if bgetClis.ListFile != "" {
 		keys = cio.ReadLines(bgetClis.ListFile)
 	}
-	return keys
+	return urlpool.JoinCondaConstraints(keys)
+}
+
+// planKeys adds the Requires of keys (unless --no-deps), the required keys
+// are placed first
+func planKeys(keys []string) []string {
+	if bgetClis.NoDeps {
+		return keys
+	}
+	plan, err := urlpool.ResolveRequires(keys, &toolLinks, &fileLinks)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(plan) > len(keys) {
+		log.Infof("Plan (with required keys): %s", strings.Join(plan, ", "))
+	}
+	return plan
+}
+
+// planNames returns the key names of plan in order
+func planNames(plan []string) (names []string) {
+	seen := make(map[string]bool)
+	for _, item := range plan {
+		key, _, _, _ := vers.ParseMeta(item)
+		if !seen[key] {
+			seen[key] = true
+			names = append(names, key)
+		}
+	}
+	return names
 }
 
 func downloadKey() {
-	keys := parseKeys()
 	initLinks()
-	urls, postShellCmd, _ := vers.QueryKeysInfo(keys, &bgetClis.Env, &toolLinks, &fileLinks)
+	keys, condaKeys, imageKeys := []string{}, []string{}, []string{}
+	for _, k := range parseKeys() {
+		if urlpool.IsCondaKey(k) {
+			condaKeys = append(condaKeys, k)
+		} else if urlpool.IsImageRef(k) {
+			imageKeys = append(imageKeys, k)
+		} else {
+			keys = append(keys, k)
+		}
+	}
+	failed := 0
+	defer func() {
+		if failed > 0 {
+			log.Fatalf("%d keys failed.", failed)
+		}
+	}()
+	if len(condaKeys) > 0 {
+		verified := downloadCondaKeys(condaKeys)
+		if !bgetClis.DryRun {
+			failed += len(condaKeys) - len(verified)
+		}
+	}
+	if len(imageKeys) > 0 {
+		pulled := downloadImages(imageKeys)
+		if !bgetClis.DryRun {
+			failed += len(imageKeys) - len(pulled)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	keys = planKeys(keys)
+	urls, postShellCmd, _, _ := vers.QueryKeysInfo(keys, &bgetClis.Env, &toolLinks, &fileLinks)
 	done := make(map[string][]string)
-	var destDirArray []string
+	doneURLs := make(map[string][]string)
+	var mu sync.Mutex
 	sem := make(chan bool, bgetClis.Thread)
 	netOpt = setNetParams(&bgetClis)
+	if bgetClis.DryRun {
+		printDryRun(planNames(keys), urls, postShellCmd)
+		return
+	}
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan,
 		syscall.SIGHUP,
@@ -123,19 +212,12 @@ func downloadKey() {
 		syscall.SIGQUIT)
 
 	for key, v := range urls {
+		destDirArray := []string{}
 		for i := range v {
 			v[i] = preURLFilter(v[i])
 			u, _ := url.Parse(v[i])
 			v[i] = strings.TrimSpace(u.String())
-			assetsDir := ""
-			if strings.Contains(v[i], "github.com") && strings.Contains(v[i], "/releases/download/") {
-				assetsDir = "github-assets"
-			}
-			if bgetClis.AutoPath {
-				destDirArray = append(destDirArray, path.Join(bgetClis.DownloadDir, key, assetsDir))
-			} else {
-				destDirArray = append(destDirArray, path.Join(bgetClis.DownloadDir, assetsDir))
-			}
+			destDirArray = append(destDirArray, keyDestDir(key, v[i]))
 		}
 		sem <- true
 		go func(key string, v []string, destDirArray []string, signalChan chan os.Signal) {
@@ -150,17 +232,26 @@ func downloadKey() {
 					return
 				}
 			}()
-			done[key] = cnet.HTTPGetURLs(v, destDirArray, netOpt)
+			fns, fetched := fetchKeyURLs(key, v, destDirArray)
+			mu.Lock()
+			done[key], doneURLs[key] = fns, fetched
+			if len(fns) < len(v) {
+				failed++
+			}
+			mu.Unlock()
 		}(key, v, destDirArray, signalChan)
 	}
 	for i := 0; i < cap(sem); i++ {
 		sem <- true
 	}
 	dest := ""
-	for key := range done {
+	for _, key := range planNames(keys) {
 		for i := range done[key] {
 			args := ""
 			dest = done[key][i]
+			if absDest, err := filepath.Abs(dest); err == nil {
+				dest = absDest
+			}
 			if len(postShellCmd[key]) > i {
 				args = postShellCmd[key][i]
 				args = postCmdRender(args, dest)
@@ -174,22 +265,102 @@ func downloadKey() {
 			if args == "" {
 				continue
 			}
-			cmd := exec.Command("sh", "-c", args)
-			logPath := ""
-			if bgetClis.SaveLog {
-				logPath = path.Join(bgetClis.LogDir, bgetClis.TaskID+"_postShellCmd_"+strings.ReplaceAll(key, "/", "_")+".log")
-			}
-			if args != "" {
-				err := bexec.System(cmd, logPath, bgetClis.Verbose == 0)
-				if err != nil {
-					log.Warn(err)
-				}
+			if crossPlatform() {
+				log.Warnf("Skip post command of %s for another platform: %s", key, args)
+				continue
 			}
+			runPostCmd(key, doneURLs[key][i], args, dest)
 		}
 		urlpool.PostKeyCmds(key, done[key], bgetClis.Keys)
 	}
 }
 
+// fetchKeyURLs downloads the URLs of key in one batch of --thread, the URLs
+// failed to download or verify are tried again in the next batch, up to
+// --retries+1 attempts, and each attempt is logged with key, url and attempt;
+// fetched are the URLs of done in the order of urls
+func fetchKeyURLs(key string, urls []string, destDirs []string) (done []string, fetched []string) {
+	dests := make([]string, len(urls))
+	for i, u := range urls {
+		dests[i] = path.Join(destDirs[i], cnet.FormatURLfileName(u, bgetClis.RemoteName, bgetClis.Timeout, bgetClis.Proxy))
+	}
+	ok := make([]bool, len(urls))
+	pending := make([]int, len(urls))
+	for i := range urls {
+		pending[i] = i
+	}
+	for attempt := 1; len(pending) > 0 && attempt <= bgetClis.Retries+1; attempt++ {
+		batchURLs, batchDirs := []string{}, []string{}
+		for _, i := range pending {
+			batchURLs = append(batchURLs, urls[i])
+			batchDirs = append(batchDirs, destDirs[i])
+		}
+		// the progress bars of opt are done after a batch
+		opt := setNetParams(&bgetClis)
+		opt.Retries, opt.RetSleepTime = singleAttempt(opt.Engine), 0
+		got := make(map[string]bool)
+		for _, fn := range cnet.HTTPGetURLs(batchURLs, batchDirs, opt) {
+			got[fn] = true
+		}
+		failed := []int{}
+		for _, i := range pending {
+			entry := taskLog(key, urls[i]).WithField("attempt", attempt)
+			// the engines may report a failed URL without a file as done, a GitHub
+			// release asset failed to verify is removed and downloaded again
+			hasDest, _ := cio.PathExists(dests[i])
+			if got[dests[i]] && hasDest && verifyAssets(urls[i:i+1], destDirs[i:i+1]) == 0 {
+				entry.Infof("Downloaded %s.", dests[i])
+				ok[i] = true
+				continue
+			}
+			// a partial file without resume state would be taken as existed
+			if hasState, _ := cio.PathExists(dests[i] + ".st"); !hasState && dests[i] != path.Clean(destDirs[i]) {
+				os.Remove(dests[i])
+			}
+			if attempt <= bgetClis.Retries {
+				entry.Warnf("Failed to download, retrying after %d seconds.", bgetClis.RetSleepTime)
+			} else {
+				entry.Errorf("Failed to download after %d attempts.", attempt)
+			}
+			failed = append(failed, i)
+		}
+		pending = failed
+		if len(pending) > 0 && attempt <= bgetClis.Retries {
+			time.Sleep(time.Duration(bgetClis.RetSleepTime) * time.Second)
+		}
+	}
+	for i := range urls {
+		if ok[i] {
+			done = append(done, dests[i])
+			fetched = append(fetched, urls[i])
+		}
+	}
+	return done, fetched
+}
+
+// singleAttempt returns the Retries of engine for one try of a URL: the
+// built-in engines take it as the number of tries, the external tools as
+// the number of retries
+func singleAttempt(engine string) int {
+	if engine == "default" || engine == "simplego" {
+		return 1
+	}
+	return 0
+}
+
+// keyDestDir returns the download dir of a URL of key: <key>/ with
+// --autopath, GitHub release assets are placed in github-assets/
+func keyDestDir(key string, u string) string {
+	assetsDir := ""
+	if strings.Contains(u, "github.com") && strings.Contains(u, "/releases/download/") {
+		assetsDir = "github-assets"
+	}
+	if bgetClis.AutoPath {
+		return path.Join(bgetClis.DownloadDir, key, assetsDir)
+	}
+	return path.Join(bgetClis.DownloadDir, assetsDir)
+}
+
 func preURLFilter(url string) string {
 	if strings.Contains(url, "doaj.org") {
 		url, err := spider.RetriveRedirectLink(url, bgetClis.Timeout, bgetClis.Proxy)
@@ -201,184 +372,227 @@ func preURLFilter(url string) string {
 	return url
 }
 
-func postCmdRender(oldCmd string, dest string) (newCmd string) {
-	if hasDest, _ := cio.PathExists(dest); !hasDest {
-		return ""
-	}
-	if bgetClis.CmdExtraFromFlag != "" {
-		newCmd = oldCmd + " " + bgetClis.CmdExtraFromFlag
-	}
-	// define your pattern replace
-	newCmd = strings.Replace(oldCmd, "{{downloadDir}}", bgetClis.DownloadDir, 100)
-	newCmd = strings.Replace(newCmd, "{{dest}}", dest, 100)
-	newCmd = strings.Replace(newCmd, "{{pdir}}", path.Dir(dest), 100)
-	return newCmd
+// printDryRun shows URLs and rendered post shell commands without downloading
+func printDryRun(keys []string, urls map[string][]string, postShellCmd map[string][]string) {
+	for _, key := range keys {
+		if len(urls[key]) == 0 {
+			continue
+		}
+		fmt.Printf("key> %s\n", key)
+		for i, u := range urls[key] {
+			destDir := bgetClis.DownloadDir
+			if bgetClis.AutoPath {
+				destDir = path.Join(destDir, key)
+			}
+			dest := path.Join(destDir, path.Base(u))
+			fmt.Printf("url> %s => %s\n", u, dest)
+			if len(postShellCmd[key]) > i && postShellCmd[key][i] != "" {
+				fmt.Printf("cmd> %s\n", postCmdRender(postShellCmd[key][i], dest))
+			}
+		}
+		fmt.Println("-----------")
+	}
 }
 
 func getAllKeys() (keys []string) {
+	channels := make(map[string]string)
 	for i := range toolLinks {
-		keys = append(keys, toolLinks[i].Name)
+		key := formatKeyName(toolLinks[i].Name)
+		if _, ok := channels[key]; !ok {
+			channels[key] = toolLinks[i].Channel
+			keys = append(keys, key)
+		}
 	}
 	for i := range fileLinks {
-		keys = append(keys, fileLinks[i].Name)
+		key := formatKeyName(fileLinks[i].Name)
+		if _, ok := channels[key]; !ok {
+			channels[key] = fileLinks[i].Channel
+			keys = append(keys, key)
+		}
 	}
-	keys = slice.DropSliceDup(keys)
 	sort.Strings(keys)
 	if bgetClis.PrintFormat == "" || bgetClis.PrintFormat == "table" {
 		table := tablewriter.NewWriter(os.Stdout)
 		table.SetRowLine(false)
-		table.SetRowSeparator("")
 		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetHeader([]string{"Key", "Channel", "Key", "Channel"})
 
 		tmp := []string{}
 		for i := range keys {
-			if i%2 == 0 {
+			if i%2 == 0 && len(tmp) > 0 {
 				table.Append(tmp)
 				tmp = []string{}
 			}
-			keys[i] = strings.ToLower(keys[i])
-			keys[i] = strings.ReplaceAll(keys[i], "_", "-")
-			tmp = append(tmp, keys[i])
+			tmp = append(tmp, keys[i], channels[keys[i]])
 		}
 		if len(tmp) != 0 {
 			table.Append(tmp)
 		}
 		table.Render()
 	} else if bgetClis.PrintFormat == "text" {
-		fmt.Println(strings.Join(keys, "\n"))
+		for _, key := range keys {
+			fmt.Printf("%s\t%s\n", key, channels[key])
+		}
 	} else if bgetClis.PrintFormat == "json" {
 		var str bytes.Buffer
-		js := fmt.Sprintf(`{"keys":[ "%s" ]}`, strings.Join(keys, `", "`))
-		_ = json.Indent(&str, []byte(js), "", "  ")
+		jsData, _ := json.Marshal(map[string]interface{}{
+			"keys":     keys,
+			"channels": channels,
+		})
+		_ = json.Indent(&str, jsData, "", "  ")
 		fmt.Println(str.String())
 	}
 
-	//fmt.Printf("%s\n", strings.Join(keys, "\n"))
 	return keys
 }
 
 func initLinks() {
+	if linksLoaded {
+		return
+	}
+	linksLoaded = true
 	netOpt = setNetParams(&bgetClis)
 	netOpt.Overwrite = true
 	netOpt.Thread = 10
-	us, _ := user.Current()
-	if entryLink == "" {
-		v := defaultEntry["entry"][0]
-		if hasFile, _ := cio.PathExists(v); hasFile {
-			fcon, _ := cio.Open(v)
-			jsData, _ := ioutil.ReadAll(fcon)
-			json.Unmarshal(jsData, &defaultEntry)
-		}
-	}
-	defaultEntry["baseURL"][0] = strings.ReplaceAll(defaultEntry["baseURL"][0],
-		"{{HOME}}", us.HomeDir)
-	if entryLink == "" {
-		if loadLocalCache(&defaultEntry) && !updateCache {
-			return
-		}
-		loadEntryData(&defaultEntry)
-	} else if strings.Contains(entryLink, "http") {
-		cnet.HTTPGetURLs([]string{entryLink}, []string{defaultEntry["baseURL"][0]}, netOpt)
-		destFn := path.Join(defaultEntry["baseURL"][0], path.Base(entryLink))
-		if hasFile, _ := cio.PathExists(destFn); hasFile {
-			fcon, _ := cio.Open(destFn)
-			jsData, _ := ioutil.ReadAll(fcon)
-			tmp := make(map[string][]string)
-			json.Unmarshal(jsData, &tmp)
-			tmp["baseURL"][0] = strings.ReplaceAll(tmp["baseURL"][0],
-				"{{HOME}}", us.HomeDir)
-			if loadLocalCache(&tmp) && !updateCache {
-				return
-			}
-			loadEntryData(&tmp)
-		}
-	} else if hasFile, _ := cio.PathExists(entryLink); hasFile {
-		fcon, _ := cio.Open(entryLink)
-		jsData, _ := ioutil.ReadAll(fcon)
-		tmp := make(map[string][]string)
-		json.Unmarshal(jsData, &tmp)
-		tmp["baseURL"][0] = strings.ReplaceAll(tmp["baseURL"][0],
-			"{{HOME}}", us.HomeDir)
-		if loadLocalCache(&tmp) && !updateCache {
-			return
-		}
-		loadEntryData(&tmp)
+	if entryLink != "" {
+		loadChannel(cliChannel(entryLink))
+		return
 	}
+	channels := channelList()
+	for _, ch := range channels {
+		loadChannel(ch)
+	}
+	dropShadowedKeys(channels)
 }
 
-func loadEntryData(entry *map[string][]string) {
+// loadEntryData updates the cache of a channel from the baseURL of entry,
+// builtin is true for the builtin default channel
+func loadEntryData(entry *map[string][]string, channel string, builtin bool) {
 	fmt.Println((*entry)["baseURL"][1:len((*entry)["baseURL"])])
+	cacheDir := (*entry)["baseURL"][0]
+	stageDir := cacheDir + ".staging"
 	for _, v := range (*entry)["baseURL"][1:len((*entry)["baseURL"])] {
 		links := []string{}
 		var destDir []string
+		var files []string
 
-		for _, v2 := range (*entry)["entry"] {
-			links = append(links, strings.Replace(path.Join(v, v2), ":/", "://", 1))
-			destDir = append(destDir, path.Join((*entry)["baseURL"][0], path.Dir(v2)))
-		}
-
-		for _, v2 := range (*entry)["tools"] {
-			links = append(links, strings.Replace(path.Join(v, v2), ":/", "://", 1))
-			destDir = append(destDir, path.Join((*entry)["baseURL"][0], path.Dir(v2)))
-		}
-		for _, v2 := range (*entry)["files"] {
-			links = append(links, strings.Replace(path.Join(v, v2), ":/", "://", 1))
-			destDir = append(destDir, path.Join((*entry)["baseURL"][0], path.Dir(v2)))
+		for _, k := range []string{"entry", "tools", "files"} {
+			for _, v2 := range (*entry)[k] {
+				links = append(links, strings.Replace(path.Join(v, v2), ":/", "://", 1))
+				destDir = append(destDir, path.Join(stageDir, path.Dir(v2)))
+				files = append(files, v2)
+			}
 		}
 		log.Infof("Updating bget meta data from %s.", v)
+		os.RemoveAll(stageDir)
 		netOpt = setNetParams(&bgetClis)
 		netOpt.Overwrite = true
 		netOpt.Thread = 10
 		destFns := cnet.HTTPGetURLs(links, destDir, netOpt)
-		if len(destFns) > 0 && loadLocalCache(entry) {
+		if len(destFns) == 0 {
+			continue
+		}
+		for _, v2 := range []string{meta.SumsFile, meta.SigFile} {
+			link := strings.Replace(path.Join(v, v2), ":/", "://", 1)
+			if err := fetchChannelFile(link, path.Join(stageDir, v2)); err != nil {
+				log.Infof("No %s in %s: %v", v2, v, err)
+			}
+		}
+		if !verifyMetaDir(stageDir, files, builtin) {
+			continue
+		}
+		for _, v2 := range append(files, meta.SumsFile, meta.SigFile) {
+			if hasFile, _ := cio.PathExists(path.Join(stageDir, v2)); !hasFile {
+				continue
+			}
+			cio.CreateDir(path.Dir(path.Join(cacheDir, v2)))
+			if _, err := cio.CopyFile(path.Join(cacheDir, v2), path.Join(stageDir, v2)); err != nil {
+				log.Warn(err)
+			}
+		}
+		os.RemoveAll(stageDir)
+		ok, err := loadLocalCache(entry, channel)
+		if err != nil {
+			log.Errorf("Channel %s: %v (check it with 'bget meta lint %s').", channel, err, cacheDir)
+		}
+		if ok {
 			break
 		}
 	}
+	os.RemoveAll(stageDir)
 }
 
-func loadLocalCache(entry *map[string][]string) bool {
-	toolsJSON := []string{}
-	filesJSON := []string{}
-
-	for _, v := range (*entry)["tools"] {
-		toolsJSON = append(toolsJSON, path.Join((*entry)["baseURL"][0], v))
-	}
+// loadLocalCache loads the tools and files meta data of entry, an error is
+// returned if any of the meta files can not be parsed
+func loadLocalCache(entry *map[string][]string, channel string) (bool, error) {
+	files := []urlpool.BgetFilesURLType{}
+	tools := []urlpool.BgetToolsURLType{}
 	for _, v := range (*entry)["files"] {
-		filesJSON = append(filesJSON, path.Join((*entry)["baseURL"][0], v))
-	}
-	for _, v := range filesJSON {
-		if hasFile, _ := cio.PathExists(v); hasFile {
-			fcon, _ := cio.Open(v)
-			jsData, _ := ioutil.ReadAll(fcon)
-			tmp := []urlpool.BgetFilesURLType{}
-			json.Unmarshal(jsData, &tmp)
-			fileLinks = append(fileLinks, tmp...)
+		fn := path.Join((*entry)["baseURL"][0], v)
+		if hasFile, _ := cio.PathExists(fn); !hasFile {
+			continue
 		}
-	}
-	for _, v := range toolsJSON {
-		if hasFile, _ := cio.PathExists(v); hasFile {
-			fcon, _ := cio.Open(v)
-			jsData, _ := ioutil.ReadAll(fcon)
-			tmp := []urlpool.BgetToolsURLType{}
-			json.Unmarshal(jsData, &tmp)
-			toolLinks = append(toolLinks, tmp...)
+		jsData, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return false, err
+		}
+		tmp := []urlpool.BgetFilesURLType{}
+		if err := json.Unmarshal(jsData, &tmp); err != nil {
+			return false, fmt.Errorf("failed to load %s: %v", fn, err)
+		}
+		for i := range tmp {
+			tmp[i].Channel = channel
 		}
+		files = append(files, tmp...)
 	}
-	if len(toolLinks) > 0 && len(fileLinks) > 0 {
-		return true
+	for _, v := range (*entry)["tools"] {
+		fn := path.Join((*entry)["baseURL"][0], v)
+		if hasFile, _ := cio.PathExists(fn); !hasFile {
+			continue
+		}
+		jsData, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return false, err
+		}
+		tmp := []urlpool.BgetToolsURLType{}
+		if err := json.Unmarshal(jsData, &tmp); err != nil {
+			return false, fmt.Errorf("failed to load %s: %v", fn, err)
+		}
+		for i := range tmp {
+			tmp[i].Channel = channel
+		}
+		tools = append(tools, tmp...)
 	}
-	return false
+	fileLinks = append(fileLinks, files...)
+	toolLinks = append(toolLinks, tools...)
+	return len(files)+len(tools) > 0, nil
 }
 
 func init() {
 	KeyCmd.Flags().BoolVarP(&updateCache, "update", "", false, "Logical indicating that whether to update local cache of meta files.")
-	KeyCmd.Flags().StringVarP(&entryLink, "channel", "c", "", "Entry meta file of bget.")
+	KeyCmd.Flags().StringVarP(&entryLink, "channel", "c", "", "Only use this channel (channel name or entry meta file of bget).")
 
 	KeyCmd.Flags().BoolVar(&(bgetClis.AutoPath), "autopath", false, "Logical indicating that whether to create subdir in download dir: e.g. reffa/{{key}}/")
 	KeyCmd.Flags().BoolVarP(&(bgetClis.ShowVersions), "show-versions", "v", false, "Show all available versions of key.")
-	KeyCmd.Flags().StringVarP(&(bgetClis.PrintFormat), "format", "", "", "Output format (text, json, table)")
+	KeyCmd.Flags().StringVarP(&(bgetClis.PrintFormat), "format", "", "", "Output format (text, json, table), json-resolved adds the resolved versions to json of -v")
 	KeyCmd.Flags().BoolVarP(&(bgetClis.KeysAll), "keys-all", "a", false, "Show all available string key can be download.")
+	KeyCmd.Flags().BoolVarP(&(bgetClis.AllowUnsigned), "allow-unsigned", "", false, "Accept unsigned meta data of channels (signed ones are still verified).")
+	KeyCmd.Flags().BoolVarP(&(bgetClis.DryRun), "dry-run", "", false, "Only show the URLs and post commands of keys.")
+	KeyCmd.Flags().StringVarP(&(bgetClis.CondaPrefix), "conda-prefix", "", "", "Extract the conda: packages into this prefix (e.g. ~/.bget/conda).")
+	KeyCmd.Flags().StringVarP(&(bgetClis.ImageFormat), "image-format", "", imageDockerArchive, "Format of docker:// images: docker-archive (docker load) or oci (OCI layout tarball).")
+	KeyCmd.Flags().BoolVarP(&(bgetClis.NoDeps), "no-deps", "", false, "Do not download the required keys (Requires) of keys.")
+	KeyCmd.Flags().StringVarP(&(bgetClis.PostCmd), "post-cmd", "", postCmdAuto, "Run PostShellCmd of keys: auto (only local channel or allowed keys), ask, yes, no.")
+	KeyCmd.Flags().StringVarP(&(bgetClis.PostCmdAllow), "post-cmd-allow", "", "", "Keys (or patterns, e.g. reffa/*) allowed to run PostShellCmd from remote channels.")
+	KeyCmd.Flags().IntVarP(&(bgetClis.PostCmdTimeout), "post-cmd-timeout", "", 0, "Timeout (seconds) of per post command (0 is no limit).")
+	KeyCmd.Flags().StringVarP(&(bgetClis.OS), "os", "", "", "Get tools of this OS (linux, mac, windows), default is the current OS.")
+	KeyCmd.Flags().StringVarP(&(bgetClis.Arch), "arch", "", "", "Get tools of this arch (amd64, arm64, ...), default is the current arch.")
+	KeyCmd.Flags().BoolVarP(&(bgetClis.Prerelease), "pre", "", false, "Include pre-release versions (e.g. rc, beta) of keys.")
+	KeyCmd.Flags().BoolVarP(&(bgetClis.WithBranches), "branches", "", false, "Include branches in versions of keys.")
+	KeyCmd.Flags().StringVarP(&(bgetClis.VersionsTTL), "versions-ttl", "", "", "TTL of cached versions from APIs (e.g. 6h, 0 to revalidate), default is VersionsTTL of config or 6h.")
 	KeyCmd.Flags().BoolVarP(&(bgetClis.WithAssets), "with-assets", "", false, "Logical indicating that whether to download associated assets files.")
+	KeyCmd.Flags().StringVarP(&(bgetClis.AssetsInclude), "assets-include", "", "", "Only download the assets matched these patterns (comma separated globs, or regexes with ~ prefix).")
+	KeyCmd.Flags().StringVarP(&(bgetClis.AssetsExclude), "assets-exclude", "", "", "Skip the assets matched these patterns (comma separated globs, or regexes with ~ prefix).")
+	KeyCmd.Flags().BoolVarP(&(bgetClis.AllAssets), "all-assets", "", false, "Download the assets of all platforms (default is the assets of --os and --arch).")
 	setGlobalFlag(KeyCmd, &bgetClis)
 	setUncompressFlag(KeyCmd, &bgetClis)
 	setKeyListFlag(KeyCmd, &bgetClis, "keys")
@@ -390,17 +604,51 @@ func init() {
   bget i -a
   # in JSON format
   bget i -a --format json
+  # search keys and show the meta data of a key
+  bget i search samtools --tag bam
+  bget i info reffa/defuse
+  # export keys as a markdown table, CSV, Snakemake or Nextflow config
+  bget i export samtools bwa --format snakemake -w bget.yaml
   # view all bwa and samtools available tags in table
   bget i bwa samtools -v
+  # resolve version constraints (>=, <, ~, ^, latest)
+  bget i "bwa@>=0.7.17" "samtools@~1.9" -v
+  bget i "samtools@latest" --pre
+  # revalidate cached versions of APIs (default TTL is 6h)
+  bget i bwa -v --versions-ttl 0
   # view all bwa and samtools available tags in json
   bget i bwa samtools -v --format json
+  # with the resolved versions of constraints: {"versions": {...}, "resolved": {...}}
+  bget i "samtools@~1.9" -v --format json-resolved
 	
   # force download defuse reference (with task env info and save log to file)
   bget i "reffa/defuse@GRCh38 #97" -t 10 -f --verbose 2 --save-log
   bget i reffa/defuse@GRCh38 release=97 -t 10 -f
+  # show URLs and post commands only (required keys are listed first)
+  bget i bwa --dry-run
+  # skip the required keys (Requires) of samtools
+  bget i samtools --no-deps
+  # conda packages (repodata.json of the current platform, sha256 verified) without conda
+  bget i conda:bioconda/samtools=1.10 "conda:conda-forge/zlib>=1.2.11" --dry-run
+  bget i conda:bioconda/samtools=1.10=h2e538c0_3 conda:bioconda/htslib=1.10 --conda-prefix ~/.bget/conda
+  # container images (docker-archive for docker load, or --image-format oci) and ORAS artifacts (e.g. SIF)
+  bget i docker://quay.io/biocontainers/bwa:0.7.17--h5bf99c6_8 --dry-run
+  bget i docker://quay.io/biocontainers/samtools:1.10--h2e538c0_3 --image-format oci -o /data/images
+  bget i oras://ghcr.io/org/bwa-sif:0.7.17
+  # download the release assets of the current platform (or --assets-include "*.jar", --all-assets)
+  bget i github/macarthur-lab/clinvar --with-assets --assets-exclude "*.md" --dry-run
+  # pre-fetch tools for another platform (e.g. building an arm64 image)
+  bget i samtools --os linux --arch arm64 --post-cmd no
+  # run post commands of remote channel keys
+  bget i bwa --post-cmd ask
+  bget i bwa samtools --post-cmd-allow "bwa,samtools" --post-cmd-timeout 600
   # download annovar reference
   bget i db/annovar@clinvar_20170501 db/annovar@clinvar_20180603 builder=hg38
 
+  # select versions by glob, regex (~) or newest N
+  bget i "db/annovar@clinvar_*" builder=hg38
+  bget i db/annovar version='~^avsnp' builder=hg19
+  bget i "db/annovar@newest:3:clinvar_*" builder=hg38
   bget i db/annovar -v --formt text
   bget i db/annovar version='clinvar_20131105, clinvar_20140211, clinvar_20140303, clinvar_20140702, clinvar_20140902, clinvar_20140929, clinvar_20150330, clinvar_20150629, clinvar_20151201, clinvar_20160302, clinvar_20161128, clinvar_20170130, clinvar_20170501, clinvar_20170905, clinvar_20180603, avsnp150, avsnp147, avsnp144, avsnp142, avsnp138, cadd, caddgt10, caddgt20, cadd13, cadd13gt10, cadd13gt20, cg69, cg46, cosmic70, cosmic68wgs, cosmic68, cosmic67wgs, cosmic67, cosmic65, cosmic64, dbnsfp35a, dbnsfp33a, dbnsfp31a_interpro, dbnsfp30a, dbscsnv11, eigen, esp6500siv2_ea, esp6500siv2_aa, esp6500siv2_all, exac03nontcga, exac03nonpsych, exac03, fathmm, gerp++gt2, gme, gnomad_exome, gnomad_genome, gwava, hrcr1, icgc21, intervar_20170202, kaviar_20150923, ljb26_all, mcap, mitimpact2, mitimpact24, nci60, popfreq_max_20150413, popfreq_all_20150413, revel, regsnpintron' builder=hg19 -t 10 -f`
 }
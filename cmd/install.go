@@ -0,0 +1,469 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clindet/bget/urlpool"
+	vers "github.com/clindet/bget/versions"
+	"github.com/olekukonko/tablewriter"
+	"github.com/openbiox/ligo/archive"
+	cio "github.com/openbiox/ligo/io"
+	"github.com/spf13/cobra"
+)
+
+// installRecord is one installed version of a key saved in <prefix>/installed.json
+type installRecord struct {
+	Key     string
+	Version string
+	Dir     string
+	Bins    []string
+	URLs    []string
+	Channel string `json:",omitempty"`
+	// Active is true if the bin/ shims point to this version
+	Active bool
+	Time   time.Time
+}
+
+// noVersion is the version dir of keys without versions
+const noVersion = "default"
+
+var installPrefix string
+
+func defaultPrefix() string {
+	us, err := user.Current()
+	if err != nil {
+		return path.Join(wd, ".bget", "opt")
+	}
+	return path.Join(us.HomeDir, ".bget", "opt")
+}
+
+// setInstallPrefix makes --prefix absolute, the shims and records use it
+func setInstallPrefix() {
+	prefix, err := filepath.Abs(installPrefix)
+	if err != nil {
+		log.Fatal(err)
+	}
+	installPrefix = prefix
+}
+
+func installedPath() string {
+	return path.Join(installPrefix, "installed.json")
+}
+
+func readInstalled() (records []*installRecord) {
+	data, err := ioutil.ReadFile(installedPath())
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		log.Fatalf("Failed to parse %s: %v", installedPath(), err)
+	}
+	return records
+}
+
+func saveInstalled(records []*installRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Key != records[j].Key {
+			return records[i].Key < records[j].Key
+		}
+		return urlpool.CompareVersions(records[i].Version, records[j].Version) > 0
+	})
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := cio.CreateDir(installPrefix); err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(installedPath(), data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func findInstalled(records []*installRecord, key string, version string) *installRecord {
+	for _, r := range records {
+		if r.Key == key && r.Version == version {
+			return r
+		}
+	}
+	return nil
+}
+
+// InstallCmd is the cobra command object to run bget install
+var InstallCmd = &cobra.Command{
+	Use:   "install [key1[@version] key2...]",
+	Short: "Install tools into a prefix with bin/ shims.",
+	Long:  `Download, unpack and build tools into <prefix>/pkgs/<key>/<version>, and link their executables into <prefix>/bin. Versions are installed side by side, the last installed (or reinstalled) version is active. More see here https://github.com/clindet/bget.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		installCmdRunOptions(cmd, args)
+	},
+}
+
+// UninstallCmd is the cobra command object to run bget uninstall
+var UninstallCmd = &cobra.Command{
+	Use:   "uninstall [key1[@version] key2...]",
+	Short: "Remove installed tools (all versions if no @version).",
+	Long:  `Remove installed versions of tools and their bin/ shims, the newest remaining version becomes active. More see here https://github.com/clindet/bget.`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		uninstallCmdRunOptions(cmd, args)
+	},
+}
+
+// ListInstalledCmd is the cobra command object to run bget list-installed
+var ListInstalledCmd = &cobra.Command{
+	Use:   "list-installed",
+	Short: "List the installed tools.",
+	Long:  `List the installed versions of tools in the prefix. More see here https://github.com/clindet/bget.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listInstalledCmdRunOptions(cmd, args)
+	},
+}
+
+func installCmdRunOptions(cmd *cobra.Command, args []string) {
+	initCmd(cmd, args)
+	checkArgs(cmd, "key")
+	setPlatform()
+	setInstallPrefix()
+	if bgetClis.Keys == "" && bgetClis.ListFile == "" {
+		cmd.Help()
+		return
+	}
+	if crossPlatform() {
+		log.Fatalf("Can not install tools of %s/%s on %s/%s (use bget i to download them).",
+			bgetClis.Env["osType"], bgetClis.Env["arch"], runtime.GOOS, runtime.GOARCH)
+	}
+	initLinks()
+	plan := planKeys(parseKeys())
+	urls, _, _, resolved := vers.QueryKeysInfo(plan, &bgetClis.Env, &toolLinks, &fileLinks)
+	records := readInstalled()
+	// build steps can use the installed required tools
+	userPath := os.Getenv("PATH")
+	os.Setenv("PATH", path.Join(installPrefix, "bin")+string(os.PathListSeparator)+userPath)
+	for _, key := range planNames(plan) {
+		if len(urls[key]) == 0 {
+			continue
+		}
+		version := resolved[key]
+		if version == "" {
+			version = noVersion
+		}
+		dir := path.Join(installPrefix, "pkgs", key, version)
+		if bgetClis.DryRun {
+			fmt.Printf("key> %s@%s => %s\n", key, version, dir)
+			for _, u := range urls[key] {
+				fmt.Printf("url> %s\n", u)
+			}
+			for _, c := range keyInstall(key).Build {
+				fmt.Printf("cmd> %s\n", c)
+			}
+			continue
+		}
+		rec := findInstalled(records, key, version)
+		if hasDir, _ := cio.PathExists(dir); rec != nil && hasDir && !bgetClis.Overwrite {
+			log.Infof("%s@%s is already installed in %s.", key, version, dir)
+		} else {
+			bins, err := installKey(key, version, urls[key], dir)
+			if err != nil {
+				log.Errorf("Failed to install %s@%s: %v", key, version, err)
+				continue
+			}
+			if rec == nil {
+				rec = &installRecord{Key: key, Version: version}
+				records = append(records, rec)
+			}
+			rec.Dir, rec.Bins, rec.URLs, rec.Channel, rec.Time = dir, bins, urls[key], keyChannel(key), time.Now()
+			log.Infof("Installed %s@%s => %s", key, version, dir)
+		}
+		activateInstalled(records, rec)
+	}
+	if bgetClis.DryRun {
+		return
+	}
+	saveInstalled(records)
+	binDir := path.Join(installPrefix, "bin")
+	if !strings.Contains(userPath, binDir) {
+		log.Infof("Add %s to PATH to use the installed tools: export PATH=%s:$PATH", binDir, binDir)
+	}
+}
+
+// keyInstall returns the Install of a tools key
+func keyInstall(key string) urlpool.InstallType {
+	for _, t := range toolLinks {
+		if formatKeyName(t.Name) == key && t.Install != nil {
+			return *t.Install
+		}
+	}
+	return urlpool.InstallType{}
+}
+
+// installKey downloads the URLs of key, unpacks them into dir and runs the
+// build steps, it returns the executables of dir
+func installKey(key string, version string, urls []string, dir string) (bins []string, err error) {
+	cacheDir := path.Join(installPrefix, "cache", key, version)
+	destDirArray := []string{}
+	for range urls {
+		destDirArray = append(destDirArray, cacheDir)
+	}
+	done, _ := fetchKeyURLs(key, urls, destDirArray)
+	if len(done) < len(urls) {
+		return nil, fmt.Errorf("%d of %d files downloaded", len(done), len(urls))
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := cio.CreateDir(dir); err != nil {
+		return nil, err
+	}
+	bins, err = unpackInstall(key, version, done, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := os.RemoveAll(cacheDir); err != nil {
+		log.Warn(err)
+	}
+	return bins, nil
+}
+
+func unpackInstall(key string, version string, done []string, dir string) (bins []string, err error) {
+	for _, fn := range done {
+		if urlpool.IsArchive(fn) {
+			if err := archive.UnarchiveLog(fn, dir); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// single executable, e.g. vcfanno_linux64
+		if err := cio.CreateDir(path.Join(dir, "bin")); err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path.Join(dir, "bin", path.Base(fn)), data, 0755); err != nil {
+			return nil, err
+		}
+	}
+	install := keyInstall(key)
+	env := make(map[string]string)
+	for k, v := range bgetClis.Env {
+		env[k] = v
+	}
+	env["prefix"], env["src"], env["version"] = dir, urlpool.SourceDir(dir), version
+	for _, c := range install.Build {
+		cmdStr := urlpool.RenderShellCmd(c, &env)
+		if !buildStepAllowed(key, cmdStr) {
+			return nil, fmt.Errorf("build step is not allowed (--post-cmd %s): %s", bgetClis.PostCmd, cmdStr)
+		}
+		log.Infof("Building %s@%s: %s", key, version, cmdStr)
+		if err := runShellCmd(cmdStr, env["src"]); err != nil {
+			return nil, fmt.Errorf("build step failed: %s: %v", cmdStr, err)
+		}
+	}
+	bins, err = urlpool.FindBins(dir, env["src"], install.Bin)
+	if err != nil {
+		return nil, err
+	}
+	if len(bins) == 0 {
+		log.Warnf("No executables of %s@%s found in %s (declare Install.Bin of the key).", key, version, dir)
+	}
+	return bins, nil
+}
+
+// buildStepAllowed reports whether an Install.Build step of key may run,
+// bget install is the consent to the declared build steps of the planned keys
+// (the keys and their Requires) unless --post-cmd is ask or no
+func buildStepAllowed(key string, cmdStr string) bool {
+	if bgetClis.PostCmd == postCmdAuto {
+		return true
+	}
+	return postCmdAllowed(key, "", cmdStr)
+}
+
+// activateInstalled points the bin/ shims to rec and deactivates the other
+// versions of rec.Key
+func activateInstalled(records []*installRecord, rec *installRecord) {
+	for _, r := range records {
+		if r.Key == rec.Key && r != rec && r.Active {
+			removeShims(r)
+			r.Active = false
+		}
+	}
+	binDir := path.Join(installPrefix, "bin")
+	if err := cio.CreateDir(binDir); err != nil {
+		log.Warn(err)
+		return
+	}
+	for _, b := range rec.Bins {
+		shim := path.Join(binDir, path.Base(b))
+		for _, r := range records {
+			if r.Key != rec.Key && r.Active && shimOf(r, shim) {
+				log.Warnf("%s of %s@%s is replaced by %s@%s.", shim, r.Key, r.Version, rec.Key, rec.Version)
+			}
+		}
+		if err := ioutil.WriteFile(shim, []byte(urlpool.ShimScript(b)), 0755); err != nil {
+			log.Warn(err)
+		}
+	}
+	rec.Active = true
+}
+
+// shimOf returns true if shim runs an executable of r
+func shimOf(r *installRecord, shim string) bool {
+	data, err := ioutil.ReadFile(shim)
+	if err != nil {
+		return false
+	}
+	for _, b := range r.Bins {
+		if path.Base(b) == path.Base(shim) && strings.Contains(string(data), urlpool.ShellQuote(b)) {
+			return true
+		}
+	}
+	return false
+}
+
+func removeShims(r *installRecord) {
+	for _, b := range r.Bins {
+		shim := path.Join(installPrefix, "bin", path.Base(b))
+		if shimOf(r, shim) {
+			if err := os.Remove(shim); err != nil {
+				log.Warn(err)
+			}
+		}
+	}
+}
+
+func uninstallCmdRunOptions(cmd *cobra.Command, args []string) {
+	initCmd(cmd, args)
+	setInstallPrefix()
+	records := readInstalled()
+	for _, arg := range args {
+		key, version, _, _ := vers.ParseMeta(arg)
+		key = formatKeyName(key)
+		found := false
+		kept := []*installRecord{}
+		for _, r := range records {
+			if r.Key != key || (version != "" && r.Version != version) {
+				kept = append(kept, r)
+				continue
+			}
+			found = true
+			if r.Active {
+				removeShims(r)
+			}
+			if err := os.RemoveAll(r.Dir); err != nil {
+				log.Warn(err)
+			}
+			log.Infof("Uninstalled %s@%s (%s).", r.Key, r.Version, r.Dir)
+		}
+		if !found {
+			log.Warnf("%s is not installed in %s.", arg, installPrefix)
+			continue
+		}
+		records = kept
+		var newest *installRecord
+		for _, r := range records {
+			if r.Key != key {
+				continue
+			}
+			if r.Active {
+				newest = nil
+				break
+			}
+			if newest == nil || urlpool.CompareVersions(r.Version, newest.Version) > 0 {
+				newest = r
+			}
+		}
+		if newest != nil {
+			activateInstalled(records, newest)
+			log.Infof("%s@%s is active.", newest.Key, newest.Version)
+		}
+		os.Remove(path.Join(installPrefix, "pkgs", key))
+	}
+	saveInstalled(records)
+}
+
+func listInstalledCmdRunOptions(cmd *cobra.Command, args []string) {
+	records := readInstalled()
+	if bgetClis.PrintFormat == "json" {
+		var str bytes.Buffer
+		data, _ := json.Marshal(records)
+		json.Indent(&str, data, "", "  ")
+		fmt.Println(str.String())
+		return
+	}
+	binNames := func(r *installRecord) string {
+		names := []string{}
+		for _, b := range r.Bins {
+			names = append(names, path.Base(b))
+		}
+		return strings.Join(names, ",")
+	}
+	if bgetClis.PrintFormat == "text" {
+		for _, r := range records {
+			fmt.Printf("%s\t%s\t%v\t%s\t%s\n", r.Key, r.Version, r.Active, binNames(r), r.Dir)
+		}
+		return
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Key", "Version", "Active", "Bins", "Dir", "Installed"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for _, r := range records {
+		active := ""
+		if r.Active {
+			active = "*"
+		}
+		table.Append([]string{r.Key, r.Version, active, binNames(r), r.Dir, r.Time.Format("2006-01-02 15:04:05")})
+	}
+	table.Render()
+}
+
+func init() {
+	for _, c := range []*cobra.Command{InstallCmd, UninstallCmd, ListInstalledCmd} {
+		c.Flags().StringVarP(&installPrefix, "prefix", "", defaultPrefix(), "Install prefix of tools (bin/ shims, pkgs/ and installed.json).")
+	}
+	InstallCmd.Flags().StringVarP(&entryLink, "channel", "c", "", "Only use this channel (channel name or entry meta file of bget).")
+	InstallCmd.Flags().BoolVarP(&(bgetClis.AllowUnsigned), "allow-unsigned", "", false, "Accept unsigned meta data of channels (signed ones are still verified).")
+	InstallCmd.Flags().BoolVarP(&(bgetClis.DryRun), "dry-run", "", false, "Only show the URLs, install dirs and build steps of keys.")
+	InstallCmd.Flags().BoolVarP(&(bgetClis.NoDeps), "no-deps", "", false, "Do not install the required keys (Requires) of keys.")
+	InstallCmd.Flags().StringVarP(&(bgetClis.PostCmd), "post-cmd", "", postCmdAuto, "Run build steps of keys: auto (the declared steps of the installed keys and their Requires), ask, yes, no.")
+	InstallCmd.Flags().IntVarP(&(bgetClis.PostCmdTimeout), "post-cmd-timeout", "", 0, "Timeout (seconds) of per build step (0 is no limit).")
+	InstallCmd.Flags().BoolVarP(&(bgetClis.Prerelease), "pre", "", false, "Include pre-release versions (e.g. rc, beta) of keys.")
+	InstallCmd.Flags().BoolVarP(&(bgetClis.WithAssets), "with-assets", "", false, "Install the associated release assets of keys.")
+	InstallCmd.Flags().StringVarP(&(bgetClis.AssetsInclude), "assets-include", "", "", "Only install the assets matched these patterns (comma separated globs, or regexes with ~ prefix).")
+	InstallCmd.Flags().StringVarP(&(bgetClis.AssetsExclude), "assets-exclude", "", "", "Skip the assets matched these patterns (comma separated globs, or regexes with ~ prefix).")
+	setGlobalFlag(InstallCmd, &bgetClis)
+	setKeyListFlag(InstallCmd, &bgetClis, "keys")
+	ListInstalledCmd.Flags().StringVarP(&(bgetClis.PrintFormat), "format", "", "", "Output format (text, json, table)")
+	InstallCmd.Example = `  bget install vcfanno
+  bget install "samtools@1.10" --prefix ~/.bget/opt
+  export PATH=~/.bget/opt/bin:$PATH
+  # install another version side by side (the last installed is active)
+  bget install samtools@1.9
+  # switch back to 1.10 (already installed)
+  bget install samtools@1.10
+  # reinstall
+  bget install samtools@1.10 -f
+  # show the URLs and build steps only (required keys are installed first)
+  bget install samtools --dry-run
+  bget install samtools --no-deps
+  # confirm each build step
+  bget install samtools --post-cmd ask`
+	UninstallCmd.Example = `  bget uninstall samtools@1.9
+  bget uninstall samtools vcfanno`
+	ListInstalledCmd.Example = `  bget list-installed
+  bget list-installed --format json --prefix /opt/bget`
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clindet/bget/urlpool"
+)
+
+// tarGz returns a tar.gz of files (name => content), all files are executable
+func tarGz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestInstallKey(t *testing.T) {
+	data := tarGz(t, map[string]string{"tool-1.0/tool.sh": "#!/bin/sh\necho tool 1.0\n"})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+	prefix, err := ioutil.TempDir("", "bget-prefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(prefix)
+
+	oldClis, oldPrefix, oldTools := bgetClis, installPrefix, toolLinks
+	defer func() { bgetClis, installPrefix, toolLinks = oldClis, oldPrefix, oldTools }()
+	bgetClis.Engine, bgetClis.Retries, bgetClis.Thread, bgetClis.Timeout = "simplego", 0, 1, 10
+	bgetClis.PostCmd, bgetClis.Env = postCmdAuto, map[string]string{}
+	installPrefix = prefix
+	// a key of a remote channel
+	toolLinks = []urlpool.BgetToolsURLType{{
+		Name:    "tool",
+		Channel: defaultChannel,
+		Install: &urlpool.InstallType{
+			Build: []string{"mkdir -p {{prefix}}/bin && cp tool.sh {{prefix}}/bin/tool"},
+			Bin:   []string{"bin/tool"},
+		},
+	}}
+
+	dir := path.Join(prefix, "pkgs", "tool", "1.0")
+	bins, err := installKey("tool", "1.0", []string{srv.URL + "/tool-1.0.tar.gz"}, dir)
+	if want := []string{path.Join(dir, "bin", "tool")}; err != nil || !reflect.DeepEqual(bins, want) {
+		t.Fatalf("installKey = %v, %v, want %v", bins, err, want)
+	}
+	rec := &installRecord{Key: "tool", Version: "1.0", Dir: dir, Bins: bins}
+	activateInstalled([]*installRecord{rec}, rec)
+	shim, err := ioutil.ReadFile(path.Join(prefix, "bin", "tool"))
+	if err != nil || !rec.Active || !strings.Contains(string(shim), urlpool.ShellQuote(bins[0])) {
+		t.Errorf("shim of tool: %s, %v", shim, err)
+	}
+	if _, err := os.Stat(path.Join(prefix, "cache", "tool", "1.0")); err == nil {
+		t.Error("the download cache should be removed")
+	}
+
+	// the build steps are refused by --post-cmd no
+	bgetClis.PostCmd = postCmdNo
+	_, err = installKey("tool", "1.0", []string{srv.URL + "/tool-1.0.tar.gz"}, dir)
+	if err == nil || !strings.Contains(err.Error(), "--post-cmd no") {
+		t.Errorf("expected an error of --post-cmd no, got %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("the install dir should be removed after a failed build")
+	}
+}
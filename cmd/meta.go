@@ -0,0 +1,450 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/clindet/bget/meta"
+	"github.com/clindet/bget/urlpool"
+	"github.com/olekukonko/tablewriter"
+	cio "github.com/openbiox/ligo/io"
+	cnet "github.com/openbiox/ligo/net"
+	"github.com/spf13/cobra"
+)
+
+var metaKeyFile string
+var metaTrust bool
+var metaFormat string
+var metaCheckKeys string
+var metaCheckThread int
+var metaCheckBaseline string
+var metaCheckOut string
+var metaCheckOnlyBroken bool
+var metaAddFile string
+var metaAddName string
+var metaAddType string
+
+// MetaCmd is the cobra command object to run bget meta
+var MetaCmd = &cobra.Command{
+	Use:   "meta",
+	Short: "Maintain bget meta data (channels).",
+	Long:  `Maintain bget meta data (channels), e.g. sign and verify the meta files. More see here https://github.com/clindet/bget.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+// MetaKeygenCmd is the cobra command object to run bget meta keygen
+var MetaKeygenCmd = &cobra.Command{
+	Use:   "keygen [name]",
+	Short: "Generate an ed25519 key pair to sign a channel.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		initCmd(cmd, args)
+		name := "bget"
+		if len(args) == 1 {
+			name = args[0]
+		}
+		pub, priv, err := meta.GenerateKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fn := path.Join(configDir(), "keys", name+".key")
+		if hasFile, _ := cio.PathExists(fn); hasFile {
+			log.Fatalf("%s existed.", fn)
+		}
+		cio.CreateDir(path.Dir(fn))
+		if err := ioutil.WriteFile(fn, []byte(priv+"\n"), 0600); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Private key saved to %s.", fn)
+		if metaTrust {
+			trustKey(pub)
+		}
+		fmt.Println(pub)
+	},
+}
+
+// MetaSignCmd is the cobra command object to run bget meta sign
+var MetaSignCmd = &cobra.Command{
+	Use:   "sign [meta-dir]",
+	Short: "Sign all JSON files of a channel dir.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		initCmd(cmd, args)
+		if metaKeyFile == "" {
+			metaKeyFile = path.Join(configDir(), "keys", "bget.key")
+		}
+		priv, err := ioutil.ReadFile(metaKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		files, err := meta.SignDir(args[0], string(priv))
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Signed %d files: %s", len(files), path.Join(args[0], meta.SigFile))
+	},
+}
+
+// MetaTrustCmd is the cobra command object to run bget meta trust
+var MetaTrustCmd = &cobra.Command{
+	Use:   "trust [public-key]",
+	Short: "Add a public key to the trusted keys of channels.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		initCmd(cmd, args)
+		trustKey(args[0])
+	},
+}
+
+// MetaLintCmd is the cobra command object to run bget meta lint
+var MetaLintCmd = &cobra.Command{
+	Use:   "lint [meta-dir...]",
+	Short: "Check meta files of channels (OS keys, template variables, duplicate names, URLs and VersionsAPI).",
+	Long:  `Check meta files of channels (OS keys, template variables, duplicate names, URLs and VersionsAPI). The JSON Schema of tools and files meta data is in _meta/schema. More see here https://github.com/clindet/bget.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		initCmd(cmd, args)
+		if len(args) == 0 {
+			args = lintDefaultDirs()
+		}
+		issues := []meta.Issue{}
+		for _, dir := range args {
+			if hasDir, _ := cio.PathExists(dir); hasDir && path.Ext(dir) == ".json" {
+				dir = path.Dir(dir)
+			}
+			tmp, err := meta.LintDir(dir)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for i := range tmp {
+				tmp[i].File = path.Join(dir, tmp[i].File)
+			}
+			issues = append(issues, tmp...)
+		}
+		printLintIssues(issues)
+	},
+}
+
+// MetaCheckCmd is the cobra command object to run bget meta check
+var MetaCheckCmd = &cobra.Command{
+	Use:   "check [meta-dir...]",
+	Short: "Check the links of meta data (broken links, redirects and size changes).",
+	Long:  `Check the links of meta data for each declared version with HEAD (or ranged GET) requests. Exit with non-zero status if broken links are found, so it can be run as a scheduled job. More see here https://github.com/clindet/bget.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		initCmd(cmd, args)
+		if len(args) == 0 {
+			args = lintDefaultDirs()
+		}
+		keys := []string{}
+		if metaCheckKeys != "" {
+			for _, k := range strings.Split(metaCheckKeys, ",") {
+				keys = append(keys, formatKeyName(strings.TrimSpace(k)))
+			}
+		}
+		links := []meta.Link{}
+		for _, dir := range args {
+			if hasDir, _ := cio.PathExists(dir); hasDir && path.Ext(dir) == ".json" {
+				dir = path.Dir(dir)
+			}
+			tools, files, err := meta.LoadDir(dir)
+			if err != nil {
+				log.Fatal(err)
+			}
+			links = append(links, meta.ExpandLinks(tools, files, keys)...)
+		}
+		opt := &meta.CheckOpt{Thread: metaCheckThread, Timeout: bgetClis.Timeout, Proxy: bgetClis.Proxy}
+		if metaCheckBaseline != "" {
+			data, err := ioutil.ReadFile(metaCheckBaseline)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := json.Unmarshal(data, &opt.Baseline); err != nil {
+				log.Fatalf("%s: %v", metaCheckBaseline, err)
+			}
+		}
+		log.Infof("Checking %d links.", len(links))
+		results := meta.CheckLinks(links, opt)
+		if metaCheckOut != "" {
+			jsData, _ := json.MarshalIndent(results, "", "  ")
+			if err := ioutil.WriteFile(metaCheckOut, jsData, 0644); err != nil {
+				log.Fatal(err)
+			}
+		}
+		printLinkResults(results)
+	},
+}
+
+func printLinkResults(results []meta.LinkResult) {
+	nBroken := 0
+	shown := []meta.LinkResult{}
+	for _, v := range results {
+		if v.State == meta.LinkBroken {
+			nBroken++
+		}
+		if !metaCheckOnlyBroken || v.State == meta.LinkBroken {
+			shown = append(shown, v)
+		}
+	}
+	if metaFormat == "json" {
+		var str bytes.Buffer
+		jsData, _ := json.Marshal(shown)
+		json.Indent(&str, jsData, "", "  ")
+		fmt.Println(str.String())
+	} else if len(shown) > 0 {
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Key", "Version", "State", "Status", "Size", "URL", "Message"})
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAutoWrapText(false)
+		for _, v := range shown {
+			msg := v.Error
+			if v.State == meta.LinkRedirect {
+				msg = "-> " + v.Location
+			} else if v.State == meta.LinkSizeChanged {
+				msg = fmt.Sprintf("size %d -> %d", v.PrevSize, v.Size)
+			}
+			table.Append([]string{v.Key, v.Version, v.State, strconv.Itoa(v.Status), strconv.FormatInt(v.Size, 10), v.URL, msg})
+		}
+		table.Render()
+	}
+	if nBroken > 0 {
+		log.Fatalf("%d broken links found (%s).", nBroken, meta.Summary(results))
+	}
+	log.Infof("No broken links found (%s).", meta.Summary(results))
+}
+
+func lintDefaultDirs() (dirs []string) {
+	if hasDir, _ := cio.PathExists("_meta"); hasDir {
+		return []string{"_meta"}
+	}
+	for _, ch := range channelList() {
+		if isLocalChannel(ch) {
+			dirs = append(dirs, path.Dir(ch.URL))
+		} else {
+			dirs = append(dirs, channelCacheDir(ch.Name))
+		}
+	}
+	return dirs
+}
+
+// MetaAddCmd is the cobra command object to run bget meta add
+var MetaAddCmd = &cobra.Command{
+	Use:   "add [repo-url]",
+	Short: "Add a GitHub repo to a meta file (description, topics and release assets).",
+	Long:  `Generate an entry of a GitHub repo from its description, topics and the assets of the latest release, with the repo as VersionsAPI. The entry (--type tools: URL templates of assets, files: git clone) is checked and inserted into the meta file in sorted order of Names (the entries of the file are expected to be sorted). More see here https://github.com/clindet/bget.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		initCmd(cmd, args)
+		metaAdd(args[0])
+	},
+}
+
+func metaAdd(repoURL string) {
+	if metaAddType != "tools" && metaAddType != "files" {
+		log.Fatalf("Unknown --type %s (use tools or files).", metaAddType)
+	}
+	if !urlpool.IsGitHubURL(repoURL) {
+		log.Fatalf("%s is not a GitHub repo (only GitHub and GitHub Enterprise hosts are supported).", repoURL)
+	}
+	info, err := urlpool.GitHubRepoInfo(repoURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	name := metaAddName
+	if name == "" {
+		name = meta.ScaffoldName(info, metaAddFile)
+	}
+	if info.Tag == "" {
+		log.Warnf("%s has no releases.", info.URL)
+	} else {
+		log.Infof("Latest release of %s: %s (%d assets)", info.URL, info.Tag, len(info.Assets))
+	}
+	data, err := ioutil.ReadFile(metaAddFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var entry interface{}
+	isTools := metaAddType == "tools"
+	if isTools {
+		entry = meta.ScaffoldTool(info, name)
+		for _, a := range info.Assets {
+			if _, ok := meta.AssetTemplate(info.URL, info.Tag, a.Name); !ok {
+				log.Warnf("%s names the version without the v of %s, it is not templated.", a.Name, info.Tag)
+			}
+		}
+	} else {
+		entry = meta.ScaffoldFile(info, name)
+	}
+	data, err = meta.InsertEntry(data, name, entry)
+	if err != nil {
+		log.Fatalf("%s: %v", metaAddFile, err)
+	}
+	var issues []meta.Issue
+	if isTools {
+		issues = meta.LintTools(metaAddFile, data, make(map[string]string))
+	} else {
+		issues = meta.LintFiles(metaAddFile, data, make(map[string]string))
+	}
+	nErr := 0
+	for _, v := range issues {
+		// issues of the file (e.g. a tools entry in a files meta file) count too
+		if v.Key != name && v.Key != "" {
+			continue
+		}
+		if v.Level == meta.LevelError {
+			nErr++
+			log.Errorf("%s: %s", name, v.Message)
+		} else {
+			log.Warnf("%s: %s", name, v.Message)
+		}
+	}
+	js, _ := json.MarshalIndent(entry, "", "  ")
+	fmt.Println(string(js))
+	if nErr > 0 {
+		log.Fatalf("The entry of %s has %d errors, %s is not changed.", name, nErr, metaAddFile)
+	}
+	if bgetClis.DryRun {
+		return
+	}
+	if err := ioutil.WriteFile(metaAddFile, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Added %s to %s.", name, metaAddFile)
+}
+
+func printLintIssues(issues []meta.Issue) {
+	nErr := 0
+	for _, v := range issues {
+		if v.Level == meta.LevelError {
+			nErr++
+		}
+	}
+	if metaFormat == "json" {
+		var str bytes.Buffer
+		jsData, _ := json.Marshal(issues)
+		json.Indent(&str, jsData, "", "  ")
+		fmt.Println(str.String())
+	} else if len(issues) > 0 {
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"File", "Key", "Level", "Message"})
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAutoWrapText(false)
+		for _, v := range issues {
+			table.Append([]string{v.File, v.Key, v.Level, v.Message})
+		}
+		table.Render()
+	}
+	if nErr > 0 {
+		log.Fatalf("%d errors and %d warnings found.", nErr, len(issues)-nErr)
+	}
+	log.Infof("%d warnings found.", len(issues))
+}
+
+func trustKey(pub string) {
+	pub = strings.TrimSpace(pub)
+	for _, v := range bgetConfig.TrustedKeys {
+		if v == pub {
+			return
+		}
+	}
+	bgetConfig.TrustedKeys = append(bgetConfig.TrustedKeys, pub)
+	if err := saveConfig(); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Added trusted key to %s.", configPath())
+}
+
+// verifyMetaDir checks the downloaded meta files against trusted keys,
+// unsigned meta data are only accepted with --allow-unsigned (or
+// AllowUnsigned of config). The builtin default channel is not signed yet,
+// it is accepted with a warning unless it is signed by an untrusted key of
+// configured TrustedKeys.
+func verifyMetaDir(dir string, files []string, builtin bool) bool {
+	err := meta.VerifyDir(dir, files, bgetConfig.TrustedKeys)
+	switch {
+	case err == nil:
+		log.Infof("Verified signature of meta data.")
+		return true
+	case err == meta.ErrUnsigned && (bgetConfig.AllowUnsigned || bgetClis.AllowUnsigned):
+		log.Warnf("Accept unsigned meta data (--allow-unsigned).")
+		return true
+	case builtin && (err == meta.ErrUnsigned || (err == meta.ErrUntrusted && len(bgetConfig.TrustedKeys) == 0)):
+		log.Warnf("Accept meta data of the builtin channel: %v.", err)
+		return true
+	case err == meta.ErrUnsigned:
+		log.Errorf("Refuse meta data: %v (use --allow-unsigned to accept unsigned channels).", err)
+	case err == meta.ErrUntrusted && len(bgetConfig.TrustedKeys) == 0:
+		log.Errorf("Refuse meta data: %v (no TrustedKeys in %s, trust the key of the channel by 'bget meta trust').", err, configPath())
+	default:
+		log.Errorf("Refuse meta data: %v.", err)
+	}
+	return false
+}
+
+// fetchChannelFile downloads a small channel file (e.g. signature) to dest
+func fetchChannelFile(url string, dest string) error {
+	client := cnet.NewHTTPClient(bgetClis.Timeout, bgetClis.Proxy)
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	cio.CreateDir(path.Dir(dest))
+	return ioutil.WriteFile(dest, data, 0644)
+}
+
+func init() {
+	MetaKeygenCmd.Flags().BoolVarP(&metaTrust, "trust", "", false, "Add the generated public key to the trusted keys.")
+	MetaSignCmd.Flags().StringVarP(&metaKeyFile, "key", "", "", "Private key file (default ~/.config/bget/keys/bget.key).")
+	MetaCmd.AddCommand(MetaKeygenCmd)
+	MetaCmd.AddCommand(MetaSignCmd)
+	MetaCmd.AddCommand(MetaTrustCmd)
+	MetaLintCmd.Flags().StringVarP(&metaFormat, "format", "", "table", "Output format (table, json)")
+	MetaCmd.AddCommand(MetaLintCmd)
+	MetaCheckCmd.Flags().StringVarP(&metaFormat, "format", "", "table", "Output format (table, json)")
+	MetaCheckCmd.Flags().StringVarP(&metaCheckKeys, "keys", "", "", "Only check these keys (comma separated).")
+	MetaCheckCmd.Flags().IntVarP(&metaCheckThread, "thread", "t", 10, "Concurrency request thread.")
+	MetaCheckCmd.Flags().IntVarP(&bgetClis.Timeout, "timeout", "", 35, "Set the timeout of per request.")
+	MetaCheckCmd.Flags().StringVarP(&(bgetClis.Proxy), "proxy", "", "", "HTTP proxy to request.")
+	MetaCheckCmd.Flags().StringVarP(&metaCheckBaseline, "baseline", "", "", "A previous JSON report to detect size changes.")
+	MetaCheckCmd.Flags().StringVarP(&metaCheckOut, "report", "o", "", "Save all results to a JSON report.")
+	MetaCheckCmd.Flags().BoolVarP(&metaCheckOnlyBroken, "only-broken", "", false, "Only print broken links.")
+	MetaCmd.AddCommand(MetaCheckCmd)
+	MetaAddCmd.Flags().StringVarP(&metaAddFile, "file", "", path.Join("_meta", "files", "github.json"), "Meta file to add the entry (tools/*.json or files/*.json).")
+	MetaAddCmd.Flags().StringVarP(&metaAddType, "type", "", "files", "Entry type: tools (URL templates of release assets) or files (git clone), it must match --file.")
+	MetaAddCmd.Flags().StringVarP(&metaAddName, "name", "", "", "Name of the key (default is the repo name in lower case, github/<repo> in github.json).")
+	MetaAddCmd.Flags().BoolVarP(&(bgetClis.DryRun), "dry-run", "", false, "Only print the entry.")
+	MetaCmd.AddCommand(MetaAddCmd)
+	MetaCmd.Example = `  # generate a key and trust it
+  bget meta keygen lab --trust
+  # sign the channel dir before publishing
+  bget meta sign _meta --key ~/.config/bget/keys/lab.key
+  # trust a channel public key
+  bget meta trust 6fJ0...=
+  bget i --update
+  # unsigned channels (except the builtin one) are refused unless accepted explicitly
+  bget i --update --allow-unsigned
+  # check meta files before publishing
+  bget meta lint _meta
+  bget meta lint _meta --format json
+  # check links of the catalog (e.g. in a nightly job)
+  bget meta check _meta --keys samtools,bwa --only-broken
+  bget meta check _meta -o report.json --baseline last-report.json
+  # add a GitHub repo to the catalog (git clone) or with its release assets
+  bget meta add https://github.com/lh3/minimap2
+  bget meta add https://github.com/BurntSushi/ripgrep --type tools --file _meta/tools/main.json --name rg --dry-run`
+}
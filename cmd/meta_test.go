@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/clindet/bget/meta"
+)
+
+func TestVerifyMetaDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bget-meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(path.Join(dir, "default.json"), []byte(`{"files": ["files/db.json"]}`), 0644)
+	oldConfig, oldAllow := bgetConfig, bgetClis.AllowUnsigned
+	defer func() { bgetConfig, bgetClis.AllowUnsigned = oldConfig, oldAllow }()
+	bgetConfig, bgetClis.AllowUnsigned = bgetConfigT{}, false
+
+	files := []string{"default.json"}
+	if !verifyMetaDir(dir, files, true) {
+		t.Error("the unsigned builtin channel should be accepted")
+	}
+	if verifyMetaDir(dir, files, false) {
+		t.Error("an unsigned channel should be refused")
+	}
+	bgetClis.AllowUnsigned = true
+	if !verifyMetaDir(dir, files, false) {
+		t.Error("an unsigned channel should be accepted with --allow-unsigned")
+	}
+	bgetClis.AllowUnsigned = false
+
+	pub, priv, _ := meta.GenerateKey()
+	other, _, _ := meta.GenerateKey()
+	if _, err := meta.SignDir(dir, priv); err != nil {
+		t.Fatal(err)
+	}
+	if !verifyMetaDir(dir, files, true) {
+		t.Error("the signed builtin channel should be accepted without TrustedKeys")
+	}
+	bgetConfig.TrustedKeys = []string{other}
+	if verifyMetaDir(dir, files, true) {
+		t.Error("the builtin channel signed by an untrusted key should be refused")
+	}
+	bgetConfig.TrustedKeys = []string{pub}
+	if !verifyMetaDir(dir, files, false) {
+		t.Error("a channel signed by a trusted key should be accepted")
+	}
+	// the entry is in the signed set
+	ioutil.WriteFile(path.Join(dir, "default.json"), []byte(`{"baseURL": ["", "http://evil.example.org/"]}`), 0644)
+	if verifyMetaDir(dir, files, false) {
+		t.Error("a changed entry should be refused")
+	}
+}
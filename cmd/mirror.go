@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/clindet/bget/meta"
+	cio "github.com/openbiox/ligo/io"
+	cnet "github.com/openbiox/ligo/net"
+	"github.com/spf13/cobra"
+)
+
+var mirrorFrom string
+var mirrorBaseURL string
+var mirrorWithFiles bool
+var mirrorKeyFile string
+
+// MirrorCmd is the cobra command object to run bget mirror
+var MirrorCmd = &cobra.Command{
+	Use:   "mirror",
+	Short: "Build mirrors of channels for self-hosting.",
+	Long:  `Build a mirror of a channel (meta data and optionally the files of keys) that can be published by any static web server and used as a channel. More see here https://github.com/clindet/bget.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+// MirrorBuildCmd is the cobra command object to run bget mirror build
+var MirrorBuildCmd = &cobra.Command{
+	Use:   "build [mirror-dir] [key[@version]...]",
+	Short: "Copy the meta data (and files) of keys into a mirror dir.",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mirrorBuildCmdRunOptions(cmd, args)
+	},
+}
+
+func mirrorBuildCmdRunOptions(cmd *cobra.Command, args []string) {
+	initCmd(cmd, args)
+	if mirrorFrom == "" {
+		dirs := lintDefaultDirs()
+		if len(dirs) == 0 {
+			log.Fatal("No channel to mirror (use --from).")
+		}
+		mirrorFrom = dirs[0]
+	}
+	if hasDir, _ := cio.PathExists(mirrorFrom); hasDir && path.Ext(mirrorFrom) == ".json" {
+		mirrorFrom = path.Dir(mirrorFrom)
+	}
+	mirrorBaseURL = strings.TrimRight(mirrorBaseURL, "/")
+	destDir := args[0]
+	netOpt := setNetParams(&bgetClis)
+	opt := meta.MirrorOpt{
+		BaseURL:   mirrorBaseURL,
+		Keys:      args[1:],
+		WithFiles: mirrorWithFiles,
+		Overwrite: bgetClis.Overwrite,
+		Fetch: func(urls []string, destDirs []string) []string {
+			return cnet.HTTPGetURLs(urls, destDirs, netOpt)
+		},
+	}
+	log.Infof("Mirroring %s => %s (%s)", mirrorFrom, destDir, mirrorBaseURL)
+	res, err := meta.BuildMirror(mirrorFrom, destDir, opt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	states := make(map[string]int)
+	for _, f := range res.Files {
+		states[f.State]++
+		if f.State == meta.MirrorFailed {
+			log.Errorf("Failed to mirror %s@%s: %s", f.Key, f.Version, f.URL)
+		}
+	}
+	for _, k := range res.Keys {
+		if reason, ok := res.Upstream[k]; ok {
+			log.Warnf("%s keeps the upstream URLs: %s.", k, reason)
+		}
+	}
+	if mirrorKeyFile != "" {
+		priv, err := ioutil.ReadFile(mirrorKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := meta.SignDir(destDir, string(priv)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Infof("Mirrored %d keys (%d meta files, %d files copied, %d existed) to %s.", len(res.Keys), len(res.Meta),
+		states[meta.MirrorCopied], states[meta.MirrorExisted], destDir)
+	log.Infof("Publish %s at %s, the entry of the channel is %s/default.json.", destDir, mirrorBaseURL, mirrorBaseURL)
+	if states[meta.MirrorFailed] > 0 {
+		log.Fatalf("%d files failed to mirror.", states[meta.MirrorFailed])
+	}
+}
+
+func init() {
+	MirrorBuildCmd.Flags().StringVarP(&mirrorFrom, "from", "", "", "Channel dir to mirror (default is _meta or the first channel).")
+	MirrorBuildCmd.Flags().StringVarP(&mirrorBaseURL, "base-url", "", "", "URL that the mirror dir is published at (required).")
+	MirrorBuildCmd.Flags().BoolVarP(&mirrorWithFiles, "with-files", "", false, "Copy the files of keys into the mirror and rewrite their URLs.")
+	MirrorBuildCmd.Flags().StringVarP(&mirrorKeyFile, "key", "", "", "Sign the mirror with this private key (bget meta keygen).")
+	MirrorBuildCmd.Flags().StringVarP(&(bgetClis.Proxy), "proxy", "", "", "HTTP proxy to download.")
+	MirrorBuildCmd.Flags().IntVarP(&(bgetClis.Thread), "thread", "t", 1, "Concurrency download thread.")
+	MirrorBuildCmd.Flags().BoolVarP(&(bgetClis.Overwrite), "overwrite", "f", false, "Download the files existed in the mirror again.")
+	MirrorBuildCmd.Flags().IntVarP(&bgetClis.Retries, "retries", "r", 5, "Retry specifies the number of attempts to retrieve the data.")
+	MirrorBuildCmd.Flags().IntVarP(&bgetClis.Timeout, "timeout", "", 35, "Set the timeout of per request.")
+	MirrorBuildCmd.Flags().IntVarP(&bgetClis.RetSleepTime, "retries-sleep-time", "", 5, "Sleep time after one retry.")
+	MirrorBuildCmd.Flags().StringVarP(&(bgetClis.Engine), "engine", "g", "default", "Point the download engine: default, simplego, wget, curl, axel, git, and rsync.")
+	MirrorCmd.AddCommand(MirrorBuildCmd)
+	MirrorBuildCmd.Example = `  # mirror the meta data of all keys
+  bget mirror build /var/www/bget --base-url https://bget.lab.org
+  # mirror samtools (and its Requires) with the files of two versions
+  bget mirror build /var/www/bget samtools@1.10,1.9 bwa --base-url https://bget.lab.org --with-files -g wget
+  # mirror a channel dir and sign it
+  bget mirror build /var/www/bget --from _meta --base-url https://bget.lab.org --key ~/.config/bget/keys/lab.key
+  # use the mirror
+  bget channel add lab https://bget.lab.org/default.json
+  bget i samtools@1.10 -c lab`
+}
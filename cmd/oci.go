@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/clindet/bget/urlpool"
+)
+
+// image formats of --image-format
+const (
+	imageDockerArchive = "docker-archive"
+	imageOCI           = "oci"
+)
+
+// downloadImages pulls docker:// and oras:// references over the registry
+// API, images are written as docker-archive or OCI layout tarballs and ORAS
+// artifacts (e.g. Singularity SIF) as their files. It returns the pulled
+// images of keys.
+func downloadImages(keys []string) (pulled map[string]*urlpool.Image) {
+	pulled = make(map[string]*urlpool.Image)
+	if bgetClis.ImageFormat != imageDockerArchive && bgetClis.ImageFormat != imageOCI {
+		log.Fatalf("Unknown --image-format %s (use %s or %s).", bgetClis.ImageFormat, imageDockerArchive, imageOCI)
+	}
+	blobDir := path.Join(bgetClis.DownloadDir, ".bget-oci")
+	defer os.RemoveAll(blobDir)
+	for _, key := range keys {
+		ref, err := urlpool.ParseImageRef(key)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		img, err := urlpool.ResolveImage(ref, bgetClis.Env["osType"], bgetClis.Env["arch"])
+		if err != nil {
+			log.Errorf("%s: %v", key, err)
+			continue
+		}
+		destDir := bgetClis.DownloadDir
+		if bgetClis.AutoPath {
+			destDir = path.Join(destDir, "images", path.Base(ref.Repository))
+		}
+		if bgetClis.DryRun {
+			printImage(key, img, destDir)
+			continue
+		}
+		log.Infof("Resolved %s => %s (%d layers)", key, img.Digest, len(img.Manifest.Layers))
+		if err := urlpool.FetchImageBlobs(img, blobDir); err != nil {
+			log.Errorf("Failed to pull %s: %v", key, err)
+			continue
+		}
+		if ref.Scheme == "oras" {
+			files, err := urlpool.WriteArtifactFiles(img, blobDir, destDir)
+			if err != nil {
+				log.Errorf("Failed to write %s: %v", key, err)
+				continue
+			}
+			log.Infof("Pulled %s => %s", key, strings.Join(files, ", "))
+			pulled[key] = img
+			continue
+		}
+		dest := path.Join(destDir, ref.FileName())
+		if bgetClis.ImageFormat == imageOCI {
+			err = urlpool.WriteOCILayout(img, blobDir, dest)
+		} else {
+			err = urlpool.WriteDockerArchive(img, blobDir, dest)
+		}
+		if err != nil {
+			log.Errorf("Failed to write %s: %v", dest, err)
+			continue
+		}
+		log.Infof("Pulled %s => %s (%s)", key, dest, bgetClis.ImageFormat)
+		pulled[key] = img
+	}
+	return pulled
+}
+
+func printImage(key string, img *urlpool.Image, destDir string) {
+	fmt.Printf("key> %s\n", key)
+	if img.Ref.Scheme == "oras" {
+		for _, l := range img.Manifest.Layers {
+			fmt.Printf("url> %s => %s\n", l.Digest, path.Join(destDir, l.Annotations["org.opencontainers.image.title"]))
+		}
+	} else {
+		fmt.Printf("url> %s => %s (%s)\n", img.Ref, path.Join(destDir, img.Ref.FileName()), bgetClis.ImageFormat)
+	}
+	fmt.Printf("img> %s %s\n", img.Digest, img.Manifest.MediaType)
+	for _, b := range img.Blobs() {
+		fmt.Printf("blob> %s (%d bytes) %s\n", b.Digest, b.Size, b.MediaType)
+	}
+	fmt.Println("-----------")
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/clindet/bget/urlpool"
+	bexec "github.com/openbiox/ligo/exec"
+	cio "github.com/openbiox/ligo/io"
+)
+
+// PostShellCmd execution modes of bget i
+const (
+	postCmdAuto = "auto"
+	postCmdYes  = "yes"
+	postCmdNo   = "no"
+	postCmdAsk  = "ask"
+)
+
+// postCmdAllowed reports whether PostShellCmd of key may run under the current policy
+func postCmdAllowed(key string, u string, cmdStr string) bool {
+	switch bgetClis.PostCmd {
+	case postCmdNo:
+		return false
+	case postCmdYes:
+		return true
+	case postCmdAsk:
+		return confirmPostCmd(key, u, cmdStr)
+	}
+	if localChannels[keyChannel(key)] || postCmdAllowListed(key) {
+		return true
+	}
+	taskLog(key, u).Warnf("Skip post command from remote channel: %s (use --post-cmd ask|yes or add the key to PostCmdAllow in %s)", cmdStr, configPath())
+	return false
+}
+
+func postCmdAllowListed(key string) bool {
+	allow := bgetConfig.PostCmdAllow
+	if bgetClis.PostCmdAllow != "" {
+		allow = append(allow, strings.Split(bgetClis.PostCmdAllow, bgetClis.Seperator)...)
+	}
+	for _, pattern := range allow {
+		if ok, _ := path.Match(strings.TrimSpace(pattern), key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func confirmPostCmd(key string, u string, cmdStr string) bool {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		taskLog(key, u).Warnf("Skip post command (stdin is not a terminal): %s", cmdStr)
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "Run post command of %s?\n  %s\n[y/N]: ", key, cmdStr)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func postCmdRender(oldCmd string, dest string) (newCmd string) {
+	if hasDest, _ := cio.PathExists(dest); !hasDest && !bgetClis.DryRun {
+		return ""
+	}
+	// define your pattern replace
+	newCmd = strings.Replace(oldCmd, "{{downloadDir}}", urlpool.ShellQuote(bgetClis.DownloadDir), 100)
+	newCmd = strings.Replace(newCmd, "{{dest}}", urlpool.ShellQuote(dest), 100)
+	newCmd = strings.Replace(newCmd, "{{pdir}}", urlpool.ShellQuote(path.Dir(dest)), 100)
+	return newCmd
+}
+
+// runPostCmd runs cmdStr in the dir of dest (downloaded from u) with the
+// --post-cmd-timeout limit
+func runPostCmd(key string, u string, cmdStr string, dest string) {
+	if !postCmdAllowed(key, u, cmdStr) {
+		return
+	}
+	if err := runShellCmd(cmdStr, path.Dir(dest)); err != nil {
+		taskLog(key, u).WithError(err).Warn("post shell command failed")
+	}
+}
+
+// postCmdLogPath returns the log file of the output of post commands, it is
+// apart from the task log (which may be JSON lines) and added to the log
+// files of the task
+func postCmdLogPath() string {
+	fn := logPrefix + ".postcmd.log"
+	if curTask != nil && !inStrings(curTask.LogFiles, fn) {
+		curTask.LogFiles = append(curTask.LogFiles, fn)
+		saveTask()
+	}
+	return fn
+}
+
+// runShellCmd runs cmdStr in dir with the --post-cmd-timeout limit
+func runShellCmd(cmdStr string, dir string) error {
+	ctx := context.Background()
+	if bgetClis.PostCmdTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(bgetClis.PostCmdTimeout)*time.Second)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", cmdStr)
+	cmd.Dir = dir
+	logPath := ""
+	if bgetClis.SaveLog {
+		logPath = postCmdLogPath()
+	}
+	if err := bexec.System(cmd, logPath, bgetClis.Verbose == 0); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
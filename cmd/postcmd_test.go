@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/clindet/bget/urlpool"
+)
+
+func TestPostCmdAllowed(t *testing.T) {
+	oldClis, oldConfig, oldFiles, oldLocal := bgetClis, bgetConfig, fileLinks, localChannels
+	defer func() { bgetClis, bgetConfig, fileLinks, localChannels = oldClis, oldConfig, oldFiles, oldLocal }()
+	fileLinks = []urlpool.BgetFilesURLType{
+		{Name: "reffa/defuse", Channel: defaultChannel},
+		{Name: "db/annovar", Channel: defaultChannel},
+		{Name: "lab/ref", Channel: "lab"},
+	}
+	localChannels = map[string]bool{"lab": true}
+	bgetConfig = bgetConfigT{PostCmdAllow: []string{"db/*"}}
+	bgetClis.Seperator = ","
+	for _, v := range []struct {
+		mode  string
+		allow string
+		key   string
+		want  bool
+	}{
+		{postCmdAuto, "", "lab/ref", true},
+		{postCmdAuto, "", "reffa/defuse", false},
+		{postCmdAuto, "", "db/annovar", true},
+		{postCmdAuto, "reffa/*", "reffa/defuse", true},
+		{postCmdAuto, "other, reffa/def*", "reffa/defuse", true},
+		{postCmdAuto, "reffa", "reffa/defuse", false},
+		{postCmdYes, "", "reffa/defuse", true},
+		{postCmdNo, "", "lab/ref", false},
+		{postCmdNo, "reffa/*", "reffa/defuse", false},
+	} {
+		bgetClis.PostCmd, bgetClis.PostCmdAllow = v.mode, v.allow
+		if got := postCmdAllowed(v.key, "", "echo ok"); got != v.want {
+			t.Errorf("--post-cmd %s --post-cmd-allow %q: postCmdAllowed(%s) = %v, want %v", v.mode, v.allow, v.key, got, v.want)
+		}
+	}
+}
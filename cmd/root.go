@@ -39,6 +39,7 @@ type bgetCliT struct {
 	TaskID             string
 	Overwrite          bool
 	SaveLog            bool
+	LogFormat          string
 	LogDir             string
 	Verbose            int
 	Env                map[string]string
@@ -49,7 +50,25 @@ type bgetCliT struct {
 	GitHubMode         bool
 	OnlyAssets         bool
 	WithAssets         bool
+	WithBranches       bool
+	Prerelease         bool
+	VersionsTTL        string
 	WithAssetsVersions string
+	GitClone           bool
+	Submodules         bool
+	AssetsInclude      string
+	AssetsExclude      string
+	AllAssets          bool
+	DryRun             bool
+	NoDeps             bool
+	CondaPrefix        string
+	ImageFormat        string
+	AllowUnsigned      bool
+	PostCmd            string
+	PostCmdAllow       string
+	PostCmdTimeout     int
+	OS                 string
+	Arch               string
 	HelpFlags          bool
 }
 
@@ -67,6 +86,7 @@ var rootCmd = &cobra.Command{
 // Execute main interface of bget
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		finishTask(taskFailed)
 		if !rootCmd.HasFlags() && !rootCmd.HasSubCommands() {
 			rootCmd.Help()
 		} else {
@@ -74,6 +94,7 @@ func Execute() {
 			os.Exit(1)
 		}
 	}
+	finishTask(taskDone)
 }
 
 func rootCmdRunOptions(cmd *cobra.Command, args []string) {
@@ -91,15 +112,26 @@ func init() {
 	rootCmd.AddCommand(DoiCmd)
 	rootCmd.AddCommand(KeyCmd)
 	rootCmd.AddCommand(SeqCmd)
+	rootCmd.AddCommand(TasksCmd)
+	rootCmd.AddCommand(LogsCmd)
+	rootCmd.AddCommand(MetaCmd)
+	rootCmd.AddCommand(ChannelCmd)
+	rootCmd.AddCommand(InstallCmd)
+	rootCmd.AddCommand(UninstallCmd)
+	rootCmd.AddCommand(ListInstalledCmd)
+	rootCmd.AddCommand(BundleCmd)
+	rootCmd.AddCommand(MirrorCmd)
 	rootCmd.Flags().BoolVarP(&(bgetClis.Clean), "clean", "", false, "remove _download and _log in current dir.")
 	rootCmd.PersistentFlags().StringVarP(&(bgetClis.TaskID), "task-id", "k", stringo.RandString(15), "task ID (default is random).")
 	rootCmd.PersistentFlags().StringVarP(&(bgetClis.LogDir), "log-dir", "", path.Join(wd, "_log"), "log dir.")
 	rootCmd.PersistentFlags().IntVarP(&(bgetClis.Verbose), "verbose", "", 1, "verbose level (0:no output, 1: basic level, 2: with env info)")
 	rootCmd.PersistentFlags().BoolVarP(&(bgetClis.SaveLog), "save-log", "", false, "Save log to file.")
+	rootCmd.PersistentFlags().StringVarP(&(bgetClis.LogFormat), "log-format", "", "text", "Log format (text, json).")
 
 	rootCmd.AddCommand(api.BapiCmd)
 	bgetClis.Env = make(map[string]string)
 	bgetClis.Env["osType"] = runtime.GOOS
+	bgetClis.Env["arch"] = runtime.GOARCH
 	bgetClis.Env["wd"] = wd
 	rootCmd.Version = version
 }
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/clindet/bget/urlpool"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var searchTags string
+var searchLimit int
+
+// keyVarT is one template variable of a key shown by bget i info
+type keyVarT struct {
+	Name        string
+	Description string   `json:",omitempty"`
+	Default     string   `json:",omitempty"`
+	Values      []string `json:",omitempty"`
+	List        bool
+	Required    bool
+	ValuesAPI   string `json:",omitempty"`
+}
+
+// keyInfoT is the detailed meta data of a key shown by bget i info
+type keyInfoT struct {
+	Key         string
+	Channel     string
+	Description string
+	Tags        []string
+	Versions    []string
+	VersionsAPI string
+	// VersionsRegex extracts the versions of a listing: VersionsAPI
+	VersionsRegex string   `json:",omitempty"`
+	Requires      []string `json:",omitempty"`
+	URL           map[string][]string
+	Vars          []keyVarT
+	PostShellCmd  []string
+}
+
+// postCmdVars are the variables only provided to post commands
+var postCmdVars = []string{"dest", "pdir", "downloadDir"}
+
+func keyVars(tpls []string, vars map[string]urlpool.BgetVarType, versions []string, versionsAPI string) (info []keyVarT) {
+	seen := make(map[string]bool)
+	for _, tpl := range tpls {
+		for _, name := range urlpool.TemplateVars(tpl) {
+			if seen[name] || inStrings(postCmdVars, name) {
+				continue
+			}
+			seen[name] = true
+			def, declared := vars[name]
+			v := keyVarT{Name: name, Description: def.Description, Default: def.Default, Values: def.Values,
+				List: def.List, Required: def.Required, ValuesAPI: def.ValuesAPI}
+			if name == "version" && v.Default == "" && len(versions) > 0 {
+				v.Default = versions[0]
+			}
+			if !declared && v.Default == "" && !(name == "version" && versionsAPI != "") {
+				v.Required = true
+			}
+			info = append(info, v)
+		}
+	}
+	for name, def := range vars {
+		if !seen[name] {
+			info = append(info, keyVarT{Name: name, Description: def.Description, Default: def.Default,
+				Values: def.Values, List: def.List, Required: def.Required, ValuesAPI: def.ValuesAPI})
+		}
+	}
+	sort.SliceStable(info, func(i, j int) bool {
+		return info[i].Name < info[j].Name
+	})
+	return info
+}
+
+func inStrings(s []string, v string) bool {
+	for i := range s {
+		if s[i] == v {
+			return true
+		}
+	}
+	return false
+}
+
+// queryKeyInfo merges the tools and files meta data of key
+func queryKeyInfo(key string) (info *keyInfoT) {
+	tpls := []string{}
+	vars := make(map[string]urlpool.BgetVarType)
+	merge := func(channel, desc string, tags, versions []string, api string, cmds []string, kv map[string]urlpool.BgetVarType) {
+		if info == nil {
+			info = &keyInfoT{Key: key, Channel: channel, URL: make(map[string][]string)}
+		}
+		if info.Description == "" {
+			info.Description = desc
+		}
+		if info.VersionsAPI == "" {
+			info.VersionsAPI = api
+		}
+		info.Tags = append(info.Tags, tags...)
+		info.Versions = append(info.Versions, versions...)
+		info.PostShellCmd = append(info.PostShellCmd, cmds...)
+		tpls = append(tpls, cmds...)
+		for k, v := range kv {
+			vars[k] = v
+		}
+	}
+	for _, t := range toolLinks {
+		if formatKeyName(t.Name) != key {
+			continue
+		}
+		merge(t.Channel, t.Description, t.Tags, t.Versions, t.VersionsAPI, t.PostShellCmd, t.Vars)
+		if info.VersionsRegex == "" {
+			info.VersionsRegex = t.VersionsRegex
+		}
+		info.Requires = append(info.Requires, t.Requires...)
+		for k, v := range t.URL {
+			info.URL[k] = append(info.URL[k], v...)
+			tpls = append(tpls, v...)
+		}
+	}
+	for _, f := range fileLinks {
+		if formatKeyName(f.Name) != key {
+			continue
+		}
+		merge(f.Channel, f.Description, f.Tags, f.Versions, f.VersionsAPI, f.PostShellCmd, f.Vars)
+		if info.VersionsRegex == "" {
+			info.VersionsRegex = f.VersionsRegex
+		}
+		info.Requires = append(info.Requires, f.Requires...)
+		info.URL["All"] = append(info.URL["All"], f.URL...)
+		tpls = append(tpls, f.URL...)
+	}
+	if info != nil {
+		info.Vars = keyVars(tpls, vars, info.Versions, info.VersionsAPI)
+	}
+	return info
+}
+
+func printJSON(v interface{}) {
+	var str bytes.Buffer
+	jsData, _ := json.Marshal(v)
+	_ = json.Indent(&str, jsData, "", "  ")
+	fmt.Println(str.String())
+}
+
+func printKeyInfo(info *keyInfoT) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Field", "Value"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+	table.Append([]string{"Key", info.Key})
+	table.Append([]string{"Channel", info.Channel})
+	table.Append([]string{"Description", info.Description})
+	table.Append([]string{"Tags", strings.Join(info.Tags, ", ")})
+	table.Append([]string{"Versions", strings.Join(info.Versions, ", ")})
+	if info.VersionsAPI != "" {
+		table.Append([]string{"VersionsAPI", info.VersionsAPI})
+	}
+	if info.VersionsRegex != "" {
+		table.Append([]string{"VersionsRegex", info.VersionsRegex})
+	}
+	if len(info.Requires) > 0 {
+		table.Append([]string{"Requires", strings.Join(info.Requires, ", ")})
+	}
+	for _, k := range urlpool.SortedURLKeys(info.URL) {
+		for _, u := range info.URL[k] {
+			table.Append([]string{"URL (" + k + ")", u})
+		}
+	}
+	for _, v := range info.Vars {
+		attrs := []string{}
+		if v.Required {
+			attrs = append(attrs, "required")
+		}
+		if v.Default != "" {
+			attrs = append(attrs, "default="+v.Default)
+		}
+		if len(v.Values) > 0 {
+			attrs = append(attrs, "values="+strings.Join(v.Values, "|"))
+		}
+		if v.ValuesAPI != "" {
+			attrs = append(attrs, "values of "+v.ValuesAPI)
+		}
+		if v.List {
+			attrs = append(attrs, "list")
+		}
+		if v.Description != "" {
+			attrs = append(attrs, v.Description)
+		}
+		table.Append([]string{"Var {{" + v.Name + "}}", strings.Join(attrs, "; ")})
+	}
+	for _, c := range info.PostShellCmd {
+		table.Append([]string{"PostShellCmd", c})
+	}
+	table.Render()
+}
+
+// KeySearchCmd is the cobra command object to run bget i search
+var KeySearchCmd = &cobra.Command{
+	Use:   "search [query]",
+	Short: "Search keys by name, description and tags (fuzzy).",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		initCmd(cmd, args)
+		query := ""
+		if len(args) == 1 {
+			query = args[0]
+		}
+		tags := []string{}
+		for _, t := range strings.Split(searchTags, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+		if query == "" && len(tags) == 0 {
+			log.Fatal("Please set a query or --tag.")
+		}
+		initLinks()
+		results := urlpool.SearchKeys(query, tags, toolLinks, fileLinks)
+		if searchLimit > 0 && len(results) > searchLimit {
+			results = results[:searchLimit]
+		}
+		switch bgetClis.PrintFormat {
+		case "json":
+			printJSON(results)
+		case "text":
+			for _, v := range results {
+				fmt.Printf("%s\t%s\t%s\n", v.Key, v.Channel, v.Description)
+			}
+		default:
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Key", "Channel", "Score", "Tags", "Description"})
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			for _, v := range results {
+				table.Append([]string{v.Key, v.Channel, strconv.Itoa(v.Score), strings.Join(v.Tags, ", "), v.Description})
+			}
+			table.Render()
+		}
+		if len(results) == 0 {
+			log.Warnf("No keys matched %q.", query)
+		}
+		bgetClis.HelpFlags = false
+	},
+}
+
+// KeyInfoCmd is the cobra command object to run bget i info
+var KeyInfoCmd = &cobra.Command{
+	Use:   "info [key]",
+	Short: "Show the meta data of a key (URL templates, variables, versions and post commands).",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		initCmd(cmd, args)
+		initLinks()
+		info := queryKeyInfo(formatKeyName(strings.TrimSpace(args[0])))
+		if info == nil {
+			log.Fatalf("Key %s not found (try 'bget i search %s').", args[0], args[0])
+		}
+		if bgetClis.PrintFormat == "json" {
+			printJSON(info)
+		} else {
+			printKeyInfo(info)
+		}
+		bgetClis.HelpFlags = false
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{KeySearchCmd, KeyInfoCmd} {
+		c.Flags().StringVarP(&entryLink, "channel", "c", "", "Only use this channel (channel name or entry meta file of bget).")
+		c.Flags().StringVarP(&(bgetClis.PrintFormat), "format", "", "", "Output format (table, json)")
+		KeyCmd.AddCommand(c)
+	}
+	KeySearchCmd.Flags().StringVarP(&searchTags, "tag", "", "", "Only keys with these tags (comma separated).")
+	KeySearchCmd.Flags().IntVarP(&searchLimit, "limit", "n", 50, "Max number of results (0 is no limit).")
+	KeySearchCmd.Example = `  bget i search samtools
+  bget i search "rna seq" --tag aligner -n 10
+  bget i search gatk --format json`
+	KeyInfoCmd.Example = `  bget i info reffa/defuse
+  bget i info samtools --format json`
+}
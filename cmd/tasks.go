@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	cio "github.com/openbiox/ligo/io"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// taskRecord is the status file of one bget task saved as <log-dir>/<task-id>.json
+type taskRecord struct {
+	TaskID     string    `json:"task_id"`
+	Subcommand string    `json:"subcommand"`
+	Args       []string  `json:"args"`
+	Status     string    `json:"status"`
+	Warnings   int       `json:"warnings"`
+	Errors     int       `json:"errors"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end,omitempty"`
+	LogFiles   []string  `json:"log_files"`
+}
+
+const (
+	taskRunning = "running"
+	taskDone    = "done"
+	taskFailed  = "failed"
+)
+
+var curTask *taskRecord
+
+// taskLogHook counts warnings and errors of the current task and, in json
+// mode, fills every entry with the same set of fields.
+type taskLogHook struct {
+	jsonMode bool
+}
+
+func (h *taskLogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *taskLogHook) Fire(entry *logrus.Entry) error {
+	if curTask != nil {
+		if entry.Level == logrus.WarnLevel {
+			curTask.Warnings++
+		} else if entry.Level <= logrus.ErrorLevel {
+			curTask.Errors++
+		}
+	}
+	if !h.jsonMode {
+		return nil
+	}
+	entry.Data["task_id"] = bgetClis.TaskID
+	if curTask != nil {
+		entry.Data["subcommand"] = curTask.Subcommand
+	}
+	for _, k := range []string{"key", "url", logrus.ErrorKey} {
+		if _, ok := entry.Data[k]; !ok {
+			entry.Data[k] = ""
+		}
+	}
+	if _, ok := entry.Data["attempt"]; !ok {
+		entry.Data["attempt"] = 0
+	}
+	return nil
+}
+
+// taskLog returns the logger entry with key and url fields
+func taskLog(key string, url string) *logrus.Entry {
+	return log.WithFields(logrus.Fields{
+		"key": key,
+		"url": url,
+	})
+}
+
+func taskRecordPath(logDir string, taskID string) string {
+	return path.Join(logDir, taskID+".json")
+}
+
+func startTask(cmd *cobra.Command, args []string) {
+	curTask = &taskRecord{
+		TaskID:     bgetClis.TaskID,
+		Subcommand: cmd.Name(),
+		Args:       args,
+		Status:     taskRunning,
+		Start:      time.Now(),
+	}
+	if logPrefix != "" {
+		curTask.LogFiles = append(curTask.LogFiles, logPrefix+".log")
+	}
+	logrus.RegisterExitHandler(func() {
+		finishTask(taskFailed)
+	})
+	saveTask()
+}
+
+func finishTask(status string) {
+	if curTask == nil || curTask.Status != taskRunning {
+		return
+	}
+	curTask.Status = status
+	curTask.End = time.Now()
+	saveTask()
+}
+
+func saveTask() {
+	if curTask == nil || !bgetClis.SaveLog {
+		return
+	}
+	data, err := json.MarshalIndent(curTask, "", "  ")
+	if err != nil {
+		log.Warn(err)
+		return
+	}
+	if err := ioutil.WriteFile(taskRecordPath(bgetClis.LogDir, curTask.TaskID), data, 0664); err != nil {
+		log.Warn(err)
+	}
+}
+
+func readTasks(logDir string) (tasks []*taskRecord) {
+	fns, _ := filepath.Glob(path.Join(logDir, "*.json"))
+	for _, fn := range fns {
+		data, err := ioutil.ReadFile(fn)
+		if err != nil {
+			continue
+		}
+		t := &taskRecord{}
+		if err := json.Unmarshal(data, t); err != nil || t.TaskID == "" {
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Start.Before(tasks[j].Start)
+	})
+	return tasks
+}
+
+// TasksCmd is the cobra command object to run bget tasks
+var TasksCmd = &cobra.Command{
+	Use:   "tasks",
+	Short: "List past tasks with their status.",
+	Long:  `List past tasks saved with --save-log in the log dir. More see here https://github.com/clindet/bget.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		tasksCmdRunOptions(cmd, args)
+	},
+}
+
+func tasksCmdRunOptions(cmd *cobra.Command, args []string) {
+	tasks := readTasks(bgetClis.LogDir)
+	if bgetClis.PrintFormat == "json" {
+		var str bytes.Buffer
+		data, _ := json.Marshal(tasks)
+		json.Indent(&str, data, "", "  ")
+		fmt.Println(str.String())
+		return
+	}
+	if bgetClis.PrintFormat == "text" {
+		for _, t := range tasks {
+			fmt.Printf("%s\t%s\t%s\t%s\n", t.TaskID, t.Subcommand, t.Status, t.Start.Format(time.RFC3339))
+		}
+		return
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Task", "Subcommand", "Status", "Warnings", "Errors", "Start", "Duration", "Args"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for _, t := range tasks {
+		duration := ""
+		if !t.End.IsZero() {
+			duration = t.End.Sub(t.Start).Round(time.Second).String()
+		}
+		table.Append([]string{t.TaskID, t.Subcommand, t.Status, fmt.Sprint(t.Warnings),
+			fmt.Sprint(t.Errors), t.Start.Format("2006-01-02 15:04:05"), duration,
+			strings.Join(t.Args, " ")})
+	}
+	table.Render()
+}
+
+// LogsCmd is the cobra command object to run bget logs
+var LogsCmd = &cobra.Command{
+	Use:   "logs [task-id]",
+	Short: "Show the logs of a past task.",
+	Long:  `Show the logs of a past task saved with --save-log in the log dir. More see here https://github.com/clindet/bget.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		logsCmdRunOptions(cmd, args)
+	},
+}
+
+func logsCmdRunOptions(cmd *cobra.Command, args []string) {
+	taskID := args[0]
+	logFiles := []string{path.Join(bgetClis.LogDir, taskID+".log")}
+	if data, err := ioutil.ReadFile(taskRecordPath(bgetClis.LogDir, taskID)); err == nil {
+		t := &taskRecord{}
+		if err := json.Unmarshal(data, t); err == nil && len(t.LogFiles) > 0 {
+			logFiles = t.LogFiles
+			fmt.Fprintf(os.Stderr, "Task %s (%s): %s\n", t.TaskID, t.Subcommand, t.Status)
+		}
+	}
+	found := false
+	for _, fn := range logFiles {
+		if hasFile, _ := cio.PathExists(fn); !hasFile {
+			continue
+		}
+		found = true
+		fcon, err := os.Open(fn)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		io.Copy(os.Stdout, fcon)
+		fcon.Close()
+	}
+	if !found {
+		log.Fatalf("No logs found for task %s in %s.", taskID, bgetClis.LogDir)
+	}
+}
+
+func init() {
+	TasksCmd.Flags().StringVarP(&(bgetClis.PrintFormat), "format", "", "", "Output format (text, json, table)")
+	TasksCmd.Example = `  bget i bwa --save-log -k bwa_task
+  bget tasks
+  bget tasks --format json
+  bget tasks --log-dir /tmp/_log`
+	LogsCmd.Example = `  bget i bwa --save-log --log-format json -k bwa_task
+  bget logs bwa_task`
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func TestTaskRecord(t *testing.T) {
+	logDir, err := ioutil.TempDir("", "bget-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(logDir)
+	oldClis, oldTask, oldLog, oldPrefix := bgetClis, curTask, log, logPrefix
+	defer func() { bgetClis, curTask, log, logPrefix = oldClis, oldTask, oldLog, oldPrefix }()
+	bgetClis.SaveLog, bgetClis.LogDir, bgetClis.TaskID = true, logDir, "task1"
+	logPrefix = logDir + "/task1"
+	log = logrus.New()
+	log.Out = ioutil.Discard
+	log.AddHook(&taskLogHook{})
+
+	startTask(&cobra.Command{Use: "i"}, []string{"bwa", "samtools"})
+	if tasks := readTasks(logDir); len(tasks) != 1 || tasks[0].Status != taskRunning {
+		t.Fatalf("running task = %+v", tasks)
+	}
+	log.Warn("warning")
+	log.Error("error")
+	log.Error("error")
+	postCmdLogPath()
+	finishTask(taskDone)
+	finishTask(taskFailed)
+
+	tasks := readTasks(logDir)
+	if len(tasks) != 1 {
+		t.Fatalf("readTasks = %+v", tasks)
+	}
+	got := tasks[0]
+	if got.TaskID != "task1" || got.Subcommand != "i" || got.Status != taskDone || got.Warnings != 1 || got.Errors != 2 ||
+		!reflect.DeepEqual(got.Args, []string{"bwa", "samtools"}) || got.End.Before(got.Start) {
+		t.Errorf("task = %+v", got)
+	}
+	if want := []string{logPrefix + ".log", logPrefix + ".postcmd.log"}; !reflect.DeepEqual(got.LogFiles, want) {
+		t.Errorf("LogFiles = %v, want %v", got.LogFiles, want)
+	}
+	// other JSON files of the log dir are not tasks
+	ioutil.WriteFile(logDir+"/other.json", []byte(`{"a": 1}`), 0644)
+	if tasks := readTasks(logDir); len(tasks) != 1 {
+		t.Errorf("readTasks with other.json = %+v", tasks)
+	}
+}
+
+func TestTaskLogJSON(t *testing.T) {
+	oldClis, oldTask, oldLog := bgetClis, curTask, log
+	defer func() { bgetClis, curTask, log = oldClis, oldTask, oldLog }()
+	bgetClis.TaskID, bgetClis.SaveLog = "task2", false
+	curTask = &taskRecord{TaskID: "task2", Subcommand: "url", Status: taskRunning}
+	var buf bytes.Buffer
+	log = logrus.New()
+	log.Out = &buf
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.AddHook(&taskLogHook{jsonMode: true})
+
+	taskLog("bwa", "https://example.org/bwa.tar.gz").WithField("attempt", 2).Warn("retrying")
+	log.Info("plain")
+	dec := json.NewDecoder(&buf)
+	for i, want := range []map[string]interface{}{
+		{"key": "bwa", "url": "https://example.org/bwa.tar.gz", "attempt": 2.0, "error": "", "task_id": "task2", "subcommand": "url", "level": "warning"},
+		{"key": "", "url": "", "attempt": 0.0, "error": "", "task_id": "task2", "subcommand": "url", "level": "info"},
+	} {
+		entry := make(map[string]interface{})
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+		for k, v := range want {
+			if entry[k] != v {
+				t.Errorf("entry %d: %s = %v, want %v", i, k, entry[k], v)
+			}
+		}
+	}
+	if curTask.Warnings != 1 {
+		t.Errorf("Warnings = %d, want 1", curTask.Warnings)
+	}
+}
+
+func TestCheckLogFormat(t *testing.T) {
+	for format, ok := range map[string]bool{"text": true, "json": true, "JSON": false, "": false, "yaml": false} {
+		if err := checkLogFormat(format); (err == nil) != ok {
+			t.Errorf("checkLogFormat(%q) = %v", format, err)
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"os/exec"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/clindet/bget/urlpool"
+	vers "github.com/clindet/bget/versions"
 	"github.com/openbiox/ligo/archive"
+	bexec "github.com/openbiox/ligo/exec"
 	cio "github.com/openbiox/ligo/io"
 	cnet "github.com/openbiox/ligo/net"
 	"github.com/spf13/cobra"
@@ -23,6 +29,7 @@ var URLCmd = &cobra.Command{
 func URLCmdRunOptions(cmd *cobra.Command, args []string) {
 	initCmd(cmd, args)
 	checkArgs(cmd, "url")
+	setPlatform()
 	checkDownloadDir(bgetClis.URLs != "" || bgetClis.ListFile != "")
 	if (bgetClis.URLs != "" || bgetClis.ListFile != "") && !bgetClis.GitHubMode {
 		downloadUrls()
@@ -55,8 +62,7 @@ func downloadUrls() {
 		destDirArray = append(destDirArray, bgetClis.DownloadDir)
 	}
 
-	netOpt := setNetParams(&bgetClis)
-	done := cnet.HTTPGetURLs(urls, destDirArray, netOpt)
+	done, _ := fetchKeyURLs("", urls, destDirArray)
 	for _, dest := range done {
 		if bgetClis.Uncompress {
 			if err := archive.UnarchiveLog(dest, path.Dir(dest)); err != nil {
@@ -64,57 +70,69 @@ func downloadUrls() {
 			}
 		}
 	}
+	// failed plain URLs are logged without changing the exit status, only
+	// release assets failed to verify are fatal
+	if len(done) < len(urls) {
+		log.Errorf("%d of %d URLs failed.", len(urls)-len(done), len(urls))
+	}
 }
 
 func downloadGitHubRepos() {
-	urls := []string{}
+	specs := []string{}
 	if bgetClis.GitHub != "" && strings.Contains(bgetClis.GitHub, bgetClis.Seperator) {
-		urls = strings.Split(bgetClis.GitHub, bgetClis.Seperator)
+		specs = strings.Split(bgetClis.GitHub, bgetClis.Seperator)
 	} else if bgetClis.GitHub != "" {
-		urls = []string{bgetClis.GitHub}
+		specs = []string{bgetClis.GitHub}
 	} else if bgetClis.ListFile != "" {
-		urls = cio.ReadLines(bgetClis.ListFile)
+		specs = cio.ReadLines(bgetClis.ListFile)
+	}
+	repos := []urlpool.RepoSpec{}
+	for _, s := range specs {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		r, err := urlpool.ParseRepoSpec(s)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		repos = append(repos, r)
 	}
-	var destDirArray []string
 	assetsUrls := make(map[string][]string)
 	versIdx := strings.Split(bgetClis.WithAssetsVersions, bgetClis.Seperator)
-	for i := range urls {
-		urls[i] = strings.TrimSpace(urls[i])
-		urls[i] = strings.ReplaceAll(urls[i], "git@github.com:", "")
-		urls[i] = strings.ReplaceAll(urls[i], "https://github.com/", "")
-		urls[i] = strings.ReplaceAll(urls[i], "http://github.com/", "")
-		key := urls[i]
-		urls[i] = "https://github.com/" + urls[i]
-
+	tarballs := []urlpool.RepoSpec{}
+	for i, r := range repos {
 		if bgetClis.WithAssets || bgetClis.OnlyAssets {
-			vers := urlpool.GitHubVersionSpider(urls[i], false)
-			if len(vers) != 0 {
-				log.Infof("Availabe tags of github.com/%s: %s", key, strings.Join(vers, ", "))
-				idx := vers[0]
-				if len(versIdx) == len(urls) && versIdx[i] != "" {
-					idx = versIdx[i]
-				}
-				assetsUrls[key+"/"+idx] = urlpool.GitHubAssetsSpider(urls[i], idx)
-			} else {
-				log.Infoln("assets file not found......")
+			tag := r.Ref
+			// fallback of repos without @ref: --github-assets-versions paired by index
+			if tag == "" && len(versIdx) == len(repos) {
+				tag = versIdx[i]
+			}
+			tag, urls := githubRepoAssets(r, tag)
+			if len(urls) > 0 {
+				assetsUrls[r.Key()+"/"+tag] = urls
 			}
 		}
-		destDirArray = append(destDirArray, bgetClis.DownloadDir)
-	}
-	netOpt := setNetParams(&bgetClis)
-	if !bgetClis.OnlyAssets {
-		cnet.HTTPGetURLs(urls, destDirArray, netOpt)
+		if bgetClis.OnlyAssets {
+			continue
+		}
+		if r.TarballURL() != "" && !bgetClis.GitClone && !bgetClis.Submodules {
+			tarballs = append(tarballs, r)
+		} else if err := gitCloneRepo(r, path.Join(bgetClis.DownloadDir, r.Repo)); err != nil {
+			log.Warnf("Failed to clone %s: %v", r.CloneURL(), err)
+		}
 	}
+	done := downloadRepoTarballs(tarballs)
 	urls2 := []string{}
 	destDirArray2 := []string{}
 	for k, v := range assetsUrls {
 		urls2 = append(urls2, v...)
 		for range v {
-			destDirArray2 = append(destDirArray2, path.Join(bgetClis.DownloadDir, "github-assets", strings.ReplaceAll(k, "https://github.com/", "")))
+			destDirArray2 = append(destDirArray2, path.Join(bgetClis.DownloadDir, "github-assets", k))
 		}
 	}
-	netOpt = setNetParams(&bgetClis)
-	done := cnet.HTTPGetURLs(urls2, destDirArray2, netOpt)
+	assets, _ := fetchKeyURLs("", urls2, destDirArray2)
+	done = append(done, assets...)
 	for _, dest := range done {
 		if bgetClis.Uncompress {
 			if err := archive.UnarchiveLog(dest, path.Dir(dest)); err != nil {
@@ -122,6 +140,130 @@ func downloadGitHubRepos() {
 			}
 		}
 	}
+	if len(assets) < len(urls2) {
+		log.Fatalf("%d of %d release assets failed.", len(urls2)-len(assets), len(urls2))
+	}
+}
+
+// githubRepoAssets returns the release tag (tag or the latest) and the
+// selected asset URLs of a GitHub repo
+func githubRepoAssets(r urlpool.RepoSpec, tag string) (string, []string) {
+	if !urlpool.IsGitHubURL(r.URL()) {
+		log.Warnf("Release assets of %s are not supported (only GitHub repos).", r.Key())
+		return tag, nil
+	}
+	if tag == "" {
+		tags, err := urlpool.GitHubVersionSpider(r.URL(), false)
+		if err != nil {
+			log.Warn(err)
+		}
+		if len(tags) == 0 {
+			log.Infof("No releases of %s found.", r.Key())
+			return tag, nil
+		}
+		log.Infof("Availabe tags of %s: %s", r.Key(), strings.Join(tags, ", "))
+		if tag, err = urlpool.ResolveVersion("latest", tags, false, false); err != nil {
+			tag = tags[0]
+		}
+	}
+	assets, err := urlpool.GitHubReleaseAssets(r.URL(), tag)
+	if err != nil {
+		log.Warn(err)
+	} else if assets == nil {
+		log.Infof("No release %s of %s found.", tag, r.Key())
+	}
+	urls := []string{}
+	selected := urlpool.SelectAssets(assets, vers.AssetFilterOfEnv(bgetClis.Env))
+	if len(assets) > 0 && len(selected) == 0 {
+		log.Warnf("No assets of %s@%s matched %s/%s (use --all-assets or --assets-include).", r.Key(), tag,
+			bgetClis.Env["osType"], bgetClis.Env["arch"])
+	}
+	for _, a := range selected {
+		urls = append(urls, a.URL)
+	}
+	return tag, urls
+}
+
+// downloadRepoTarballs downloads the source tarballs of repos to
+// DownloadDir/repo-ref.tar.gz
+func downloadRepoTarballs(repos []urlpool.RepoSpec) (done []string) {
+	urls := []string{}
+	destDirArray := []string{}
+	tmpDirs := make(map[string]string)
+	tmpRoot := path.Join(bgetClis.DownloadDir, ".bget-repos")
+	for _, r := range repos {
+		dest := path.Join(bgetClis.DownloadDir, r.TarballName())
+		if hasDest, _ := cio.PathExists(dest); hasDest && !bgetClis.Overwrite {
+			log.Infof("%s existed.", dest)
+			done = append(done, dest)
+			continue
+		}
+		if _, ok := tmpDirs[dest]; ok {
+			continue
+		}
+		// one dir of each ref, the tarballs of refs are named alike
+		tmpDir := path.Join(tmpRoot, r.Key(), strings.TrimSuffix(r.TarballName(), ".tar.gz"))
+		tmpDirs[dest] = tmpDir
+		urls = append(urls, r.TarballURL())
+		destDirArray = append(destDirArray, tmpDir)
+	}
+	if len(urls) == 0 {
+		return done
+	}
+	netOpt := setNetParams(&bgetClis)
+	cnet.HTTPGetURLs(urls, destDirArray, netOpt)
+	for dest, tmpDir := range tmpDirs {
+		fns, _ := filepath.Glob(path.Join(tmpDir, "*"))
+		for _, fn := range fns {
+			// wget -O leaves an empty file if the download failed
+			if info, err := os.Stat(fn); err != nil || info.Size() == 0 || strings.HasSuffix(fn, ".st") {
+				continue
+			}
+			if err := os.Rename(fn, dest); err != nil {
+				log.Warn(err)
+				break
+			}
+			done = append(done, dest)
+			break
+		}
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Warn(err)
+		}
+		// the parent dirs are kept if other fetches are using them
+		for dir := path.Dir(tmpDir); dir != path.Dir(tmpRoot); dir = path.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return done
+}
+
+// gitCloneRepo clones r at its ref (shallow) into dest
+func gitCloneRepo(r urlpool.RepoSpec, dest string) error {
+	if hasDest, _ := cio.PathExists(dest); hasDest {
+		if !bgetClis.Overwrite {
+			log.Infof("%s existed.", dest)
+			return nil
+		}
+		if err := os.RemoveAll(dest); err != nil {
+			return err
+		}
+	}
+	log.Infof("Trying git clone %s => %s", r.CloneURL(), dest)
+	logPath := ""
+	if bgetClis.SaveLog {
+		logPath = path.Join(bgetClis.LogDir, fmt.Sprintf("%s_%s_git.log", bgetClis.TaskID, r.Repo))
+		cio.CreateFileParDir(logPath)
+	}
+	for _, args := range r.GitCloneArgs(dest, bgetClis.Submodules) {
+		cmd := exec.Command("git", args...)
+		if err := bexec.System(cmd, logPath, bgetClis.Verbose == 0); err != nil {
+			os.RemoveAll(dest)
+			return err
+		}
+	}
+	return nil
 }
 
 func init() {
@@ -131,7 +273,14 @@ func init() {
 	URLCmd.Flags().BoolVarP(&(bgetClis.GitHubMode), "github", "", false, "GitHub mode.")
 	URLCmd.Flags().BoolVarP(&(bgetClis.OnlyAssets), "only-github-assets", "", false, "Logical indicating that whether to only download github repo assets files.")
 	URLCmd.Flags().BoolVarP(&(bgetClis.WithAssets), "with-github-assets", "", false, "Logical indicating that whether to download associated assets files of github repo.")
-	URLCmd.Flags().StringVarP(&(bgetClis.WithAssetsVersions), "github-assets-versions", "", "", "Required to get specific tagname of github assets (e.g. v2.7.1,v1.0.0).")
+	URLCmd.Flags().StringVarP(&(bgetClis.WithAssetsVersions), "github-assets-versions", "", "", "Tagnames of github assets for the repos without @ref, paired by order (e.g. v2.7.1,v1.0.0).")
+	URLCmd.Flags().BoolVarP(&(bgetClis.GitClone), "git-clone", "", false, "Shallow git clone the repos at @ref instead of downloading the source tarballs.")
+	URLCmd.Flags().BoolVarP(&(bgetClis.Submodules), "submodules", "", false, "Git clone the repos with submodules (implies --git-clone).")
+	URLCmd.Flags().StringVarP(&(bgetClis.AssetsInclude), "assets-include", "", "", "Only download the github assets matched these patterns (comma separated globs, or regexes with ~ prefix).")
+	URLCmd.Flags().StringVarP(&(bgetClis.AssetsExclude), "assets-exclude", "", "", "Skip the github assets matched these patterns (comma separated globs, or regexes with ~ prefix).")
+	URLCmd.Flags().BoolVarP(&(bgetClis.AllAssets), "all-assets", "", false, "Download the github assets of all platforms (default is the assets of --os and --arch).")
+	URLCmd.Flags().StringVarP(&(bgetClis.OS), "os", "", "", "Get github assets of this OS (linux, mac, windows), default is the current OS.")
+	URLCmd.Flags().StringVarP(&(bgetClis.Arch), "arch", "", "", "Get github assets of this arch (amd64, arm64, ...), default is the current arch.")
 	URLCmd.Example = `  urls="https://dldir1.qq.com/weixin/Windows/WeChatSetup.exe,http://download.oray.com/pgy/windows/PgyVPN_4.1.0.21693.exe,https://dldir1.qq.com/qqfile/qq/PCQQ9.1.6/25786/QQ9.1.6.25786.exe" && echo $urls | tr "," "\n"> /tmp/urls.list
 
   bget url ${urls}
@@ -143,7 +292,17 @@ func init() {
   bget url -l /tmp/urls.list -o /tmp/download -f -t 3
 
   bget url Miachol/github_demo --github
+  # source tarball (codeload) or shallow git clone of owner/repo@tag|branch|sha, any repo host
+  bget url PapenfussLab/gridss@v2.7.2 clindet/bget@master --github
+  bget url samtools/htslib@1.10 --git-clone --submodules --github
+  bget url https://gitlab.com/group/repo@v1.0 git@git.example.com:team/tool.git@1a2b3c4 --github
   bget url PapenfussLab/gridss clindet/bget --with-github-assets -t 5 --github
   bget url PapenfussLab/gridss clindet/bget --only-github-assets -t 5 --github
-  bget url PapenfussLab/gridss clindet/bget --with-github-assets --with-assets-versions v2.7.2,v0.1.3 -t 5 --github`
+  bget url PapenfussLab/gridss@v2.7.2 clindet/bget@v0.1.3 --with-github-assets -t 5 --github
+  bget url PapenfussLab/gridss clindet/bget --with-github-assets --github-assets-versions v2.7.2,v0.1.3 -t 5 --github
+  # only the assets of Linux/arm64 (default is the current platform), or by patterns
+  bget url BurntSushi/ripgrep --only-github-assets --os linux --arch arm64 --github
+  bget url BurntSushi/ripgrep --only-github-assets --assets-include "*.deb" --github
+  # GitHub Enterprise (or set GitHubEnterprise in ~/.config/bget/config.json)
+  GITHUB_ENTERPRISE_URL=https://git.example.com/api/v3/ bget url https://git.example.com/team/tool --with-github-assets --github`
 }
@@ -5,8 +5,10 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
+	"github.com/clindet/bget/urlpool"
 	cvrt "github.com/openbiox/ligo/convert"
 	cio "github.com/openbiox/ligo/io"
 	clog "github.com/openbiox/ligo/log"
@@ -31,6 +33,11 @@ func initCmd(cmd *cobra.Command, args []string) {
 		}
 	}
 	setLog()
+	startTask(cmd, args)
+	loadConfig()
+	setVersionCache()
+	setGitHubHosts()
+	setCondaBaseURL()
 	if bgetClis.Clean {
 		clearLogDownload()
 	}
@@ -47,7 +54,18 @@ func initCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
+// checkLogFormat checks the value of --log-format
+func checkLogFormat(format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown --log-format %s (use text or json)", format)
+	}
+	return nil
+}
+
 func setLog() {
+	if err := checkLogFormat(bgetClis.LogFormat); err != nil {
+		log.Fatal(err)
+	}
 	if bgetClis.SaveLog {
 		if bgetClis.LogDir == "" {
 			bgetClis.LogDir = filepath.Join(os.TempDir(), "_log")
@@ -57,6 +75,10 @@ func setLog() {
 		logCon, _ = cio.Open(logPrefix + ".log")
 	}
 	clog.SetLogStream(log, bgetClis.Verbose == 0, bgetClis.SaveLog, &logCon)
+	if bgetClis.LogFormat == "json" {
+		log.SetFormatter(&logrus.JSONFormatter{})
+	}
+	log.AddHook(&taskLogHook{jsonMode: bgetClis.LogFormat == "json"})
 }
 
 func checkDownloadDir(condtions bool) {
@@ -69,6 +91,29 @@ func checkDownloadDir(condtions bool) {
 	}
 }
 
+// verifyAssets checks the size and digest of downloaded GitHub release
+// assets, the assets failed to verify are removed and counted
+func verifyAssets(urls []string, destDirs []string) (failed int) {
+	for i := range urls {
+		asset, ok := urlpool.KnownAsset(urls[i])
+		if !ok || i >= len(destDirs) {
+			continue
+		}
+		fn := filepath.Join(destDirs[i], asset.Name)
+		if hasFile, _ := cio.PathExists(fn); !hasFile {
+			continue
+		}
+		if err := urlpool.VerifyAsset(fn, asset); err != nil {
+			log.Errorf("Failed to verify asset (removed): %v", err)
+			os.Remove(fn)
+			failed++
+		} else if asset.Digest != "" {
+			log.Infof("Verified %s (%s).", fn, asset.Digest)
+		}
+	}
+	return failed
+}
+
 func clearLogDownload() {
 	if err := os.RemoveAll("_download"); err != nil {
 		log.Warn(err)
@@ -102,11 +147,14 @@ func setGlobalFlag(cmd *cobra.Command, bgetClis *bgetCliT) {
 	cmd.Flags().IntVarP(&(bgetClis.ThreadQuery), "thread-req", "d", 1, "Set the thread of request number of per URL.")
 }
 
+// envArgRe matches the name=value args of bget i (not key@>=version)
+var envArgRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
 func checkArgs(cmd *cobra.Command, subcmd string) {
 	items := []string{}
 	for _, v := range cmd.Flags().Args() {
-		if strings.Contains(v, "=") && subcmd == "key" {
-			kvs := strings.Split(v, "=")
+		if envArgRe.MatchString(v) && (subcmd == "key" || subcmd == "bundle") {
+			kvs := strings.SplitN(v, "=", 2)
 			bgetClis.Env[kvs[0]] = strings.TrimSpace(kvs[1])
 		} else {
 			items = append(items, v)
@@ -115,6 +163,17 @@ func checkArgs(cmd *cobra.Command, subcmd string) {
 	if bgetClis.WithAssets {
 		bgetClis.Env["withAssets"] = "yes"
 	}
+	if bgetClis.AllAssets {
+		bgetClis.Env["allAssets"] = "yes"
+	}
+	bgetClis.Env["assetsInclude"] = bgetClis.AssetsInclude
+	bgetClis.Env["assetsExclude"] = bgetClis.AssetsExclude
+	if bgetClis.Prerelease {
+		bgetClis.Env["prerelease"] = "yes"
+	}
+	if bgetClis.WithBranches {
+		bgetClis.Env["withBranches"] = "yes"
+	}
 	if len(items) == 0 {
 		return
 	}
@@ -130,8 +189,8 @@ func checkArgs(cmd *cobra.Command, subcmd string) {
 }
 
 func setNetParams(bgetClis *bgetCliT) (netOpt *cnet.Params) {
-
-	p := par.NewMpb(bgetClis.Verbose == 0, bgetClis.SaveLog, &logCon)
+	// progress bars are text, keep them out of the JSON log
+	p := par.NewMpb(bgetClis.Verbose == 0, bgetClis.SaveLog && bgetClis.LogFormat != "json", &logCon)
 
 	return &cnet.Params{
 		Proxy:          bgetClis.Proxy,
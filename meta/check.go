@@ -0,0 +1,205 @@
+package meta
+
+import (
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clindet/bget/urlpool"
+)
+
+// Link states of CheckLinks
+const (
+	LinkOK          = "ok"
+	LinkBroken      = "broken"
+	LinkRedirect    = "redirect"
+	LinkSizeChanged = "size-changed"
+	LinkSkipped     = "skipped"
+)
+
+// Link is one expanded URL of a key
+type Link struct {
+	Key     string
+	Version string
+	URL     string
+}
+
+// LinkResult is the check result of one Link
+type LinkResult struct {
+	Link
+	State    string
+	Status   int
+	Location string `json:",omitempty"`
+	Size     int64
+	PrevSize int64  `json:",omitempty"`
+	Error    string `json:",omitempty"`
+}
+
+// CheckOpt is the options of CheckLinks
+type CheckOpt struct {
+	Thread  int
+	Timeout int
+	Proxy   string
+	// Baseline is the previous results used to detect size changes
+	Baseline []LinkResult
+}
+
+func expandVersions(key string, tpl string, versions []string, vars map[string]urlpool.BgetVarType) (links []Link) {
+	if len(versions) == 0 {
+		versions = []string{""}
+	}
+	for _, v := range versions {
+		env, err := urlpool.ResolveVars(map[string]string{"version": v}, vars)
+		if err != nil {
+			links = append(links, Link{Key: key, Version: v, URL: tpl})
+			continue
+		}
+		urls, err := urlpool.RenderURL(tpl, env, vars)
+		if err != nil {
+			urls = []string{tpl}
+		}
+		for _, u := range urls {
+			links = append(links, Link{Key: key, Version: env["version"], URL: u})
+		}
+		if len(TemplateVars(tpl)) == 0 {
+			break
+		}
+	}
+	return links
+}
+
+// ExpandLinks expands the URL templates of keys for each of their Versions and
+// the defaults of Vars, keys is the (formatted) key names to check or all keys if empty
+func ExpandLinks(tools []urlpool.BgetToolsURLType, files []urlpool.BgetFilesURLType, keys []string) (links []Link) {
+	want := func(name string) bool {
+		if len(keys) == 0 {
+			return true
+		}
+		return contains(keys, strings.ReplaceAll(strings.ToLower(name), "_", "-"))
+	}
+	for _, t := range tools {
+		if !want(t.Name) {
+			continue
+		}
+		for _, k := range urlpool.SortedURLKeys(t.URL) {
+			for _, u := range t.URL[k] {
+				links = append(links, expandVersions(t.Name, u, t.Versions, t.Vars)...)
+			}
+		}
+	}
+	for _, f := range files {
+		if !want(f.Name) {
+			continue
+		}
+		for _, u := range f.URL {
+			links = append(links, expandVersions(f.Name, u, f.Versions, f.Vars)...)
+		}
+	}
+	return links
+}
+
+func checkLink(client *http.Client, link Link) (res LinkResult) {
+	res = LinkResult{Link: link}
+	if strings.Contains(link.URL, "{{") {
+		res.State = LinkSkipped
+		res.Error = "unresolved template variables"
+		return res
+	}
+	u, err := neturl.Parse(link.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		res.State = LinkSkipped
+		res.Error = "unsupported URL scheme"
+		return res
+	}
+	resp, err := client.Head(link.URL)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed ||
+		resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		req, _ := http.NewRequest("GET", link.URL, nil)
+		req.Header.Set("Range", "bytes=0-0")
+		resp, err = client.Do(req)
+	}
+	if err != nil {
+		res.State = LinkBroken
+		res.Error = err.Error()
+		return res
+	}
+	defer resp.Body.Close()
+	res.Status = resp.StatusCode
+	res.Size = resp.ContentLength
+	if cr := resp.Header.Get("Content-Range"); cr != "" {
+		if i := strings.LastIndex(cr, "/"); i >= 0 {
+			res.Size, _ = strconv.ParseInt(cr[i+1:], 10, 64)
+		}
+	}
+	if resp.StatusCode >= 400 {
+		res.State = LinkBroken
+		return res
+	}
+	res.State = LinkOK
+	if final := resp.Request.URL.String(); final != link.URL {
+		res.State = LinkRedirect
+		res.Location = final
+	}
+	return res
+}
+
+// CheckLinks sends HEAD (or ranged GET) requests of links in parallel
+func CheckLinks(links []Link, opt *CheckOpt) (results []LinkResult) {
+	client := &http.Client{Timeout: time.Duration(opt.Timeout) * time.Second}
+	if opt.Proxy != "" {
+		if proxy, err := neturl.Parse(opt.Proxy); err == nil {
+			client.Transport = &http.Transport{Proxy: http.ProxyURL(proxy)}
+		}
+	}
+	prev := make(map[string]int64)
+	for _, v := range opt.Baseline {
+		prev[v.URL] = v.Size
+	}
+	thread := opt.Thread
+	if thread < 1 {
+		thread = 1
+	}
+	results = make([]LinkResult, len(links))
+	sem := make(chan bool, thread)
+	wg := sync.WaitGroup{}
+	for i := range links {
+		wg.Add(1)
+		sem <- true
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res := checkLink(client, links[i])
+			if size, ok := prev[res.URL]; ok && res.State == LinkOK && size > 0 && res.Size > 0 && size != res.Size {
+				res.State = LinkSizeChanged
+				res.PrevSize = size
+			}
+			results[i] = res
+		}(i)
+	}
+	wg.Wait()
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Key < results[j].Key
+	})
+	return results
+}
+
+// Summary counts LinkResult by state
+func Summary(results []LinkResult) string {
+	count := make(map[string]int)
+	for _, v := range results {
+		count[v.State]++
+	}
+	states := []string{}
+	for _, k := range []string{LinkOK, LinkRedirect, LinkSizeChanged, LinkBroken, LinkSkipped} {
+		states = append(states, fmt.Sprintf("%d %s", count[k], k))
+	}
+	return strings.Join(states, ", ")
+}
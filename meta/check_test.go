@@ -0,0 +1,80 @@
+package meta
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	mux := http.NewServeMux()
+	sized := func(size string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", size)
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+	mux.HandleFunc("/tool-1.0.tar.gz", sized("10"))
+	mux.HandleFunc("/tool-2.0.tar.gz", sized("20"))
+	mux.HandleFunc("/old/tool-1.0.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/tool-1.0.tar.gz", http.StatusMovedPermanently)
+	})
+	// no HEAD, the size is from Content-Range of a ranged GET
+	mux.HandleFunc("/nohead.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Range") != "bytes=0-0" {
+			t.Errorf("ranged GET without Range: %v", r.Header)
+		}
+		w.Header().Set("Content-Range", "bytes 0-0/30")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte("x"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	links := []Link{
+		{Key: "a-ok", URL: ts.URL + "/tool-1.0.tar.gz"},
+		{Key: "b-missing", URL: ts.URL + "/missing.tar.gz"},
+		{Key: "c-redirect", URL: ts.URL + "/old/tool-1.0.tar.gz"},
+		{Key: "d-size", URL: ts.URL + "/tool-2.0.tar.gz"},
+		{Key: "e-nohead", URL: ts.URL + "/nohead.tar.gz"},
+		{Key: "f-template", URL: ts.URL + "/tool-{{version}}.tar.gz"},
+		{Key: "g-ftp", URL: "ftp://ftp.example.org/tool.tar.gz"},
+	}
+	baseline := []LinkResult{{Link: links[3], State: LinkOK, Size: 15}, {Link: links[0], State: LinkOK, Size: 10}}
+	results := CheckLinks(links, &CheckOpt{Thread: 3, Timeout: 5, Baseline: baseline})
+	want := []struct {
+		state  string
+		status int
+		size   int64
+	}{
+		{LinkOK, 200, 10},
+		{LinkBroken, 404, -1},
+		{LinkRedirect, 200, 10},
+		{LinkSizeChanged, 200, 20},
+		{LinkOK, 206, 30},
+		{LinkSkipped, 0, 0},
+		{LinkSkipped, 0, 0},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("CheckLinks = %+v", results)
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Key != links[i].Key || r.State != w.state || r.Status != w.status || (w.size >= 0 && r.Size != w.size) {
+			t.Errorf("%s: got %s %d %d, want %s %d %d", links[i].Key, r.State, r.Status, r.Size, w.state, w.status, w.size)
+		}
+	}
+	if results[2].Location != ts.URL+"/tool-1.0.tar.gz" {
+		t.Errorf("redirect Location = %s", results[2].Location)
+	}
+	if results[3].PrevSize != 15 {
+		t.Errorf("PrevSize = %d, want 15", results[3].PrevSize)
+	}
+	if got := Summary(results); got != "2 ok, 1 redirect, 1 size-changed, 1 broken, 2 skipped" {
+		t.Errorf("Summary = %s", got)
+	}
+}
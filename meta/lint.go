@@ -0,0 +1,384 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	neturl "net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/clindet/bget/urlpool"
+)
+
+// Issue levels of Lint
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+// Issue is one problem found by Lint
+type Issue struct {
+	File    string
+	Key     string
+	Level   string
+	Message string
+}
+
+// OsKeys are the valid OS of BgetToolsURLType.URL keys ("OS" or "OS/arch")
+var OsKeys = urlpool.OsKeys
+
+// BuiltinVars are the template variables always provided by bget
+var BuiltinVars = []string{"version", "site", "release", "chrom", "dest", "pdir", "downloadDir"}
+
+// InstallVars are the template variables of Install.Build steps
+var InstallVars = []string{"prefix", "src"}
+
+var templateVarRe = regexp.MustCompile(`{{\s*([A-Za-z0-9_]+)\s*}}`)
+
+// TemplateVars returns the {{var}} names used in s
+func TemplateVars(s string) []string {
+	return urlpool.TemplateVars(s)
+}
+
+type linter struct {
+	file   string
+	issues []Issue
+}
+
+func (l *linter) add(key string, level string, format string, a ...interface{}) {
+	l.issues = append(l.issues, Issue{File: l.file, Key: key, Level: level, Message: fmt.Sprintf(format, a...)})
+}
+
+func contains(s []string, v string) bool {
+	for i := range s {
+		if s[i] == v {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeStrict decodes data into v and reports the line of syntax errors
+func decodeStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if serr, ok := err.(*json.SyntaxError); ok {
+		line := bytes.Count(data[:serr.Offset], []byte("\n")) + 1
+		return fmt.Errorf("line %d: %v", line, err)
+	} else if terr, ok := err.(*json.UnmarshalTypeError); ok {
+		line := bytes.Count(data[:terr.Offset], []byte("\n")) + 1
+		return fmt.Errorf("line %d: %v", line, err)
+	}
+	return err
+}
+
+func (l *linter) checkTemplate(key string, tpl string, isURL bool, vars map[string]urlpool.BgetVarType) {
+	for _, v := range TemplateVars(tpl) {
+		if _, ok := vars[v]; !ok && !contains(BuiltinVars, v) && (isURL || !contains(InstallVars, v)) {
+			l.add(key, LevelWarning, "undeclared template variable {{%s}} in %s", v, tpl)
+		}
+	}
+	if !isURL {
+		return
+	}
+	raw := templateVarRe.ReplaceAllString(tpl, "x")
+	if strings.HasPrefix(raw, "git@") {
+		return
+	}
+	u, err := neturl.Parse(raw)
+	if err != nil {
+		l.add(key, LevelError, "malformed URL %s: %v", tpl, err)
+		return
+	}
+	if !contains([]string{"http", "https", "ftp", "rsync", "git"}, u.Scheme) || u.Host == "" {
+		l.add(key, LevelError, "malformed URL %s", tpl)
+	}
+}
+
+func (l *linter) checkVersionsAPI(key string, api string, regex string) {
+	// the {{var}} of a listing URL and regex are rendered before listing
+	api, regex = templateVarRe.ReplaceAllString(api, "x"), templateVarRe.ReplaceAllString(regex, "x")
+	if api == "" {
+		if regex != "" {
+			l.add(key, LevelError, "VersionsRegex is only used with a listing: VersionsAPI")
+		}
+		return
+	}
+	p, ref := urlpool.FindVersionProvider(api)
+	if p == nil {
+		l.add(key, LevelError, "unsupported VersionsAPI %s (supported: %s, e.g. https://github.com/owner/repo or pypi:name)",
+			api, strings.Join(urlpool.VersionProviderNames(), ", "))
+		return
+	}
+	if _, ok := p.(urlpool.ListingProvider); ok {
+		p = urlpool.ListingProvider{Regex: regex}
+	} else if regex != "" {
+		l.add(key, LevelError, "VersionsRegex is only used with a listing: VersionsAPI")
+	}
+	if err := p.Validate(ref); err != nil {
+		l.add(key, LevelError, "malformed VersionsAPI %s: %v", api, err)
+	}
+}
+
+func (l *linter) checkInstall(key string, install *urlpool.InstallType) {
+	for _, p := range install.Bin {
+		if _, err := filepath.Match(p, ""); err != nil || filepath.IsAbs(p) || strings.HasPrefix(p, "..") {
+			l.add(key, LevelError, "malformed Install.Bin %s (use globs relative to the install dir)", p)
+		}
+	}
+	if len(install.Build)+len(install.Bin) == 0 {
+		l.add(key, LevelWarning, "empty Install")
+	}
+}
+
+func (l *linter) checkVars(key string, vars map[string]urlpool.BgetVarType, tpls []string) {
+	used := make(map[string]bool)
+	for _, tpl := range tpls {
+		for _, v := range TemplateVars(tpl) {
+			used[v] = true
+		}
+	}
+	names := []string{}
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		def := vars[k]
+		if !used[k] {
+			l.add(key, LevelWarning, "variable %s is declared but not used", k)
+		}
+		if def.ValuesAPI != "" || def.ValuesRegex != "" {
+			l.checkValuesAPI(key, k, def)
+		}
+		if def.Default == "" || len(def.Values) == 0 {
+			continue
+		}
+		defaults := []string{def.Default}
+		if def.List {
+			defaults = strings.Split(def.Default, ",")
+		}
+		for _, v := range defaults {
+			if !contains(def.Values, strings.TrimSpace(v)) {
+				l.add(key, LevelError, "default %q of %s is not in Values", v, k)
+			}
+		}
+	}
+}
+
+func (l *linter) checkValuesAPI(key string, name string, def urlpool.BgetVarType) {
+	p, ref := urlpool.FindVersionProvider(templateVarRe.ReplaceAllString(def.ValuesAPI, "x"))
+	if _, ok := p.(urlpool.ListingProvider); !ok {
+		l.add(key, LevelError, "ValuesAPI %q of %s should be a listing: URL", def.ValuesAPI, name)
+		return
+	}
+	if err := (urlpool.ListingProvider{Regex: templateVarRe.ReplaceAllString(def.ValuesRegex, "x")}).Validate(ref); err != nil {
+		l.add(key, LevelError, "malformed ValuesAPI of %s: %v", name, err)
+	}
+}
+
+func (l *linter) checkKey(name string, seen map[string]string, versions []string, api string, regex string, urls []string,
+	cmds []string, vars map[string]urlpool.BgetVarType) {
+	if name == "" {
+		l.add(name, LevelError, "empty Name")
+		return
+	}
+	key := strings.ReplaceAll(strings.ToLower(name), "_", "-")
+	if fn, ok := seen[key]; ok {
+		l.add(name, LevelError, "duplicate Name %s (first defined in %s)", key, fn)
+	} else {
+		seen[key] = l.file
+	}
+	l.checkVersionsAPI(name, api, regex)
+	for _, tpl := range []string{api, regex} {
+		l.checkTemplate(name, tpl, false, vars)
+	}
+	usesVersion := false
+	for _, u := range urls {
+		l.checkTemplate(name, u, true, vars)
+		usesVersion = usesVersion || contains(TemplateVars(u), "version")
+	}
+	for _, c := range cmds {
+		l.checkTemplate(name, c, false, vars)
+	}
+	l.checkVars(name, vars, append(append([]string{}, urls...), cmds...))
+	if usesVersion && len(versions) == 0 && api == "" && vars["version"].Default == "" && !strings.Contains(strings.Join(urls, " "), "github.com") &&
+		!strings.Contains(strings.Join(urls, " "), "bitbucket.org") {
+		l.add(name, LevelWarning, "{{version}} is used but no Versions or VersionsAPI declared")
+	}
+}
+
+// LintTools checks a tools meta file (e.g. tools/main.json)
+func LintTools(file string, data []byte, seen map[string]string) []Issue {
+	l := &linter{file: file}
+	tools := []urlpool.BgetToolsURLType{}
+	if err := decodeStrict(data, &tools); err != nil {
+		l.add("", LevelError, "invalid JSON: %v", err)
+		return l.issues
+	}
+	for _, t := range tools {
+		urls := []string{}
+		osKeys := []string{}
+		for k := range t.URL {
+			osKeys = append(osKeys, k)
+		}
+		sort.Strings(osKeys)
+		for _, k := range osKeys {
+			if osKey, arch := urlpool.SplitURLKey(k); !contains(OsKeys, osKey) {
+				l.add(t.Name, LevelError, "unknown OS key %q in URL (use %s)", k, strings.Join(OsKeys, ", "))
+			} else if strings.Contains(k, "/") && !contains(urlpool.ArchKeys, arch) {
+				l.add(t.Name, LevelError, "unknown arch %q in URL key %s (use %s)", arch, k, strings.Join(urlpool.ArchKeys, ", "))
+			}
+			if len(t.URL[k]) == 0 {
+				l.add(t.Name, LevelError, "empty URL of %s", k)
+			}
+			urls = append(urls, t.URL[k]...)
+		}
+		if len(t.URL) == 0 {
+			l.add(t.Name, LevelError, "empty URL")
+		}
+		cmds := t.PostShellCmd
+		if t.Install != nil {
+			cmds = append(append([]string{}, cmds...), t.Install.Build...)
+			l.checkInstall(t.Name, t.Install)
+		}
+		l.checkKey(t.Name, seen, t.Versions, t.VersionsAPI, t.VersionsRegex, urls, cmds, t.Vars)
+	}
+	return l.issues
+}
+
+// LintFiles checks a files meta file (e.g. files/db.json)
+func LintFiles(file string, data []byte, seen map[string]string) []Issue {
+	l := &linter{file: file}
+	files := []urlpool.BgetFilesURLType{}
+	if err := decodeStrict(data, &files); err != nil {
+		l.add("", LevelError, "invalid JSON: %v", err)
+		return l.issues
+	}
+	for _, f := range files {
+		if len(f.URL) == 0 {
+			l.add(f.Name, LevelError, "empty URL")
+		}
+		l.checkKey(f.Name, seen, f.Versions, f.VersionsAPI, f.VersionsRegex, f.URL, f.PostShellCmd, f.Vars)
+	}
+	return l.issues
+}
+
+// lintRequires checks the Requires of keys: unknown keys and cycles
+func lintRequires(tools []urlpool.BgetToolsURLType, files []urlpool.BgetFilesURLType) (issues []Issue) {
+	names := []string{}
+	for _, t := range tools {
+		if len(t.Requires) > 0 {
+			names = append(names, t.Name)
+		}
+	}
+	for _, f := range files {
+		if len(f.Requires) > 0 {
+			names = append(names, f.Name)
+		}
+	}
+	for _, name := range names {
+		if _, err := urlpool.ResolveRequires([]string{name}, &tools, &files); err != nil {
+			issues = append(issues, Issue{Key: name, Level: LevelError, Message: err.Error()})
+		}
+	}
+	return issues
+}
+
+// EntryFiles returns the tools and files meta files (relative) of a channel
+// dir, the list is read from dir/default.json if it exists.
+func EntryFiles(dir string) (tools []string, files []string, err error) {
+	entry := make(map[string][]string)
+	if data, err := ioutil.ReadFile(path.Join(dir, "default.json")); err == nil {
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, nil, fmt.Errorf("default.json: invalid JSON: %v", err)
+		}
+		tools, files = entry["tools"], entry["files"]
+	} else {
+		tools, _ = filepath.Glob(path.Join(dir, "tools", "*.json"))
+		files, _ = filepath.Glob(path.Join(dir, "files", "*.json"))
+		for i := range tools {
+			tools[i], _ = filepath.Rel(dir, tools[i])
+		}
+		for i := range files {
+			files[i], _ = filepath.Rel(dir, files[i])
+		}
+	}
+	if len(tools)+len(files) == 0 {
+		return nil, nil, fmt.Errorf("no meta files found in %s", dir)
+	}
+	return tools, files, nil
+}
+
+// LoadDir loads all tools and files meta data of a channel dir
+func LoadDir(dir string) (tools []urlpool.BgetToolsURLType, files []urlpool.BgetFilesURLType, err error) {
+	toolsJSON, filesJSON, err := EntryFiles(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, fn := range toolsJSON {
+		data, err := ioutil.ReadFile(path.Join(dir, fn))
+		if err != nil {
+			return nil, nil, err
+		}
+		tmp := []urlpool.BgetToolsURLType{}
+		if err := json.Unmarshal(data, &tmp); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", fn, err)
+		}
+		tools = append(tools, tmp...)
+	}
+	for _, fn := range filesJSON {
+		data, err := ioutil.ReadFile(path.Join(dir, fn))
+		if err != nil {
+			return nil, nil, err
+		}
+		tmp := []urlpool.BgetFilesURLType{}
+		if err := json.Unmarshal(data, &tmp); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", fn, err)
+		}
+		files = append(files, tmp...)
+	}
+	return tools, files, nil
+}
+
+// LintDir checks all tools and files meta files of a channel dir
+func LintDir(dir string) (issues []Issue, err error) {
+	tools, files, err := EntryFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	seenTools := make(map[string]string)
+	seenFiles := make(map[string]string)
+	for _, fn := range tools {
+		data, err := ioutil.ReadFile(path.Join(dir, fn))
+		if err != nil {
+			issues = append(issues, Issue{File: fn, Level: LevelError, Message: err.Error()})
+			continue
+		}
+		issues = append(issues, LintTools(fn, data, seenTools)...)
+	}
+	for _, fn := range files {
+		data, err := ioutil.ReadFile(path.Join(dir, fn))
+		if err != nil {
+			issues = append(issues, Issue{File: fn, Level: LevelError, Message: err.Error()})
+			continue
+		}
+		issues = append(issues, LintFiles(fn, data, seenFiles)...)
+	}
+	if toolsData, filesData, err := LoadDir(dir); err == nil {
+		issues = append(issues, lintRequires(toolsData, filesData)...)
+	}
+	for key := range seenTools {
+		if _, ok := seenFiles[key]; ok {
+			issues = append(issues, Issue{Key: key, Level: LevelWarning,
+				Message: "defined in both tools and files meta data (URLs are merged)"})
+		}
+	}
+	return issues, nil
+}
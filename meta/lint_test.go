@@ -0,0 +1,64 @@
+package meta
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLintTools(t *testing.T) {
+	data := []byte(`[
+  {"Name": "imagej", "Versions": ["150"], "VersionsAPI": "",
+   "URL": {"Linux": ["http://wsr.imagej.net/ij{{version}}.zip"], "Windows": ["http://wsr.imagej.net/{{builder}}.zip"]}},
+  {"Name": "ImageJ", "VersionsAPI": "https://example.com/x", "URL": {"Mac": ["wsr.imagej.net/ij.zip"]}},
+  {"Name": "fiji", "VersionsAPI": "https://gitlab.com/x", "URL": {"Mac": ["https://fiji.sc/fiji.zip"]}},
+  {"Name": "bwa", "Versions": ["0.7.17"], "URL": {"Linux": ["https://x.org/bwa-{{version}}.tar.bz2"]},
+   "Install": {"Build": ["make && cp bwa {{prefix}}/bin/{{bin}}"], "Bin": ["/usr/bin/bwa"]}}
+]`)
+	issues := LintTools("tools/main.json", data, make(map[string]string))
+	want := []string{
+		`unknown OS key "Windows"`,
+		"undeclared template variable {{builder}}",
+		"duplicate Name imagej",
+		"unsupported VersionsAPI https://example.com/x",
+		"malformed VersionsAPI https://gitlab.com/x",
+		"malformed URL wsr.imagej.net/ij.zip",
+		"undeclared template variable {{bin}}",
+		"malformed Install.Bin /usr/bin/bwa",
+	}
+	for _, v := range issues {
+		if strings.Contains(v.Message, "variable {{prefix}}") {
+			t.Errorf("unexpected issue %v", v)
+		}
+	}
+	for _, w := range want {
+		found := false
+		for _, v := range issues {
+			if strings.Contains(v.Message, w) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("missing issue %q in %v", w, issues)
+		}
+	}
+}
+
+func TestLintFilesInvalidJSON(t *testing.T) {
+	issues := LintFiles("files/db.json", []byte("[\n{\"Name\": \"db\", \"Verison\": []}]"), make(map[string]string))
+	if len(issues) != 1 || issues[0].Level != LevelError || !strings.Contains(issues[0].Message, "Verison") {
+		t.Errorf("unexpected issues %v", issues)
+	}
+}
+
+// TestLintMeta checks the shipped catalog (_meta) has no lint errors
+func TestLintMeta(t *testing.T) {
+	issues, err := LintDir("../_meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range issues {
+		if v.Level == LevelError {
+			t.Errorf("%s: %s: %s", v.File, v.Key, v.Message)
+		}
+	}
+}
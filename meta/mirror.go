@@ -0,0 +1,461 @@
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/clindet/bget/urlpool"
+)
+
+// MirrorDataDir is the dir of the mirrored files: data/<key>/<version>/<file>
+const MirrorDataDir = "data"
+
+// States of MirrorFile
+const (
+	MirrorCopied  = "copied"
+	MirrorExisted = "existed"
+	MirrorFailed  = "failed"
+)
+
+// MirrorOpt is the options of BuildMirror
+type MirrorOpt struct {
+	// BaseURL is the URL that the mirror dir is published at
+	BaseURL string
+	// Keys are the keys (e.g. samtools@1.10) to mirror with their Requires,
+	// all keys if empty
+	Keys []string
+	// WithFiles copies the files of keys into data/ and rewrites their URLs
+	// to BaseURL
+	WithFiles bool
+	// Overwrite downloads the files existed in the mirror again
+	Overwrite bool
+	// Fetch downloads urls into destDirs (one dir per URL) and returns the
+	// downloaded files
+	Fetch func(urls []string, destDirs []string) []string
+}
+
+// MirrorFile is one file of a mirror, Path is relative to the mirror dir
+type MirrorFile struct {
+	Link
+	Path  string
+	State string
+}
+
+// MirrorResult is the result of BuildMirror
+type MirrorResult struct {
+	Keys []string
+	// Meta are the meta files written (relative to the mirror dir)
+	Meta  []string
+	Files []MirrorFile
+	// Upstream are the keys (and the reasons) whose URLs are not rewritten
+	Upstream map[string]string
+}
+
+// mirrorEntry is the mirror plan of one key
+type mirrorEntry struct {
+	versions []string
+	// urls maps the URL templates to the mirror templates
+	urls   map[string]string
+	files  []MirrorFile
+	reason string
+}
+
+func keyName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "_", "-")
+}
+
+// mirrorVersions returns the versions of a key to mirror: all Versions, the
+// versions matched a constraint or selector, or the exact versions
+func mirrorVersions(constraint string, versions []string) ([]string, error) {
+	switch {
+	case constraint == "":
+		return versions, nil
+	case urlpool.IsVersionSelector(constraint):
+		return urlpool.SelectVersions(constraint, versions, true)
+	case constraint == "latest":
+		v, err := urlpool.ResolveVersion(constraint, versions, false, false)
+		return []string{v}, err
+	case urlpool.IsVersionConstraint(constraint):
+		selected := []string{}
+		for _, v := range versions {
+			if ok, err := urlpool.MatchConstraint(v, constraint); err != nil {
+				return nil, err
+			} else if ok {
+				selected = append(selected, v)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("no version matches %q", constraint)
+		}
+		return selected, nil
+	}
+	return strings.Split(constraint, ","), nil
+}
+
+// MirrorTemplate returns the mirror URL template of a URL template of key,
+// e.g. https://x.org/bwa-{{version}}.tar.bz2 =>
+// <baseURL>/data/bwa/{{version}}/bwa-{{version}}.tar.bz2
+func MirrorTemplate(baseURL string, key string, tpl string) string {
+	name := tpl
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	// e.g. https://sourceforge.net/projects/x/files/x-1.0.tar.gz/download
+	name = path.Base(strings.TrimSuffix(strings.TrimRight(name, "/"), "/download"))
+	dir := strings.TrimRight(baseURL, "/") + "/" + MirrorDataDir + "/" + keyName(key)
+	if contains(TemplateVars(tpl), "version") {
+		dir += "/{{version}}"
+	}
+	return dir + "/" + name
+}
+
+// planMirror expands the URL templates of a key into the mirrored files,
+// paths maps the planned mirror paths to their URLs to detect collisions
+func planMirror(baseURL string, name string, constraint string, versions []string, tpls []string,
+	vars map[string]urlpool.BgetVarType, paths map[string]string) (e *mirrorEntry) {
+	e = &mirrorEntry{urls: make(map[string]string)}
+	usesVersion := false
+	for _, tpl := range tpls {
+		if contains(TemplateVars(tpl), "version") {
+			usesVersion = true
+		}
+	}
+	if usesVersion && len(versions) == 0 {
+		e.reason = "no static Versions to mirror"
+		return e
+	}
+	if usesVersion {
+		vs, err := mirrorVersions(constraint, versions)
+		if err != nil {
+			e.reason = err.Error()
+			return e
+		}
+		e.versions = vs
+	}
+	prefix := strings.TrimRight(baseURL, "/") + "/"
+	for _, tpl := range tpls {
+		if _, ok := e.urls[tpl]; ok {
+			continue
+		}
+		mtpl := MirrorTemplate(baseURL, name, tpl)
+		src := expandVersions(name, tpl, e.versions, vars)
+		dst := expandVersions(name, mtpl, e.versions, vars)
+		if len(src) != len(dst) {
+			e.reason = fmt.Sprintf("the file names of %s are not unique", tpl)
+			return e
+		}
+		for i := range src {
+			if strings.Contains(src[i].URL, "{{") {
+				e.reason = fmt.Sprintf("unresolved template variables of %s", tpl)
+				return e
+			}
+			if !strings.HasPrefix(src[i].URL, "http://") && !strings.HasPrefix(src[i].URL, "https://") &&
+				!strings.HasPrefix(src[i].URL, "ftp://") {
+				e.reason = fmt.Sprintf("unsupported URL %s", src[i].URL)
+				return e
+			}
+			p := strings.TrimPrefix(dst[i].URL, prefix)
+			if prev, ok := paths[p]; ok && prev != src[i].URL {
+				e.reason = fmt.Sprintf("%s and %s are both mirrored to %s", prev, src[i].URL, p)
+				return e
+			}
+			paths[p] = src[i].URL
+			e.files = append(e.files, MirrorFile{Link: src[i], Path: p})
+		}
+		e.urls[tpl] = mtpl
+	}
+	return e
+}
+
+// fetchMirrorFiles downloads the files of entries into destDir, the entries
+// with failed files keep their upstream URLs
+func fetchMirrorFiles(entries []*mirrorEntry, destDir string, opt MirrorOpt) error {
+	stage := filepath.Join(destDir, ".bget-mirror")
+	defer os.RemoveAll(stage)
+	urls, dirs := []string{}, []string{}
+	pending := make(map[string]*MirrorFile)
+	fetched := []*MirrorFile{}
+	for _, e := range entries {
+		for i := range e.files {
+			f := &e.files[i]
+			dest := filepath.Join(destDir, filepath.FromSlash(f.Path))
+			if _, err := os.Stat(dest); err == nil && !opt.Overwrite {
+				f.State = MirrorExisted
+				continue
+			} else if _, ok := pending[f.Path]; ok {
+				// the same file of another OS
+				continue
+			}
+			dir := filepath.Join(stage, strconv.Itoa(len(urls)))
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+			urls, dirs = append(urls, f.URL), append(dirs, dir)
+			pending[f.Path], fetched = f, append(fetched, f)
+		}
+	}
+	if len(urls) > 0 {
+		done := make(map[string]string)
+		for _, fn := range opt.Fetch(urls, dirs) {
+			done[filepath.Dir(fn)] = fn
+		}
+		for i, f := range fetched {
+			fn, ok := done[dirs[i]]
+			if !ok {
+				f.State = MirrorFailed
+				continue
+			}
+			dest := filepath.Join(destDir, filepath.FromSlash(f.Path))
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return err
+			}
+			if err := os.Rename(fn, dest); err != nil {
+				return err
+			}
+			f.State = MirrorCopied
+		}
+	}
+	for _, e := range entries {
+		for i := range e.files {
+			if e.files[i].State == "" {
+				e.files[i].State = pending[e.files[i].Path].State
+			}
+			if e.files[i].State == MirrorFailed && e.reason == "" {
+				e.reason = fmt.Sprintf("failed to download %s", e.files[i].URL)
+			}
+		}
+	}
+	return nil
+}
+
+func writeMirrorJSON(fn string, v interface{}) error {
+	data, err := urlpool.IndentJSON(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fn, data, 0644)
+}
+
+// mirrorKeys returns the keys (with the version constraints) to mirror
+func mirrorKeys(keys []string, tools []urlpool.BgetToolsURLType, files []urlpool.BgetFilesURLType) (map[string]string, error) {
+	selected := make(map[string]string)
+	if len(keys) == 0 {
+		for _, t := range tools {
+			selected[keyName(t.Name)] = ""
+		}
+		for _, f := range files {
+			selected[keyName(f.Name)] = ""
+		}
+		return selected, nil
+	}
+	known := make(map[string]bool)
+	for _, t := range tools {
+		known[keyName(t.Name)] = true
+	}
+	for _, f := range files {
+		known[keyName(f.Name)] = true
+	}
+	for _, k := range keys {
+		if name, _ := urlpool.SplitRequire(k); !known[name] {
+			return nil, fmt.Errorf("unknown key %s", name)
+		}
+	}
+	plan, err := urlpool.ResolveRequires(keys, &tools, &files)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range plan {
+		name, constraint := urlpool.SplitRequire(item)
+		selected[name] = constraint
+	}
+	return selected, nil
+}
+
+// BuildMirror copies the meta files of the keys of the channel dir srcDir
+// into destDir (the same layout) with a default.json of opt.BaseURL. The
+// files of keys are copied into destDir/data and their URLs rewritten to
+// opt.BaseURL if opt.WithFiles is true; the Versions of them are pinned to
+// the mirrored versions.
+func BuildMirror(srcDir string, destDir string, opt MirrorOpt) (res MirrorResult, err error) {
+	if opt.BaseURL == "" {
+		return res, fmt.Errorf("the base URL of the mirror is required")
+	}
+	toolsJSON, filesJSON, err := EntryFiles(srcDir)
+	if err != nil {
+		return res, err
+	}
+	tools, files, err := LoadDir(srcDir)
+	if err != nil {
+		return res, err
+	}
+	selected, err := mirrorKeys(opt.Keys, tools, files)
+	if err != nil {
+		return res, err
+	}
+	res.Upstream = make(map[string]string)
+	for k := range selected {
+		res.Keys = append(res.Keys, k)
+	}
+	sort.Strings(res.Keys)
+
+	paths := make(map[string]string)
+	toolsOut := make(map[string][]urlpool.BgetToolsURLType)
+	filesOut := make(map[string][]urlpool.BgetFilesURLType)
+	toolsPlan := make(map[string][]*mirrorEntry)
+	filesPlan := make(map[string][]*mirrorEntry)
+	plan := []*mirrorEntry{}
+	for _, fn := range toolsJSON {
+		tmp := []urlpool.BgetToolsURLType{}
+		if err := readJSON(path.Join(srcDir, fn), &tmp); err != nil {
+			return res, err
+		}
+		for _, t := range tmp {
+			constraint, ok := selected[keyName(t.Name)]
+			if !ok {
+				continue
+			}
+			if opt.WithFiles {
+				tpls := []string{}
+				for _, k := range urlpool.SortedURLKeys(t.URL) {
+					tpls = append(tpls, t.URL[k]...)
+				}
+				e := planMirror(opt.BaseURL, t.Name, constraint, t.Versions, tpls, t.Vars, paths)
+				toolsPlan[fn], plan = append(toolsPlan[fn], e), append(plan, e)
+			}
+			toolsOut[fn] = append(toolsOut[fn], t)
+		}
+	}
+	for _, fn := range filesJSON {
+		tmp := []urlpool.BgetFilesURLType{}
+		if err := readJSON(path.Join(srcDir, fn), &tmp); err != nil {
+			return res, err
+		}
+		for _, f := range tmp {
+			constraint, ok := selected[keyName(f.Name)]
+			if !ok {
+				continue
+			}
+			if opt.WithFiles {
+				e := planMirror(opt.BaseURL, f.Name, constraint, f.Versions, f.URL, f.Vars, paths)
+				filesPlan[fn], plan = append(filesPlan[fn], e), append(plan, e)
+			}
+			filesOut[fn] = append(filesOut[fn], f)
+		}
+	}
+	if opt.WithFiles {
+		ready := []*mirrorEntry{}
+		for _, e := range plan {
+			if e.reason == "" {
+				ready = append(ready, e)
+			}
+		}
+		if err := fetchMirrorFiles(ready, destDir, opt); err != nil {
+			return res, err
+		}
+	}
+
+	entry := map[string][]string{
+		"baseURL": {"{{HOME}}/.config/bget/meta", strings.TrimRight(opt.BaseURL, "/")},
+		"entry":   {"default.json"},
+	}
+	for _, fn := range toolsJSON {
+		if len(toolsOut[fn]) == 0 {
+			continue
+		}
+		for i := range toolsOut[fn] {
+			t := &toolsOut[fn][i]
+			if !opt.WithFiles {
+				break
+			}
+			e := toolsPlan[fn][i]
+			res.Files = appendMirrorFiles(res.Files, e.files)
+			if e.reason != "" {
+				res.Upstream[keyName(t.Name)] = e.reason
+				continue
+			}
+			urls := make(map[string][]string)
+			for k, v := range t.URL {
+				for _, u := range v {
+					urls[k] = append(urls[k], e.urls[u])
+				}
+			}
+			t.URL = urls
+			if e.versions != nil {
+				t.Versions, t.VersionsAPI, t.VersionsRegex = e.versions, "", ""
+			}
+		}
+		if err := writeMirrorJSON(filepath.Join(destDir, filepath.FromSlash(fn)), toolsOut[fn]); err != nil {
+			return res, err
+		}
+		entry["tools"] = append(entry["tools"], fn)
+	}
+	for _, fn := range filesJSON {
+		if len(filesOut[fn]) == 0 {
+			continue
+		}
+		for i := range filesOut[fn] {
+			f := &filesOut[fn][i]
+			if !opt.WithFiles {
+				break
+			}
+			e := filesPlan[fn][i]
+			res.Files = appendMirrorFiles(res.Files, e.files)
+			if e.reason != "" {
+				res.Upstream[keyName(f.Name)] = e.reason
+				continue
+			}
+			urls := []string{}
+			for _, u := range f.URL {
+				urls = append(urls, e.urls[u])
+			}
+			f.URL = urls
+			if e.versions != nil {
+				f.Versions, f.VersionsAPI, f.VersionsRegex = e.versions, "", ""
+			}
+		}
+		if err := writeMirrorJSON(filepath.Join(destDir, filepath.FromSlash(fn)), filesOut[fn]); err != nil {
+			return res, err
+		}
+		entry["files"] = append(entry["files"], fn)
+	}
+	res.Meta = append(append([]string{"default.json"}, entry["tools"]...), entry["files"]...)
+	return res, writeMirrorJSON(filepath.Join(destDir, "default.json"), entry)
+}
+
+// appendMirrorFiles appends the files not in mirrored (the files shared by OS)
+func appendMirrorFiles(mirrored []MirrorFile, files []MirrorFile) []MirrorFile {
+	for _, f := range files {
+		dup := false
+		for _, m := range mirrored {
+			if m.Path == f.Path {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			mirrored = append(mirrored, f)
+		}
+	}
+	return mirrored
+}
+
+func readJSON(fn string, v interface{}) error {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %v", fn, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package meta
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clindet/bget/urlpool"
+)
+
+func TestMirrorTemplate(t *testing.T) {
+	tests := map[string]string{
+		"https://x.org/bwa-{{version}}.tar.bz2":                                     "https://m.org/data/bwa/{{version}}/bwa-{{version}}.tar.bz2",
+		"https://sourceforge.net/projects/x/files/zlib-{{version}}.tar.gz/download": "https://m.org/data/bwa/{{version}}/zlib-{{version}}.tar.gz",
+		"https://x.org/hg38.fa.gz?raw=1":                                            "https://m.org/data/bwa/hg38.fa.gz",
+	}
+	for tpl, want := range tests {
+		if got := MirrorTemplate("https://m.org/", "BWA", tpl); got != want {
+			t.Errorf("MirrorTemplate(%s) = %s, want %s", tpl, got, want)
+		}
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for fn, content := range files {
+		fn = filepath.Join(dir, fn)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuildMirror(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bget-mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src, dest := filepath.Join(dir, "src"), filepath.Join(dir, "mirror")
+	writeFiles(t, src, map[string]string{
+		"default.json": `{"tools": ["tools/main.json"], "files": ["files/db.json"]}`,
+		"tools/main.json": `[
+  {"Name": "foo", "Versions": ["1.1", "1.0"], "VersionsAPI": "https://github.com/x/foo", "Requires": ["libbar"],
+   "URL": {"Linux": ["https://x.org/v{{version}}/foo-{{version}}.tar.gz"], "Mac": ["https://x.org/v{{version}}/foo-{{version}}.tar.gz"]}},
+  {"Name": "libbar", "URL": {"Linux": ["https://x.org/libbar.txt"]}},
+  {"Name": "vcfanno", "URL": {"Linux": ["https://github.com/brentp/vcfanno/releases/download/{{version}}/vcfanno_linux64"]}}
+]`,
+		"files/db.json": `[{"Name": "mydb", "URL": ["https://x.org/broken/db.txt"]}]`,
+	})
+	fetched := []string{}
+	opt := MirrorOpt{BaseURL: "https://m.org", Keys: []string{"foo@~1.1", "vcfanno", "mydb"}, WithFiles: true,
+		Fetch: func(urls []string, destDirs []string) (done []string) {
+			for i, u := range urls {
+				fetched = append(fetched, u)
+				if strings.Contains(u, "broken") {
+					continue
+				}
+				fn := path.Join(destDirs[i], path.Base(u))
+				if err := ioutil.WriteFile(fn, []byte(u), 0644); err != nil {
+					t.Fatal(err)
+				}
+				done = append(done, fn)
+			}
+			return done
+		}}
+	res, err := BuildMirror(src, dest, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"foo", "libbar", "mydb", "vcfanno"}; !reflect.DeepEqual(res.Keys, want) {
+		t.Errorf("Keys = %v, want %v", res.Keys, want)
+	}
+	if len(fetched) != 3 {
+		t.Errorf("fetched %v", fetched)
+	}
+	if len(res.Upstream) != 2 || res.Upstream["vcfanno"] == "" || !strings.Contains(res.Upstream["mydb"], "broken") {
+		t.Errorf("Upstream = %v", res.Upstream)
+	}
+	for _, fn := range []string{"data/foo/1.1/foo-1.1.tar.gz", "data/libbar/libbar.txt"} {
+		if _, err := os.Stat(filepath.Join(dest, fn)); err != nil {
+			t.Errorf("missing %s: %v", fn, err)
+		}
+	}
+	tools := []urlpool.BgetToolsURLType{}
+	data, _ := ioutil.ReadFile(filepath.Join(dest, "tools", "main.json"))
+	if err := json.Unmarshal(data, &tools); err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 3 || !reflect.DeepEqual(tools[0].Versions, []string{"1.1"}) || tools[0].VersionsAPI != "" ||
+		tools[0].URL["Mac"][0] != "https://m.org/data/foo/{{version}}/foo-{{version}}.tar.gz" ||
+		!strings.HasPrefix(tools[2].URL["Linux"][0], "https://github.com/") {
+		t.Errorf("unexpected mirrored tools %s", data)
+	}
+	data, _ = ioutil.ReadFile(filepath.Join(dest, "default.json"))
+	entry := make(map[string][]string)
+	if err := json.Unmarshal(data, &entry); err != nil || entry["baseURL"][1] != "https://m.org" ||
+		!reflect.DeepEqual(entry["files"], []string{"files/db.json"}) {
+		t.Errorf("unexpected default.json %s", data)
+	}
+	if issues, err := LintDir(dest); err != nil || len(issues) != 0 {
+		t.Errorf("LintDir = %v, %v", issues, err)
+	}
+
+	// files existed in the mirror are not downloaded again
+	fetched = nil
+	if _, err := BuildMirror(src, dest, opt); err != nil || len(fetched) != 1 {
+		t.Errorf("rebuild fetched %v, %v", fetched, err)
+	}
+	if _, err := BuildMirror(src, dest, MirrorOpt{BaseURL: "https://m.org", Keys: []string{"bar"}}); err == nil {
+		t.Error("expected an error of the unknown key")
+	}
+}
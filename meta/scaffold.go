@@ -0,0 +1,196 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/clindet/bget/urlpool"
+)
+
+// packageAssetRe matches the assets of package managers and installers
+var packageAssetRe = regexp.MustCompile(`(?i)\.(deb|rpm|apk|msi|pkg|dmg|snap|appimage)$`)
+
+var archiveAssetRe = regexp.MustCompile(`(?i)\.(tar\.gz|tgz|tar\.bz2|tar\.xz|zip)$`)
+
+// assetRank prefers archives to bare binaries and bare binaries to packages
+func assetRank(name string) int {
+	switch {
+	case archiveAssetRe.MatchString(name):
+		return 0
+	case packageAssetRe.MatchString(name):
+		return 2
+	}
+	return 1
+}
+
+// replaceVersion replaces the version v (not a part of a longer version) in
+// name with repl
+func replaceVersion(name string, v string, repl string) string {
+	re := regexp.MustCompile(`(^|[^0-9A-Za-z.])` + regexp.QuoteMeta(v) + `($|[^0-9.]|\.[^0-9])`)
+	return re.ReplaceAllString(name, "${1}"+repl+"${2}")
+}
+
+// AssetTemplate returns the URL template of a release asset with the tag
+// replaced by {{version}}; ok is false if the name has the version without
+// the v of the tag (e.g. tool_1.0.tar.gz of v1.0), which can not be templated
+func AssetTemplate(repoURL string, tag string, name string) (tpl string, ok bool) {
+	tpl = replaceVersion(name, tag, "{{version}}")
+	if bare := strings.TrimLeft(tag, "vV"); bare != tag && replaceVersion(tpl, bare, "") != tpl {
+		return "", false
+	}
+	return strings.TrimRight(repoURL, "/") + "/releases/download/{{version}}/" + tpl, true
+}
+
+// AssetURLs returns the URL templates (by URL keys, e.g. Linux/arm64) of the
+// assets of the latest release, one asset per platform. The assets without
+// OS names are used for all OS if no asset has OS names, and the source
+// archive of tags if there are no usable assets.
+func AssetURLs(info urlpool.GitHubRepo) map[string][]string {
+	urls := make(map[string][]string)
+	ranks := make(map[string]int)
+	neutral, neutralRank := "", 3
+	for _, a := range info.Assets {
+		if urlpool.IsExtraAsset(a.Name) {
+			continue
+		}
+		tpl, ok := AssetTemplate(info.URL, info.Tag, a.Name)
+		if !ok {
+			continue
+		}
+		rank := assetRank(a.Name)
+		osKey, arch := urlpool.AssetPlatform(a.Name)
+		if osKey == "" {
+			if arch == "" && rank < neutralRank {
+				neutral, neutralRank = tpl, rank
+			}
+			continue
+		}
+		key := osKey
+		if arch != "" && arch != "amd64" {
+			key += "/" + arch
+		}
+		if r, ok := ranks[key]; !ok || rank < r {
+			urls[key], ranks[key] = []string{tpl}, rank
+		}
+	}
+	if len(urls) > 0 {
+		return urls
+	}
+	if neutral == "" {
+		neutral = strings.TrimRight(info.URL, "/") + "/archive/{{version}}.tar.gz"
+	}
+	for _, k := range urlpool.OsKeys {
+		urls[k] = []string{neutral}
+	}
+	return urls
+}
+
+// ScaffoldName returns the key name of a repo (lower case) to be added to
+// file, the keys of files/github.json are github/<repo>
+func ScaffoldName(info urlpool.GitHubRepo, file string) string {
+	name := info.Name
+	if name == "" {
+		name = path.Base(info.URL)
+	}
+	if path.Base(file) == "github.json" {
+		name = "github/" + name
+	}
+	return strings.ToLower(name)
+}
+
+// ScaffoldTool returns a tools entry of a repo with the release assets as
+// URL templates and the repo as VersionsAPI
+func ScaffoldTool(info urlpool.GitHubRepo, name string) urlpool.BgetToolsURLType {
+	return urlpool.BgetToolsURLType{Name: name, Description: info.Description, VersionsAPI: info.URL,
+		Tags: info.Topics, URL: AssetURLs(info)}
+}
+
+// ScaffoldFile returns a files entry of a repo (git clone and checkout of
+// the version, like files/github.json), the package assets (e.g. *.deb) are
+// excluded from --with-assets if the release has other binaries
+func ScaffoldFile(info urlpool.GitHubRepo, name string) urlpool.BgetFilesURLType {
+	f := urlpool.BgetFilesURLType{Name: name, Description: info.Description, URL: []string{info.URL},
+		VersionsAPI: info.URL, Tags: info.Topics, PostShellCmd: []string{"cd {{dest}} && git checkout {{version}}"}}
+	exts, binaries := []string{}, false
+	for _, a := range info.Assets {
+		if urlpool.IsExtraAsset(a.Name) {
+			continue
+		}
+		if m := packageAssetRe.FindString(a.Name); m != "" {
+			if p := "*" + strings.ToLower(m); !contains(exts, p) {
+				exts = append(exts, p)
+			}
+		} else {
+			binaries = true
+		}
+	}
+	if binaries && len(exts) > 0 {
+		sort.Strings(exts)
+		f.AssetsExclude = exts
+	}
+	return f
+}
+
+// InsertEntry inserts entry into the JSON array of a meta file between the
+// first two adjacent entries whose Names sort around name (or at the start or
+// end), the other entries are kept as is. It fails if name is already in the
+// file. The entries are expected to be sorted by Name (lower case, _ as -),
+// as files/github.json is; in an unsorted file the entry goes to the first
+// such gap, which may not be its sorted position.
+func InsertEntry(data []byte, name string, entry interface{}) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, fmt.Errorf("the meta file is not a JSON array")
+	}
+	name = keyName(name)
+	pos, first, firstName, prev, last := -1, -1, "", "", int(dec.InputOffset())
+	for dec.More() {
+		start := int(dec.InputOffset())
+		start += len(data[start:]) - len(bytes.TrimLeft(data[start:], " \t\r\n,"))
+		v := struct{ Name string }{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		if keyName(v.Name) == name {
+			return nil, fmt.Errorf("%s is already in the meta file", v.Name)
+		}
+		if first < 0 {
+			first, firstName = start, keyName(v.Name)
+		}
+		if pos < 0 && prev != "" && prev < name && keyName(v.Name) > name {
+			pos = start
+		}
+		prev, last = keyName(v.Name), int(dec.InputOffset())
+	}
+	if pos < 0 && first >= 0 && firstName > name {
+		pos = first
+	}
+	js, err := json.MarshalIndent(entry, "  ", "  ")
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	switch {
+	case pos >= 0:
+		buf.Write(data[:pos])
+		buf.Write(js)
+		buf.WriteString(",\n  ")
+		buf.Write(data[pos:])
+	case bytes.Equal(bytes.TrimSpace(data[:last]), []byte("[")):
+		buf.WriteString("[\n  ")
+		buf.Write(js)
+		buf.WriteString("\n")
+		buf.Write(bytes.TrimLeft(data[last:], " \t\r\n"))
+	default:
+		buf.Write(data[:last])
+		buf.WriteString(",\n  ")
+		buf.Write(js)
+		buf.Write(data[last:])
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,82 @@
+package meta
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/clindet/bget/urlpool"
+)
+
+func TestAssetTemplate(t *testing.T) {
+	repo := "https://github.com/x/tool"
+	tests := []struct {
+		tag, name, want string
+	}{
+		{"1.10", "tool-1.10.tar.bz2", repo + "/releases/download/{{version}}/tool-{{version}}.tar.bz2"},
+		{"v2.1", "tool-v2.1-x86_64-linux.tar.gz", repo + "/releases/download/{{version}}/tool-{{version}}-x86_64-linux.tar.gz"},
+		{"1.1", "tool-1.1.5-win32.zip", repo + "/releases/download/{{version}}/tool-1.1.5-win32.zip"},
+		{"2", "tool-win32.zip", repo + "/releases/download/{{version}}/tool-win32.zip"},
+		{"v2.1", "tool_2.1_linux_amd64.tar.gz", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := AssetTemplate(repo, tt.tag, tt.name); got != tt.want {
+			t.Errorf("AssetTemplate(%s, %s) = %s, want %s", tt.tag, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScaffold(t *testing.T) {
+	info := urlpool.GitHubRepo{URL: "https://github.com/x/Tool", Name: "Tool", Description: "A tool", Topics: []string{"bioinformatics"}, Tag: "1.0"}
+	for _, name := range []string{"tool-1.0-x86_64-linux.tar.gz", "tool-1.0-x86_64-linux.deb", "tool-1.0-aarch64-linux.tar.gz",
+		"tool-1.0-x86_64-apple-darwin.zip", "tool-1.0.jar", "SHA256SUMS"} {
+		info.Assets = append(info.Assets, urlpool.ReleaseAsset{Name: name})
+	}
+	if name := ScaffoldName(info, "_meta/files/github.json"); name != "github/tool" {
+		t.Errorf("ScaffoldName of github.json = %s", name)
+	}
+	name := ScaffoldName(info, "_meta/tools/main.json")
+	tool := ScaffoldTool(info, name)
+	want := map[string][]string{
+		"Linux":       {"https://github.com/x/Tool/releases/download/{{version}}/tool-{{version}}-x86_64-linux.tar.gz"},
+		"Linux/arm64": {"https://github.com/x/Tool/releases/download/{{version}}/tool-{{version}}-aarch64-linux.tar.gz"},
+		"Mac":         {"https://github.com/x/Tool/releases/download/{{version}}/tool-{{version}}-x86_64-apple-darwin.zip"},
+	}
+	if name != "tool" || tool.VersionsAPI != info.URL || !reflect.DeepEqual(tool.URL, want) {
+		t.Errorf("ScaffoldTool = %+v", tool)
+	}
+	file := ScaffoldFile(info, name)
+	if !reflect.DeepEqual(file.URL, []string{info.URL}) || !reflect.DeepEqual(file.AssetsExclude, []string{"*.deb"}) {
+		t.Errorf("ScaffoldFile = %+v", file)
+	}
+	data, _ := json.Marshal([]urlpool.BgetToolsURLType{tool})
+	if issues := LintTools("tools/main.json", data, make(map[string]string)); len(issues) > 0 {
+		t.Errorf("LintTools = %v", issues)
+	}
+	info.Assets = nil
+	if got := ScaffoldTool(info, name).URL["Win"]; !reflect.DeepEqual(got, []string{"https://github.com/x/Tool/archive/{{version}}.tar.gz"}) {
+		t.Errorf("source archive URL = %v", got)
+	}
+}
+
+func TestInsertEntry(t *testing.T) {
+	data := []byte("[\n  {\n    \"Name\": \"bwa\"\n  },\n  {\n    \"Name\": \"star\"\n  }\n]\n")
+	got, err := InsertEntry(data, "samtools", map[string]string{"Name": "samtools"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[\n  {\n    \"Name\": \"bwa\"\n  },\n  {\n    \"Name\": \"samtools\"\n  },\n  {\n    \"Name\": \"star\"\n  }\n]\n"
+	if string(got) != want {
+		t.Errorf("InsertEntry = %s", got)
+	}
+	if got, _ = InsertEntry(data, "zlib", map[string]string{"Name": "zlib"}); !strings.HasSuffix(string(got), "\"star\"\n  },\n  {\n    \"Name\": \"zlib\"\n  }\n]\n") {
+		t.Errorf("InsertEntry at the end = %s", got)
+	}
+	if got, _ = InsertEntry([]byte("[]\n"), "zlib", map[string]string{"Name": "zlib"}); string(got) != "[\n  {\n    \"Name\": \"zlib\"\n  }\n]\n" {
+		t.Errorf("InsertEntry into [] = %s", got)
+	}
+	if _, err := InsertEntry(data, "STAR", map[string]string{"Name": "STAR"}); err == nil {
+		t.Error("expected an error of the existed key")
+	}
+}
@@ -0,0 +1,187 @@
+package meta
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SumsFile is the checksum manifest published with a channel
+const SumsFile = "sha256sums.txt"
+
+// SigFile is the ed25519 signature of SumsFile
+const SigFile = SumsFile + ".sig"
+
+// ErrUnsigned is returned by VerifyDir when the channel has no signature
+var ErrUnsigned = errors.New("meta data is not signed")
+
+// ErrUntrusted is returned by VerifyDir when no trusted key matches the signature
+var ErrUntrusted = errors.New("signature does not match any trusted key")
+
+// GenerateKey creates a new ed25519 key pair encoded in base64
+func GenerateKey() (pub string, priv string, err error) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(pubKey), base64.StdEncoding.EncodeToString(privKey), nil
+}
+
+func decodeKey(key string, size int) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != size {
+		return nil, fmt.Errorf("invalid key size %d", len(raw))
+	}
+	return raw, nil
+}
+
+// PublicKey returns the base64 public key of a base64 private key
+func PublicKey(priv string) (string, error) {
+	raw, err := decodeKey(priv, ed25519.PrivateKeySize)
+	if err != nil {
+		return "", err
+	}
+	pub := ed25519.PrivateKey(raw).Public().(ed25519.PublicKey)
+	return base64.StdEncoding.EncodeToString(pub), nil
+}
+
+// listJSON returns all *.json files of dir (relative and sorted)
+func listJSON(dir string) (files []string, err error) {
+	err = filepath.Walk(dir, func(fn string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || path.Ext(fn) != ".json" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, fn)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+func sha256File(fn string) (string, error) {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// SignDir writes SumsFile of all JSON files in dir and signs it with priv
+func SignDir(dir string, priv string) (files []string, err error) {
+	raw, err := decodeKey(priv, ed25519.PrivateKeySize)
+	if err != nil {
+		return nil, err
+	}
+	files, err = listJSON(dir)
+	if err != nil {
+		return nil, err
+	}
+	var sums bytes.Buffer
+	for _, fn := range files {
+		sum, err := sha256File(path.Join(dir, fn))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&sums, "%s  %s\n", sum, fn)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, SumsFile), sums.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	sig := ed25519.Sign(ed25519.PrivateKey(raw), sums.Bytes())
+	err = ioutil.WriteFile(path.Join(dir, SigFile), []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), 0644)
+	return files, err
+}
+
+// ParseSums parses the content of SumsFile into a map of file => sha256
+func ParseSums(data []byte) (sums map[string]string, err error) {
+	sums = make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("malformed line in %s: %s", SumsFile, line)
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = fields[0]
+	}
+	return sums, scanner.Err()
+}
+
+// VerifyDir checks the signature of SumsFile in dir against trusted keys
+// and the checksums of files. ErrUnsigned is returned if dir has no signature.
+func VerifyDir(dir string, files []string, trusted []string) error {
+	sumsData, err := ioutil.ReadFile(path.Join(dir, SumsFile))
+	if os.IsNotExist(err) {
+		return ErrUnsigned
+	} else if err != nil {
+		return err
+	}
+	sigData, err := ioutil.ReadFile(path.Join(dir, SigFile))
+	if os.IsNotExist(err) {
+		return ErrUnsigned
+	} else if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigData)))
+	if err != nil {
+		return fmt.Errorf("malformed signature: %v", err)
+	}
+	verified := false
+	for _, key := range trusted {
+		pub, err := decodeKey(key, ed25519.PublicKeySize)
+		if err != nil {
+			continue
+		}
+		if ed25519.Verify(ed25519.PublicKey(pub), sumsData, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return ErrUntrusted
+	}
+	sums, err := ParseSums(sumsData)
+	if err != nil {
+		return err
+	}
+	for _, fn := range files {
+		want, ok := sums[fn]
+		if !ok {
+			return fmt.Errorf("%s is not listed in %s", fn, SumsFile)
+		}
+		got, err := sha256File(path.Join(dir, fn))
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return fmt.Errorf("checksum mismatch of %s", fn)
+		}
+	}
+	return nil
+}
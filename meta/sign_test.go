@@ -0,0 +1,74 @@
+package meta
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSignDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bget-sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"default.json":    `{"tools": ["tools/main.json"]}`,
+		"tools/main.json": `[{"Name": "bwa"}]`,
+	})
+	pub, priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := PublicKey(priv); err != nil || got != pub {
+		t.Errorf("PublicKey = %s, %v, want %s", got, err, pub)
+	}
+	files, err := SignDir(dir, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(files, ",") != "default.json,tools/main.json" {
+		t.Errorf("signed files %v", files)
+	}
+	if err := VerifyDir(dir, files, []string{pub}); err != nil {
+		t.Errorf("VerifyDir = %v", err)
+	}
+
+	otherPub, _, _ := GenerateKey()
+	if err := VerifyDir(dir, files, []string{otherPub}); err != ErrUntrusted {
+		t.Errorf("VerifyDir with a wrong key = %v, want %v", err, ErrUntrusted)
+	}
+	if err := VerifyDir(dir, files, nil); err != ErrUntrusted {
+		t.Errorf("VerifyDir without trusted keys = %v, want %v", err, ErrUntrusted)
+	}
+	if err := VerifyDir(dir, append(files, "files/db.json"), []string{pub}); err == nil {
+		t.Error("expected an error of the file not in " + SumsFile)
+	}
+
+	// a tampered meta file
+	writeFiles(t, dir, map[string]string{"tools/main.json": `[{"Name": "bwa", "URL": {"Linux": ["https://evil.org/bwa"]}}]`})
+	if err := VerifyDir(dir, files, []string{pub}); err == nil || !strings.Contains(err.Error(), "checksum mismatch of tools/main.json") {
+		t.Errorf("VerifyDir of a tampered file = %v", err)
+	}
+	// tampered checksums do not match the signature
+	sums, _ := SignDir(dir, priv)
+	data, _ := ioutil.ReadFile(filepath.Join(dir, SumsFile))
+	ioutil.WriteFile(filepath.Join(dir, SumsFile), append(data, []byte("0000  extra.json\n")...), 0644)
+	if err := VerifyDir(dir, sums, []string{pub}); err != ErrUntrusted {
+		t.Errorf("VerifyDir of tampered checksums = %v, want %v", err, ErrUntrusted)
+	}
+
+	os.Remove(filepath.Join(dir, SigFile))
+	if err := VerifyDir(dir, files, []string{pub}); err != ErrUnsigned {
+		t.Errorf("VerifyDir without signature = %v, want %v", err, ErrUnsigned)
+	}
+	os.Remove(filepath.Join(dir, SumsFile))
+	if err := VerifyDir(dir, files, []string{pub}); err != ErrUnsigned {
+		t.Errorf("VerifyDir of an unsigned dir = %v, want %v", err, ErrUnsigned)
+	}
+	if _, err := SignDir(dir, pub); err == nil {
+		t.Error("expected an error of signing with a public key")
+	}
+}
@@ -0,0 +1,265 @@
+package urlpool
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ReleaseAsset is an asset of a GitHub release, Digest is "sha256:<hex>"
+// (empty if the API does not provide it)
+type ReleaseAsset struct {
+	Name   string
+	URL    string `json:"browser_download_url"`
+	Size   int64
+	Digest string
+}
+
+// AssetFilter selects release assets by OS/arch names and include/exclude
+// patterns (globs, or regexes with ~ prefix)
+type AssetFilter struct {
+	OS      string
+	Arch    string
+	Include []string
+	Exclude []string
+	// All keeps the assets of all platforms
+	All bool
+}
+
+// osTokens and archTokens are the names of OS and arch keys in asset names
+var osTokens = map[string][]string{
+	"Linux": {"linux", "gnu", "musl", "ubuntu", "centos"},
+	"Mac":   {"darwin", "macos", "osx", "mac", "apple"},
+	"Win":   {"windows", "win", "win32", "win64", "exe", "msi"},
+}
+var archTokens = map[string][]string{
+	"amd64":   {"amd64", "x64", "64bit"},
+	"arm64":   {"arm64", "aarch64", "armv8"},
+	"386":     {"386", "i386", "i686", "x86", "32bit"},
+	"arm":     {"arm", "armv7", "armv7l", "armv6", "armv6l", "armhf"},
+	"ppc64le": {"ppc64le"},
+	"s390x":   {"s390x"},
+}
+
+// extraAssetRe matches checksums, signatures and other non-binary assets
+var extraAssetRe = regexp.MustCompile(`(?i)(\.(sha1|sha256|sha512|md5|asc|sig|pem|sbom)$|sha\d*sums|checksums)`)
+
+var assetWordRe = regexp.MustCompile(`[a-z0-9]+`)
+
+var knownAssets sync.Map
+
+// KnownAsset returns the size and digest of a release asset URL fetched by
+// GitHubReleaseAssets
+func KnownAsset(url string) (ReleaseAsset, bool) {
+	v, ok := knownAssets.Load(url)
+	if !ok {
+		return ReleaseAsset{}, false
+	}
+	return v.(ReleaseAsset), true
+}
+
+// GitHubReleaseAssets gets the assets (with size and digest) of a release (tag)
+func GitHubReleaseAssets(url, version string) (assets []ReleaseAsset, err error) {
+	user, repo, ctx, client, err := setGitHubCtx(url)
+	if err != nil {
+		return nil, err
+	}
+	rel, resp, err := client.Repositories.GetReleaseByTag(ctx, user, repo, version)
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get release %s of %s/%s: %v", version, user, repo, err)
+	}
+	// go-github does not decode the digest of assets
+	for page := 1; page > 0; {
+		req, err := client.NewRequest("GET", fmt.Sprintf("repos/%s/%s/releases/%d/assets?per_page=%d&page=%d",
+			user, repo, rel.GetID(), githubPerPage, page), nil)
+		if err != nil {
+			return assets, err
+		}
+		tmp := []ReleaseAsset{}
+		resp, err := client.Do(ctx, req, &tmp)
+		if err != nil {
+			return assets, fmt.Errorf("failed to get assets of %s/%s@%s: %v", user, repo, version, err)
+		}
+		for _, a := range tmp {
+			knownAssets.Store(a.URL, a)
+		}
+		assets = append(assets, tmp...)
+		page = resp.NextPage
+	}
+	return assets, nil
+}
+
+func hasToken(words map[string]bool, tokens []string) bool {
+	for _, t := range tokens {
+		if words[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// assetWords returns the lower case words of an asset name, x86_64 and
+// x86-64 are kept as one word
+func assetWords(name string) map[string]bool {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("x86_64", "amd64", "x86-64", "amd64").Replace(name)
+	words := make(map[string]bool)
+	for _, w := range assetWordRe.FindAllString(name, -1) {
+		words[w] = true
+	}
+	return words
+}
+
+func matchPattern(pattern string, name string) bool {
+	if strings.HasPrefix(pattern, "~") {
+		re, err := regexp.Compile(pattern[1:])
+		return err == nil && re.MatchString(name)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p != "" && matchPattern(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExtraAsset returns true if an asset is a checksum, signature or other
+// non-binary file
+func IsExtraAsset(name string) bool {
+	return extraAssetRe.MatchString(name)
+}
+
+// AssetPlatform returns the OS key and arch named in an asset name, empty if
+// the name has no OS (or arch) names
+func AssetPlatform(name string) (osKey string, arch string) {
+	words := assetWords(name)
+	for _, k := range OsKeys {
+		if hasToken(words, osTokens[k]) {
+			osKey = k
+			break
+		}
+	}
+	for _, k := range ArchKeys {
+		if hasToken(words, archTokens[k]) {
+			arch = k
+			break
+		}
+	}
+	return osKey, arch
+}
+
+// SelectAssets returns the assets matched filter: the Include patterns (if
+// set) and not the Exclude patterns, checksums and signatures are dropped
+// unless included. Without Include and All, the assets named with another
+// OS or arch are dropped, e.g. tool-linux-x86_64.tar.gz is kept and
+// tool-darwin-arm64.zip is dropped for Linux/amd64; assets without OS or arch
+// names (e.g. tool.jar) are kept.
+func SelectAssets(assets []ReleaseAsset, filter AssetFilter) (selected []ReleaseAsset) {
+	candidates := []ReleaseAsset{}
+	for _, a := range assets {
+		included := matchAny(filter.Include, a.Name)
+		if len(filter.Include) > 0 && !included {
+			continue
+		}
+		if matchAny(filter.Exclude, a.Name) || (!included && extraAssetRe.MatchString(a.Name)) {
+			continue
+		}
+		candidates = append(candidates, a)
+	}
+	if filter.All || len(filter.Include) > 0 {
+		return candidates
+	}
+	osKey, arch := NormOS(filter.OS), NormArch(filter.Arch)
+	if osKey != "" {
+		tmp := []ReleaseAsset{}
+		for _, a := range candidates {
+			words := assetWords(a.Name)
+			other := false
+			for k, tokens := range osTokens {
+				other = other || (k != osKey && hasToken(words, tokens) && !hasToken(words, osTokens[osKey]))
+			}
+			if !other {
+				tmp = append(tmp, a)
+			}
+		}
+		candidates = tmp
+	}
+	if arch == "" {
+		return candidates
+	}
+	matched, neutral := []ReleaseAsset{}, []ReleaseAsset{}
+	for _, a := range candidates {
+		words := assetWords(a.Name)
+		if hasToken(words, archTokens[arch]) {
+			matched = append(matched, a)
+			continue
+		}
+		other := false
+		for k, tokens := range archTokens {
+			other = other || (k != arch && hasToken(words, tokens))
+		}
+		if !other {
+			neutral = append(neutral, a)
+		}
+	}
+	// amd64 builds run on arm64 Mac and Win (emulated)
+	if len(matched) == 0 && arch == "arm64" && (osKey == "Mac" || osKey == "Win") {
+		return SelectAssets(assets, AssetFilter{OS: filter.OS, Arch: "amd64", Exclude: filter.Exclude})
+	}
+	return append(matched, neutral...)
+}
+
+// VerifyAsset checks the size and digest of a downloaded asset
+func VerifyAsset(fn string, asset ReleaseAsset) error {
+	info, err := os.Stat(fn)
+	if err != nil {
+		return err
+	}
+	if asset.Size > 0 && info.Size() != asset.Size {
+		return fmt.Errorf("%s: size %d, want %d", fn, info.Size(), asset.Size)
+	}
+	if asset.Digest == "" {
+		return nil
+	}
+	algo, want := "sha256", asset.Digest
+	if i := strings.Index(asset.Digest, ":"); i > 0 {
+		algo, want = asset.Digest[:i], asset.Digest[i+1:]
+	}
+	var h hash.Hash
+	switch algo {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("%s: unsupported digest %s", fn, asset.Digest)
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return fmt.Errorf("%s: %s %s, want %s", fn, algo, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package urlpool
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func assetNames(assets []ReleaseAsset) (names []string) {
+	for _, a := range assets {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+func TestSelectAssets(t *testing.T) {
+	assets := []ReleaseAsset{}
+	for _, name := range []string{
+		"tool-1.0-x86_64-unknown-linux-musl.tar.gz",
+		"tool-1.0-aarch64-unknown-linux-gnu.tar.gz",
+		"tool-1.0-x86_64-apple-darwin.tar.gz",
+		"tool-1.0-x86_64-pc-windows-msvc.zip",
+		"tool-1.0-i686-pc-windows-msvc.zip",
+		"tool-1.0-linux-x86_64.tar.gz.sha256",
+		"tool_1.0_amd64.deb",
+		"tool.jar",
+		"SHA256SUMS",
+	} {
+		assets = append(assets, ReleaseAsset{Name: name})
+	}
+	for _, v := range []struct {
+		filter AssetFilter
+		want   []string
+	}{
+		{AssetFilter{OS: "linux", Arch: "amd64"}, []string{"tool-1.0-x86_64-unknown-linux-musl.tar.gz", "tool_1.0_amd64.deb", "tool.jar"}},
+		{AssetFilter{OS: "linux", Arch: "aarch64"}, []string{"tool-1.0-aarch64-unknown-linux-gnu.tar.gz", "tool.jar"}},
+		{AssetFilter{OS: "windows", Arch: "386"}, []string{"tool-1.0-i686-pc-windows-msvc.zip", "tool.jar"}},
+		{AssetFilter{OS: "mac", Arch: "arm64"}, []string{"tool-1.0-x86_64-apple-darwin.tar.gz", "tool_1.0_amd64.deb", "tool.jar"}},
+		{AssetFilter{Include: []string{"*.deb", "~SUMS$"}}, []string{"tool_1.0_amd64.deb", "SHA256SUMS"}},
+		{AssetFilter{OS: "linux", Arch: "amd64", Exclude: []string{"*.deb", "*.jar"}}, []string{"tool-1.0-x86_64-unknown-linux-musl.tar.gz"}},
+	} {
+		if got := assetNames(SelectAssets(assets, v.filter)); !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%+v: got %v, want %v", v.filter, got, v.want)
+		}
+	}
+	if got := SelectAssets(assets, AssetFilter{All: true}); len(got) != 7 {
+		t.Errorf("All: got %v", assetNames(got))
+	}
+}
+
+func TestVerifyAsset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bget-asset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := path.Join(dir, "tool.txt")
+	if err := ioutil.WriteFile(fn, []byte("bget\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	asset := ReleaseAsset{Name: "tool.txt", Size: 5,
+		Digest: "sha256:1f7ee0f5b6d3b7f4a0a5d0c0e9a1a4c0c1c0e7fb6e0e4b3e7d4c0c3f1b6c5d4a"}
+	if err := VerifyAsset(fn, asset); err == nil {
+		t.Error("expected a digest mismatch")
+	}
+	asset.Digest = "sha256:2b449634f3e0d39ba72da92c97aa3a5f107f3fb539734c25ccec2c1bcc1f4942"
+	if err := VerifyAsset(fn, asset); err != nil {
+		t.Error(err)
+	}
+	asset.Size = 6
+	if err := VerifyAsset(fn, asset); err == nil {
+		t.Error("expected a size mismatch")
+	}
+}
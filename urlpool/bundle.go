@@ -0,0 +1,295 @@
+package urlpool
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BundleFormat is the format version of bundle.json
+const BundleFormat = 1
+
+// BundleMetaDir is the dir of bundle.json and the catalog (meta/) of an
+// installed bundle
+const BundleMetaDir = ".bget-bundle"
+
+// BundleManifest is the bundle.json of an offline bundle, the files are in
+// files/ (the layout of bget i -o) and the catalog subset in meta/
+type BundleManifest struct {
+	Format  int
+	Created time.Time
+	OS      string
+	Arch    string
+	// AutoPath is true if the files of keys are in <key>/ dirs (--autopath)
+	AutoPath bool `json:",omitempty"`
+	Items    []BundleItem
+	Files    []BundleFile
+}
+
+// BundleItem is one key, URL or accession of a bundle
+type BundleItem struct {
+	// Input is the item given to bget bundle create
+	Input string
+	// Type is key, url, accession, conda or image
+	Type    string
+	Key     string   `json:",omitempty"`
+	Version string   `json:",omitempty"`
+	Channel string   `json:",omitempty"`
+	URLs    []string `json:",omitempty"`
+}
+
+// BundleFile is one file of a bundle, Path is relative to files/
+type BundleFile struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+var accessionRe = regexp.MustCompile(`(?i)^(GSE|GPL|GDS|GSM|SRR|ERR|EGAD|EGAF)\d+$`)
+
+// BundleItemType returns the type of a bundle item: conda, image, url,
+// accession (the ids of bget seq) or key
+func BundleItemType(item string) string {
+	item = strings.TrimSpace(item)
+	switch {
+	case IsCondaKey(item):
+		return "conda"
+	case IsImageRef(item):
+		return "image"
+	case strings.Contains(item, "://") || strings.HasPrefix(item, "git@"):
+		return "url"
+	case accessionRe.MatchString(item):
+		return "accession"
+	}
+	return "key"
+}
+
+// PinCatalog returns the meta data of the resolved keys with the Versions
+// pinned to the resolved versions, an empty version keeps the Versions
+func PinCatalog(resolved map[string]string, BgetToolsPool *[]BgetToolsURLType, BgetFilesPool *[]BgetFilesURLType) (tools []BgetToolsURLType, files []BgetFilesURLType) {
+	for _, t := range *BgetToolsPool {
+		v, ok := resolved[formatName(t.Name)]
+		if !ok {
+			continue
+		}
+		if v != "" {
+			t.Versions, t.VersionsAPI, t.VersionsRegex = []string{v}, "", ""
+		}
+		tools = append(tools, t)
+	}
+	for _, f := range *BgetFilesPool {
+		v, ok := resolved[formatName(f.Name)]
+		if !ok {
+			continue
+		}
+		if v != "" {
+			f.Versions, f.VersionsAPI, f.VersionsRegex = []string{v}, "", ""
+		}
+		files = append(files, f)
+	}
+	return tools, files
+}
+
+// IndentJSON marshals v with indent, version constraints (e.g. >=1.10) are
+// not escaped
+func IndentJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fileSHA256(fn string) (string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// BundleFiles returns the files (with sizes and sha256) under dir
+func BundleFiles(dir string) (files []BundleFile, err error) {
+	err = filepath.Walk(dir, func(fn string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, fn)
+		if err != nil {
+			return err
+		}
+		sum, err := fileSHA256(fn)
+		if err != nil {
+			return err
+		}
+		files = append(files, BundleFile{Path: filepath.ToSlash(rel), Size: info.Size(), SHA256: sum})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, err
+}
+
+// WriteBundle writes the bundle tarball of dir (bundle.json, meta/ and
+// files/), bundle.json is the first entry
+func WriteBundle(dir string, dest string) error {
+	return writeTar(dest, func(tw *tar.Writer) error {
+		if err := tarFile(tw, "bundle.json", filepath.Join(dir, "bundle.json")); err != nil {
+			return err
+		}
+		for _, sub := range []string{"meta", "files"} {
+			err := filepath.Walk(filepath.Join(dir, sub), func(fn string, info os.FileInfo, err error) error {
+				if err != nil || !info.Mode().IsRegular() {
+					return err
+				}
+				rel, err := filepath.Rel(dir, fn)
+				if err != nil {
+					return err
+				}
+				return tarFile(tw, filepath.ToSlash(rel), fn)
+			})
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readBundleManifest reads the first entry (bundle.json) of a bundle
+func readBundleManifest(tr *tar.Reader, fn string) (*BundleManifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	if hdr.Name != "bundle.json" {
+		return nil, fmt.Errorf("%s is not a bget bundle (no bundle.json)", fn)
+	}
+	m := &BundleManifest{}
+	if err := json.NewDecoder(tr).Decode(m); err != nil {
+		return nil, fmt.Errorf("%s: bundle.json: %v", fn, err)
+	}
+	if m.Format > BundleFormat {
+		return nil, fmt.Errorf("%s: unsupported bundle format %d (upgrade bget)", fn, m.Format)
+	}
+	return m, nil
+}
+
+// ExtractBundle restores the files of a bundle into destDir and the catalog
+// and bundle.json into destDir/.bget-bundle, every file is verified by its
+// sha256. Existing files with the same sha256 are kept, other existing
+// files are only replaced if overwrite is true.
+func ExtractBundle(fn string, destDir string, overwrite bool) (m *BundleManifest, err error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	if m, err = readBundleManifest(tr, fn); err != nil {
+		return nil, err
+	}
+	want := make(map[string]BundleFile)
+	for _, bf := range m.Files {
+		want[bf.Path] = bf
+	}
+	metaDir := filepath.Join(destDir, BundleMetaDir)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fn, err)
+		}
+		name := filepath.ToSlash(filepath.Clean(hdr.Name))
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if strings.HasPrefix(name, "../") || filepath.IsAbs(name) {
+			return nil, fmt.Errorf("%s: illegal file path %s", fn, hdr.Name)
+		}
+		if strings.HasPrefix(name, "meta/") {
+			if err := writeBundleFile(tr, filepath.Join(metaDir, name), ""); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		rel := strings.TrimPrefix(name, "files/")
+		bf, ok := want[rel]
+		if !ok || rel == name {
+			return nil, fmt.Errorf("%s: %s is not in bundle.json", fn, hdr.Name)
+		}
+		delete(want, rel)
+		dest := filepath.Join(destDir, filepath.FromSlash(rel))
+		if sum, err := fileSHA256(dest); err == nil {
+			if sum == bf.SHA256 {
+				log.Infof("%s existed.", dest)
+				continue
+			}
+			if !overwrite {
+				return nil, fmt.Errorf("%s existed with another sha256 (use -f to overwrite)", dest)
+			}
+		}
+		if err := writeBundleFile(tr, dest, bf.SHA256); err != nil {
+			return nil, err
+		}
+	}
+	if len(want) > 0 {
+		missing := []string{}
+		for p := range want {
+			missing = append(missing, p)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%s: missing files %s", fn, strings.Join(missing, ", "))
+	}
+	data, err := IndentJSON(m)
+	if err != nil {
+		return nil, err
+	}
+	return m, ioutil.WriteFile(filepath.Join(metaDir, "bundle.json"), data, 0644)
+}
+
+// writeBundleFile writes r to dest via a temporary file, the content is
+// verified if sum is not empty
+func writeBundleFile(r io.Reader, dest string, sum string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp := dest + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); err == nil && sum != "" && got != sum {
+		err = fmt.Errorf("%s: sha256 %s, want %s", dest, got, sum)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
@@ -0,0 +1,122 @@
+package urlpool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBundleItemType(t *testing.T) {
+	tests := map[string]string{
+		"samtools@1.10":                  "key",
+		"reffa-defuse":                   "key",
+		"https://x.org/README.txt":       "url",
+		"SRR8400200":                     "accession",
+		"gse1000":                        "accession",
+		"conda:bioconda/samtools=1.10":   "conda",
+		"docker://quay.io/biocontainers": "image",
+	}
+	for item, want := range tests {
+		if got := BundleItemType(item); got != want {
+			t.Errorf("BundleItemType(%s) = %s, want %s", item, got, want)
+		}
+	}
+}
+
+func TestPinCatalog(t *testing.T) {
+	tools := []BgetToolsURLType{
+		{Name: "samtools", Versions: []string{"1.10", "1.9"}, VersionsAPI: "https://github.com/samtools/samtools"},
+		{Name: "bwa", Versions: []string{"0.7.17"}},
+	}
+	files := []BgetFilesURLType{{Name: "reffa_hg38", Versions: []string{"v1"}}}
+	gotTools, gotFiles := PinCatalog(map[string]string{"samtools": "1.10", "reffa-hg38": ""}, &tools, &files)
+	if len(gotTools) != 1 || !reflect.DeepEqual(gotTools[0].Versions, []string{"1.10"}) || gotTools[0].VersionsAPI != "" {
+		t.Errorf("PinCatalog tools = %+v", gotTools)
+	}
+	if len(gotFiles) != 1 || !reflect.DeepEqual(gotFiles[0].Versions, []string{"v1"}) {
+		t.Errorf("PinCatalog files = %+v", gotFiles)
+	}
+	if !reflect.DeepEqual(tools[0].Versions, []string{"1.10", "1.9"}) {
+		t.Error("PinCatalog changed the pool")
+	}
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for fn, content := range files {
+		fn = filepath.Join(dir, fn)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bget-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stage := filepath.Join(dir, "stage")
+	writeTestFiles(t, stage, map[string]string{
+		"files/samtools/samtools-1.10.tar.bz2": "samtools",
+		"files/README.txt":                     "readme",
+		"meta/default.json":                    `{"tools": ["tools.json"]}`,
+		"meta/tools.json":                      "[]",
+	})
+	m := BundleManifest{Format: BundleFormat, Items: []BundleItem{{Input: "samtools@1.10", Type: "key", Key: "samtools", Version: "1.10"}}}
+	if m.Files, err = BundleFiles(filepath.Join(stage, "files")); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 2 || m.Files[1].Path != "samtools/samtools-1.10.tar.bz2" || m.Files[1].Size != 8 {
+		t.Fatalf("BundleFiles = %+v", m.Files)
+	}
+	data, _ := IndentJSON(m)
+	writeTestFiles(t, stage, map[string]string{"bundle.json": string(data)})
+	bundle := filepath.Join(dir, "tools.tar")
+	if err := WriteBundle(stage, bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(dir, "offline")
+	got, err := ExtractBundle(bundle, dest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Items, m.Items) {
+		t.Errorf("ExtractBundle items = %+v", got.Items)
+	}
+	for _, fn := range []string{"samtools/samtools-1.10.tar.bz2", "README.txt", BundleMetaDir + "/meta/tools.json", BundleMetaDir + "/bundle.json"} {
+		if _, err := os.Stat(filepath.Join(dest, fn)); err != nil {
+			t.Errorf("missing %s: %v", fn, err)
+		}
+	}
+	// existing files with the same sha256 are kept, others need overwrite
+	if _, err := ExtractBundle(bundle, dest, false); err != nil {
+		t.Errorf("reinstall: %v", err)
+	}
+	writeTestFiles(t, dest, map[string]string{"README.txt": "changed"})
+	if _, err := ExtractBundle(bundle, dest, false); err == nil || !strings.Contains(err.Error(), "README.txt") {
+		t.Errorf("expected an error of the changed file, got %v", err)
+	}
+	if _, err := ExtractBundle(bundle, dest, true); err != nil {
+		t.Errorf("overwrite: %v", err)
+	}
+
+	// files changed after bundle.json was written fail the sha256 check
+	writeTestFiles(t, stage, map[string]string{"files/README.txt": "tampered"})
+	if err := WriteBundle(stage, bundle); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExtractBundle(bundle, filepath.Join(dir, "offline2"), false); err == nil || !strings.Contains(err.Error(), "sha256") {
+		t.Errorf("expected a sha256 error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "offline2", "README.txt")); err == nil {
+		t.Error("the tampered file is restored")
+	}
+}
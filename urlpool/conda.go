@@ -0,0 +1,533 @@
+package urlpool
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CondaBaseURL is the base URL of conda channels
+var CondaBaseURL = "https://conda.anaconda.org"
+
+// CondaDefaultChannel is the channel of conda: keys without a channel
+const CondaDefaultChannel = "conda-forge"
+
+// condaPlaceholder is the default prefix placeholder of conda packages
+const condaPlaceholder = "/opt/anaconda1anaconda2anaconda3"
+
+// CondaSpec is a conda: key, e.g. conda:bioconda/samtools=1.10,
+// conda:samtools>=1.10 or conda:bioconda/samtools=1.10=h2e538c0_3
+type CondaSpec struct {
+	Channel string
+	Name    string
+	// Op is = (version prefix, e.g. 1.10 matches 1.10.2), == (exact) or
+	// empty (a constraint, e.g. >=1.10,<2)
+	Op      string
+	Version string
+	Build   string
+}
+
+// CondaPackage is a package record of repodata.json
+type CondaPackage struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Build       string   `json:"build"`
+	BuildNumber int      `json:"build_number"`
+	Depends     []string `json:"depends"`
+	Sha256      string   `json:"sha256"`
+	Size        int64    `json:"size"`
+	Subdir      string   `json:"subdir"`
+	Channel     string   `json:"channel,omitempty"`
+	FileName    string   `json:"fn,omitempty"`
+	URL         string   `json:"url,omitempty"`
+}
+
+type condaRepodata struct {
+	Packages      map[string]CondaPackage `json:"packages"`
+	PackagesConda map[string]CondaPackage `json:"packages.conda"`
+}
+
+// IsCondaKey returns true if key is a conda: key
+func IsCondaKey(key string) bool {
+	return strings.HasPrefix(strings.TrimSpace(key), "conda:")
+}
+
+// JoinCondaConstraints rejoins the conda: keys split by the "," of their
+// constraints, e.g. [conda:htslib>=1.10 <1.11] => [conda:htslib>=1.10,<1.11]
+func JoinCondaConstraints(keys []string) (joined []string) {
+	for _, k := range keys {
+		n := len(joined)
+		if n > 0 && IsCondaKey(joined[n-1]) && strings.IndexAny(strings.TrimSpace(k), "<>=!") == 0 {
+			joined[n-1] += "," + strings.TrimSpace(k)
+			continue
+		}
+		joined = append(joined, k)
+	}
+	return joined
+}
+
+// ParseCondaSpec parses a conda: key
+func ParseCondaSpec(key string) (spec CondaSpec, err error) {
+	s := strings.TrimPrefix(strings.TrimSpace(key), "conda:")
+	end := strings.IndexAny(s, "=<>!~ ")
+	if end < 0 {
+		end = len(s)
+	}
+	name, rest := s[:end], strings.TrimSpace(s[end:])
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		spec.Channel, name = name[:i], name[i+1:]
+	}
+	if spec.Channel == "" {
+		spec.Channel = CondaDefaultChannel
+	}
+	spec.Name = strings.ToLower(name)
+	if spec.Name == "" {
+		return spec, fmt.Errorf("malformed conda key %s (e.g. conda:bioconda/samtools=1.10)", key)
+	}
+	switch {
+	case strings.HasPrefix(rest, "=="):
+		spec.Op, rest = "==", rest[2:]
+	case strings.HasPrefix(rest, "="):
+		spec.Op, rest = "=", rest[1:]
+	}
+	if i := strings.Index(rest, "="); i > 0 && spec.Op != "" {
+		rest, spec.Build = rest[:i], rest[i+1:]
+	}
+	spec.Version = strings.TrimSuffix(strings.TrimSuffix(rest, "*"), ".")
+	if spec.Op == "" && spec.Version != "" && !IsVersionConstraint(spec.Version) {
+		return spec, fmt.Errorf("malformed version %s of conda key %s", spec.Version, key)
+	}
+	return spec, nil
+}
+
+// Match returns true if the version and build of pkg matched spec
+func (spec CondaSpec) Match(pkg CondaPackage) (bool, error) {
+	if pkg.Name != spec.Name {
+		return false, nil
+	}
+	if spec.Build != "" {
+		if ok, _ := path.Match(spec.Build, pkg.Build); !ok {
+			return false, nil
+		}
+	}
+	switch {
+	case spec.Version == "":
+		return true, nil
+	case spec.Op == "==":
+		return pkg.Version == spec.Version, nil
+	case spec.Op == "=":
+		return pkg.Version == spec.Version || strings.HasPrefix(pkg.Version, spec.Version+"."), nil
+	}
+	return MatchConstraint(pkg.Version, spec.Version)
+}
+
+// CondaSubdir returns the conda subdir of OS and arch, e.g. linux-64
+func CondaSubdir(ostype string, arch string) string {
+	subdirs := map[string]string{
+		"Linux/amd64": "linux-64", "Linux/arm64": "linux-aarch64", "Linux/ppc64le": "linux-ppc64le",
+		"Linux/s390x": "linux-s390x", "Mac/amd64": "osx-64", "Mac/arm64": "osx-arm64",
+		"Win/amd64": "win-64", "Win/386": "win-32",
+	}
+	return subdirs[NormOS(ostype)+"/"+NormArch(arch)]
+}
+
+func condaChannelURL(channel string) string {
+	if strings.Contains(channel, "://") {
+		return strings.TrimSuffix(channel, "/")
+	}
+	return strings.TrimSuffix(CondaBaseURL, "/") + "/" + channel
+}
+
+// condaRepodataOf fetches repodata.json.bz2 (or repodata.json) of a
+// channel subdir, the responses are cached in VersionCache
+func condaRepodataOf(channel string, subdir string) (*condaRepodata, error) {
+	client := versionHTTPClient(&http.Client{Timeout: 10 * time.Minute})
+	base := condaChannelURL(channel) + "/" + subdir + "/"
+	var body []byte
+	for _, fn := range []string{"repodata.json.bz2", "repodata.json"} {
+		resp, err := client.Get(base + fn)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			if resp.StatusCode == http.StatusNotFound {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get %s%s: %s", base, fn, resp.Status)
+		}
+		if strings.HasSuffix(fn, ".bz2") {
+			if data, err = ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(data))); err != nil {
+				return nil, fmt.Errorf("%s%s: %v", base, fn, err)
+			}
+		}
+		body = data
+		break
+	}
+	if body == nil {
+		return nil, fmt.Errorf("no repodata.json of %s/%s found", channel, subdir)
+	}
+	repodata := &condaRepodata{}
+	if err := json.Unmarshal(body, repodata); err != nil {
+		return nil, fmt.Errorf("%srepodata.json: %v", base, err)
+	}
+	return repodata, nil
+}
+
+// ResolveCondaPackage returns the newest package (highest version, then
+// build number, .conda preferred) of the channel matched spec in the subdir
+// of OS and arch or noarch
+func ResolveCondaPackage(spec CondaSpec, ostype string, arch string) (best CondaPackage, err error) {
+	subdir := CondaSubdir(ostype, arch)
+	if subdir == "" {
+		return best, fmt.Errorf("no conda subdir of %s/%s", ostype, arch)
+	}
+	candidates := []CondaPackage{}
+	versions := make(map[string]bool)
+	for _, sd := range []string{subdir, "noarch"} {
+		repodata, err := condaRepodataOf(spec.Channel, sd)
+		if err != nil {
+			if sd == "noarch" {
+				log.Warn(err)
+				continue
+			}
+			return best, err
+		}
+		for _, pkgs := range []map[string]CondaPackage{repodata.Packages, repodata.PackagesConda} {
+			for fn, pkg := range pkgs {
+				if pkg.Name != spec.Name {
+					continue
+				}
+				versions[pkg.Version] = true
+				ok, err := spec.Match(pkg)
+				if err != nil {
+					return best, err
+				}
+				if !ok {
+					continue
+				}
+				pkg.Channel, pkg.FileName, pkg.Subdir = spec.Channel, fn, sd
+				pkg.URL = condaChannelURL(spec.Channel) + "/" + sd + "/" + fn
+				candidates = append(candidates, pkg)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		if len(versions) == 0 {
+			return best, fmt.Errorf("no package %s in %s (%s, noarch)", spec.Name, spec.Channel, subdir)
+		}
+		available := []string{}
+		for v := range versions {
+			available = append(available, v)
+		}
+		available = SortVersions(available)
+		if len(available) > 10 {
+			available = available[:10]
+		}
+		return best, fmt.Errorf("no version of %s/%s matches %s%s (available: %s)", spec.Channel, spec.Name, spec.Op,
+			spec.Version, strings.Join(available, ", "))
+	}
+	// .conda needs the zstd command, use .tar.bz2 if both are available
+	_, err = exec.LookPath("zstd")
+	preferConda := err == nil
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if c := CompareVersions(a.Version, b.Version); c != 0 {
+			return c > 0
+		}
+		if a.BuildNumber != b.BuildNumber {
+			return a.BuildNumber > b.BuildNumber
+		}
+		if strings.HasSuffix(a.FileName, ".conda") != strings.HasSuffix(b.FileName, ".conda") {
+			return strings.HasSuffix(a.FileName, ".conda") == preferConda
+		}
+		return a.FileName < b.FileName
+	})
+	return candidates[0], nil
+}
+
+// VerifyCondaPackage checks the size and sha256 of a downloaded package
+func VerifyCondaPackage(fn string, pkg CondaPackage) error {
+	asset := ReleaseAsset{Name: pkg.FileName, Size: pkg.Size}
+	if pkg.Sha256 != "" {
+		asset.Digest = "sha256:" + pkg.Sha256
+	}
+	return VerifyAsset(fn, asset)
+}
+
+// unpackConda extracts a .tar.bz2 or .conda package (files and info/) into dir
+func unpackConda(fn string, dir string) error {
+	if strings.HasSuffix(fn, ".tar.bz2") {
+		f, err := os.Open(fn)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		log.Infof("Uncompressing %s => %s", fn, dir)
+		return untar(bzip2.NewReader(f), dir)
+	}
+	if !strings.HasSuffix(fn, ".conda") {
+		return fmt.Errorf("%s is not a conda package (.tar.bz2 or .conda)", fn)
+	}
+	zr, err := zip.OpenReader(fn)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".tar.zst") || strings.Contains(f.Name, "/") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		log.Infof("Uncompressing %s:%s => %s", fn, f.Name, dir)
+		err = untarZstd(rc, dir)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s:%s: %v", fn, f.Name, err)
+		}
+	}
+	return nil
+}
+
+// untarZstd extracts a .tar.zst stream into dir, the stream is decoded by
+// the zstd command
+func untarZstd(r io.Reader, dir string) error {
+	zstdPath, err := exec.LookPath("zstd")
+	if err != nil {
+		return fmt.Errorf("zstd is required to unpack .conda packages: %v", err)
+	}
+	cmd := exec.Command(zstdPath, "-dc")
+	cmd.Stdin = r
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	err = untar(stdout, dir)
+	io.Copy(ioutil.Discard, stdout)
+	if werr := cmd.Wait(); werr != nil && err == nil {
+		err = fmt.Errorf("zstd: %v %s", werr, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+// withinDir returns true if p is dir or in dir (both are cleaned)
+func withinDir(dir string, p string) bool {
+	return p == dir || strings.HasPrefix(p, dir+string(os.PathSeparator))
+}
+
+// tarDest returns the path of an archive entry in dir, it fails if the
+// entry is out of dir or would be written through a symlink in dir (e.g.
+// one created by an earlier entry)
+func tarDest(dir string, name string) (string, error) {
+	dest := filepath.Join(dir, name)
+	if !withinDir(dir, dest) {
+		return "", fmt.Errorf("illegal file path in archive: %s", name)
+	}
+	rel, _ := filepath.Rel(dir, dest)
+	p := dir
+	for _, part := range strings.Split(filepath.Dir(rel), string(os.PathSeparator)) {
+		if part == "." {
+			break
+		}
+		p = filepath.Join(p, part)
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("illegal file path in archive (through the symlink %s): %s", filepath.Base(p), name)
+		}
+	}
+	return dest, nil
+}
+
+// untar extracts a tar stream into dir, entries out of dir, links to the
+// files out of dir and entries under symlinks are rejected
+func untar(r io.Reader, dir string) error {
+	dir = filepath.Clean(dir)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		dest, err := tarDest(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(dest, 0755)
+		case tar.TypeSymlink:
+			if filepath.IsAbs(hdr.Linkname) || !withinDir(dir, filepath.Join(filepath.Dir(dest), hdr.Linkname)) {
+				return fmt.Errorf("illegal link in archive: %s -> %s", hdr.Name, hdr.Linkname)
+			}
+			if err = os.MkdirAll(filepath.Dir(dest), 0755); err == nil {
+				os.Remove(dest)
+				err = os.Symlink(hdr.Linkname, dest)
+			}
+		case tar.TypeLink:
+			var target string
+			if filepath.IsAbs(hdr.Linkname) {
+				return fmt.Errorf("illegal link in archive: %s -> %s", hdr.Name, hdr.Linkname)
+			}
+			if target, err = tarDest(dir, hdr.Linkname); err != nil {
+				return err
+			}
+			if err = os.MkdirAll(filepath.Dir(dest), 0755); err == nil {
+				os.Remove(dest)
+				err = os.Link(target, dest)
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return err
+			}
+			// a new file, not the target of an existed (hard or symbolic) link
+			if err = os.Remove(dest); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			var out *os.File
+			if out, err = os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// condaPrefixFiles returns the text files (relative) of info/has_prefix
+// and their placeholders, binary files are skipped
+func condaPrefixFiles(dir string) (files map[string]string, binary int) {
+	files = make(map[string]string)
+	f, err := os.Open(filepath.Join(dir, "info", "has_prefix"))
+	if err != nil {
+		return files, 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch len(fields) {
+		case 1:
+			files[fields[0]] = condaPlaceholder
+		case 3:
+			if fields[1] == "binary" {
+				binary++
+				continue
+			}
+			files[fields[2]] = fields[0]
+		}
+	}
+	return files, binary
+}
+
+// ExtractCondaPackage extracts a downloaded package into prefix like conda
+// (the placeholder prefix of text files is replaced), the installed files
+// are recorded in <prefix>/conda-meta/<name>-<version>-<build>.json
+func ExtractCondaPackage(fn string, pkg CondaPackage, prefix string) (files []string, err error) {
+	if err := os.MkdirAll(prefix, 0755); err != nil {
+		return nil, err
+	}
+	if prefix, err = filepath.Abs(prefix); err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempDir(prefix, ".bget-conda-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := unpackConda(fn, tmp); err != nil {
+		return nil, err
+	}
+	prefixFiles, binary := condaPrefixFiles(tmp)
+	if binary > 0 {
+		log.Warnf("%s: %d binary files with the placeholder prefix are not relocated.", pkg.FileName, binary)
+	}
+	for rel, placeholder := range prefixFiles {
+		target, err := tarDest(tmp, rel)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := os.Lstat(target)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		data, err := ioutil.ReadFile(target)
+		if err != nil {
+			continue
+		}
+		if err := ioutil.WriteFile(target, bytes.ReplaceAll(data, []byte(placeholder), []byte(prefix)), fi.Mode()); err != nil {
+			return nil, err
+		}
+	}
+	err = filepath.Walk(tmp, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(tmp, p)
+		if rel == "." || fi.IsDir() {
+			if rel == "info" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		dest := filepath.Join(prefix, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		os.Remove(dest)
+		if err := os.Rename(p, dest); err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return files, err
+	}
+	meta := struct {
+		CondaPackage
+		Files []string `json:"files"`
+	}{pkg, files}
+	data, _ := json.MarshalIndent(meta, "", "  ")
+	metaDir := filepath.Join(prefix, "conda-meta")
+	if err := os.MkdirAll(metaDir, 0755); err != nil {
+		return files, err
+	}
+	return files, ioutil.WriteFile(filepath.Join(metaDir, fmt.Sprintf("%s-%s-%s.json", pkg.Name, pkg.Version, pkg.Build)), data, 0644)
+}
@@ -0,0 +1,248 @@
+package urlpool
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseCondaSpec(t *testing.T) {
+	tests := []struct {
+		key  string
+		want CondaSpec
+	}{
+		{"conda:samtools", CondaSpec{Channel: "conda-forge", Name: "samtools"}},
+		{"conda:bioconda/samtools=1.10", CondaSpec{Channel: "bioconda", Name: "samtools", Op: "=", Version: "1.10"}},
+		{"conda:bioconda/samtools==1.10=h2e538c0_3", CondaSpec{Channel: "bioconda", Name: "samtools", Op: "==", Version: "1.10", Build: "h2e538c0_3"}},
+		{"conda:htslib>=1.10,<1.11", CondaSpec{Channel: "conda-forge", Name: "htslib", Version: ">=1.10,<1.11"}},
+		{"conda:https://x.org/ch/BWA=0.7.*", CondaSpec{Channel: "https://x.org/ch", Name: "bwa", Op: "=", Version: "0.7"}},
+	}
+	for _, tt := range tests {
+		if got, err := ParseCondaSpec(tt.key); err != nil || got != tt.want {
+			t.Errorf("ParseCondaSpec(%s) = %+v, %v, want %+v", tt.key, got, err, tt.want)
+		}
+	}
+	if _, err := ParseCondaSpec("conda:bioconda/"); err == nil {
+		t.Error("expected an error of an empty name")
+	}
+	got := JoinCondaConstraints([]string{"bwa", "conda:htslib>=1.10", "<1.11", "samtools"})
+	if want := []string{"bwa", "conda:htslib>=1.10,<1.11", "samtools"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("JoinCondaConstraints = %v, want %v", got, want)
+	}
+}
+
+func TestCondaSpecMatch(t *testing.T) {
+	pkg := CondaPackage{Name: "samtools", Version: "1.10.2", Build: "h2e538c0_3"}
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"conda:samtools=1.10", true},
+		{"conda:samtools=1.1", false},
+		{"conda:samtools==1.10", false},
+		{"conda:samtools>=1.10,<1.11", true},
+		{"conda:samtools=1.10=h2e*", true},
+		{"conda:samtools=1.10=h1*", false},
+		{"conda:htslib", false},
+	}
+	for _, tt := range tests {
+		spec, _ := ParseCondaSpec(tt.key)
+		if got, err := spec.Match(pkg); err != nil || got != tt.want {
+			t.Errorf("%s Match = %v, %v, want %v", tt.key, got, err, tt.want)
+		}
+	}
+	if got := CondaSubdir("darwin", "arm64"); got != "osx-arm64" {
+		t.Errorf("CondaSubdir = %s", got)
+	}
+}
+
+func TestResolveCondaPackage(t *testing.T) {
+	oldDir, oldBase := VersionCache.Dir, CondaBaseURL
+	defer func() { VersionCache.Dir, CondaBaseURL = oldDir, oldBase }()
+	VersionCache.Dir = ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bioconda/linux-64/repodata.json":
+			fmt.Fprint(w, `{"packages": {
+  "samtools-1.9-h0_1.tar.bz2": {"name": "samtools", "version": "1.9", "build": "h0_1", "build_number": 1},
+  "samtools-1.10-h0_0.tar.bz2": {"name": "samtools", "version": "1.10", "build": "h0_0", "build_number": 0},
+  "samtools-1.10-h1_2.tar.bz2": {"name": "samtools", "version": "1.10", "build": "h1_2", "build_number": 2,
+    "depends": ["htslib >=1.10"], "sha256": "abc", "size": 3}}}`)
+		case "/bioconda/noarch/repodata.json":
+			fmt.Fprint(w, `{"packages": {}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	CondaBaseURL = srv.URL
+	spec, _ := ParseCondaSpec("conda:bioconda/samtools=1.10")
+	pkg, err := ResolveCondaPackage(spec, "linux", "amd64")
+	if err != nil || pkg.FileName != "samtools-1.10-h1_2.tar.bz2" || pkg.Subdir != "linux-64" ||
+		pkg.URL != srv.URL+"/bioconda/linux-64/samtools-1.10-h1_2.tar.bz2" || !reflect.DeepEqual(pkg.Depends, []string{"htslib >=1.10"}) {
+		t.Errorf("ResolveCondaPackage = %+v, %v", pkg, err)
+	}
+	spec, _ = ParseCondaSpec("conda:bioconda/samtools=2")
+	if _, err := ResolveCondaPackage(spec, "linux", "amd64"); err == nil || !strings.Contains(err.Error(), "available: 1.10, 1.9") {
+		t.Errorf("expected an error of the available versions, got %v", err)
+	}
+}
+
+type tarEntry struct {
+	name, link, body string
+	typ              byte
+}
+
+func tarOf(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Linkname: e.link, Typeflag: e.typ, Mode: 0644, Size: int64(len(e.body))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	return &buf
+}
+
+func TestUntar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bget-untar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outside := filepath.Join(dir, "outside")
+	os.MkdirAll(outside, 0755)
+	ioutil.WriteFile(filepath.Join(outside, "keep"), []byte("keep"), 0644)
+	tests := map[string][]tarEntry{
+		"parent":          {{name: "../pwned", body: "x", typ: tar.TypeReg}},
+		"symlink abs":     {{name: "lnk", link: outside, typ: tar.TypeSymlink}},
+		"symlink parent":  {{name: "lnk", link: "../outside", typ: tar.TypeSymlink}},
+		"hardlink abs":    {{name: "hl", link: filepath.Join(outside, "keep"), typ: tar.TypeLink}},
+		"hardlink parent": {{name: "hl", link: "../outside/keep", typ: tar.TypeLink}},
+	}
+	for name, entries := range tests {
+		dest := filepath.Join(dir, "dest-"+strings.Replace(name, " ", "-", -1))
+		if err := untar(tarOf(t, entries), dest); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	// an entry under an existed symlink to out of dir
+	dest := filepath.Join(dir, "dest")
+	os.MkdirAll(dest, 0755)
+	os.Symlink(outside, filepath.Join(dest, "lnk"))
+	if err := untar(tarOf(t, []tarEntry{{name: "lnk/pwned", body: "x", typ: tar.TypeReg}}), dest); err == nil {
+		t.Error("expected an error of the entry under a symlink")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned")); err == nil {
+		t.Error("wrote a file out of dir")
+	}
+
+	ok := filepath.Join(dir, "ok")
+	err = untar(tarOf(t, []tarEntry{
+		{name: "lib/", typ: tar.TypeDir},
+		{name: "lib/libz.so.1", body: "libz", typ: tar.TypeReg},
+		{name: "lib/libz.so", link: "libz.so.1", typ: tar.TypeSymlink},
+		{name: "bin/z", link: "../lib/libz.so.1", typ: tar.TypeSymlink},
+		{name: "lib/libz.a", link: "lib/libz.so.1", typ: tar.TypeLink},
+		{name: "lib/libz.a", body: "new", typ: tar.TypeReg},
+	}), ok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(ok, "bin", "z")); string(data) != "libz" {
+		t.Errorf("bin/z = %q, the hardlink target is overwritten?", data)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(ok, "lib", "libz.a")); string(data) != "new" {
+		t.Errorf("lib/libz.a = %q", data)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(outside, "keep")); string(data) != "keep" {
+		t.Errorf("outside/keep = %q", data)
+	}
+}
+
+func TestExtractCondaPackage(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd is not installed")
+	}
+	dir, err := ioutil.TempDir("", "bget-conda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	zstdOf := func(entries []tarEntry) []byte {
+		cmd := exec.Command("zstd", "-c")
+		cmd.Stdin = tarOf(t, entries)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	conda := func(fn string, pkg []tarEntry) string {
+		fn = filepath.Join(dir, fn)
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for name, entries := range map[string][]tarEntry{
+			"info-x.tar.zst": {{name: "info/has_prefix", body: "/opt/anaconda1anaconda2anaconda3 text bin/x-config\n", typ: tar.TypeReg}},
+			"pkg-x.tar.zst":  pkg,
+		} {
+			w, _ := zw.Create(name)
+			w.Write(zstdOf(entries))
+		}
+		zw.Close()
+		if err := ioutil.WriteFile(fn, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return fn
+	}
+	prefix := filepath.Join(dir, "prefix")
+	pkg := CondaPackage{Name: "x", Version: "1.0", Build: "0", FileName: "x-1.0-0.conda"}
+	fn := conda("x-1.0-0.conda", []tarEntry{
+		{name: "bin/x-config", body: "prefix=/opt/anaconda1anaconda2anaconda3\n", typ: tar.TypeReg},
+		{name: "lib/libx.so", link: "libx.so.1", typ: tar.TypeSymlink},
+	})
+	files, err := ExtractCondaPackage(fn, pkg, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	if !reflect.DeepEqual(files, []string{"bin/x-config", "lib/libx.so"}) {
+		t.Errorf("files = %v", files)
+	}
+	abs, _ := filepath.Abs(prefix)
+	if data, _ := ioutil.ReadFile(filepath.Join(prefix, "bin", "x-config")); string(data) != "prefix="+abs+"\n" {
+		t.Errorf("bin/x-config = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(prefix, "conda-meta", "x-1.0-0.json")); err != nil {
+		t.Error(err)
+	}
+
+	outside := filepath.Join(dir, "outside")
+	fn = conda("evil-1.0-0.conda", []tarEntry{
+		{name: "lnk", link: outside, typ: tar.TypeSymlink},
+		{name: "lnk/pwned", body: "x", typ: tar.TypeReg},
+	})
+	os.MkdirAll(outside, 0755)
+	if _, err := ExtractCondaPackage(fn, pkg, prefix); err == nil {
+		t.Error("expected an error of the symlink out of prefix")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned")); err == nil {
+		t.Error("wrote a file out of prefix")
+	}
+}
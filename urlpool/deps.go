@@ -0,0 +1,106 @@
+package urlpool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitRequire splits a Requires item (or a key) into the key name and the
+// version constraint, e.g. htslib@>=1.10 => htslib, >=1.10
+func SplitRequire(item string) (key string, constraint string) {
+	item = strings.TrimSpace(item)
+	if i := strings.IndexAny(item, "@%#"); i >= 0 {
+		key = item[:i]
+	} else {
+		key = item
+	}
+	if i := strings.Index(item, "@"); i >= 0 {
+		constraint = strings.TrimSpace(strings.SplitN(item[i+1:], "#", 2)[0])
+	}
+	return formatName(key), constraint
+}
+
+func formatName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "_", "-")
+}
+
+// keyRequires returns the Requires of a key, ok is false if the key is not
+// in the pools
+func keyRequires(name string, BgetToolsPool *[]BgetToolsURLType, BgetFilesPool *[]BgetFilesURLType) (requires []string, ok bool) {
+	for _, t := range *BgetToolsPool {
+		if formatName(t.Name) == name {
+			requires, ok = append(requires, t.Requires...), true
+		}
+	}
+	for _, f := range *BgetFilesPool {
+		if formatName(f.Name) == name {
+			requires, ok = append(requires, f.Requires...), true
+		}
+	}
+	return requires, ok
+}
+
+// ResolveRequires expands keys (e.g. samtools@1.10) with their Requires
+// recursively into a download plan, the required keys are placed before the
+// keys requiring them. The constraint of a key given in keys (or the first
+// required) is used if a key is required more than once. It fails on
+// unknown required keys and dependency cycles.
+func ResolveRequires(keys []string, BgetToolsPool *[]BgetToolsURLType, BgetFilesPool *[]BgetFilesURLType) (plan []string, err error) {
+	items := make(map[string]string)
+	for _, k := range keys {
+		name, _ := SplitRequire(k)
+		if _, ok := items[name]; !ok {
+			items[name] = strings.TrimSpace(k)
+		}
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	stack := []string{}
+	var visit func(name string, item string, by string) error
+	visit = func(name string, item string, by string) error {
+		if prev, ok := items[name]; ok {
+			if _, c := SplitRequire(item); c != "" && item != prev {
+				log.Warnf("%s requires %s, using %s.", by, item, prev)
+			}
+			item = prev
+		} else {
+			items[name] = item
+		}
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i := range stack {
+				if stack[i] == name {
+					return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(stack[i:], " -> "), name)
+				}
+			}
+		}
+		requires, ok := keyRequires(name, BgetToolsPool, BgetFilesPool)
+		if !ok && by != "" {
+			return fmt.Errorf("%s requires unknown key %s", by, name)
+		}
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, r := range requires {
+			rname, _ := SplitRequire(r)
+			if err := visit(rname, strings.TrimSpace(r), name); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		plan = append(plan, item)
+		return nil
+	}
+	for _, k := range keys {
+		name, _ := SplitRequire(k)
+		if err := visit(name, strings.TrimSpace(k), ""); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,40 @@
+package urlpool
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveRequires(t *testing.T) {
+	tools := []BgetToolsURLType{
+		{Name: "samtools", Requires: []string{"htslib@>=1.10", "zlib"}},
+		{Name: "bcftools", Requires: []string{"htslib@1.9"}},
+		{Name: "htslib", Requires: []string{"zlib", "bzip2"}},
+		{Name: "zlib"},
+		{Name: "bzip2"},
+	}
+	files := []BgetFilesURLType{
+		{Name: "reffa/bundle", Requires: []string{"reffa/genome", "samtools"}},
+		{Name: "reffa/genome"},
+	}
+	plan, err := ResolveRequires([]string{"samtools@1.10", "bcftools"}, &tools, &files)
+	want := []string{"zlib", "bzip2", "htslib@>=1.10", "samtools@1.10", "bcftools"}
+	if err != nil || !reflect.DeepEqual(plan, want) {
+		t.Errorf("got %v %v, want %v", plan, err, want)
+	}
+	plan, err = ResolveRequires([]string{"reffa/bundle", "htslib@1.9"}, &tools, &files)
+	want = []string{"reffa/genome", "zlib", "bzip2", "htslib@1.9", "samtools", "reffa/bundle"}
+	if err != nil || !reflect.DeepEqual(plan, want) {
+		t.Errorf("got %v %v, want %v", plan, err, want)
+	}
+
+	tools = append(tools, BgetToolsURLType{Name: "a", Requires: []string{"b"}}, BgetToolsURLType{Name: "b", Requires: []string{"a@1.0"}},
+		BgetToolsURLType{Name: "c", Requires: []string{"missing"}})
+	if _, err := ResolveRequires([]string{"a"}, &tools, &files); err == nil || !strings.Contains(err.Error(), "a -> b -> a") {
+		t.Errorf("expect cycle error, got %v", err)
+	}
+	if _, err := ResolveRequires([]string{"c"}, &tools, &files); err == nil || !strings.Contains(err.Error(), "unknown key missing") {
+		t.Errorf("expect unknown key error, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package urlpool
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ExportFormats are the formats of bget i export
+var ExportFormats = []string{"markdown", "csv", "snakemake", "nextflow"}
+
+// ExportEntry is one key of a catalog export, Files are the local paths of
+// URLs (the layout of bget i -o)
+type ExportEntry struct {
+	Key         string
+	Version     string
+	Channel     string
+	Description string
+	Tags        []string
+	URLs        []string
+	Files       []string
+}
+
+var exportNameRe = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// ExportName returns the identifier of key used in the Snakemake and
+// Nextflow configs, e.g. reffa_defuse of reffa/defuse
+func ExportName(key string) string {
+	name := strings.Trim(exportNameRe.ReplaceAllString(key, "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// Export writes entries in format (markdown, csv, snakemake or nextflow)
+func Export(w io.Writer, format string, entries []ExportEntry) error {
+	seen := make(map[string]string)
+	for _, e := range entries {
+		name := ExportName(e.Key)
+		if k, ok := seen[name]; ok && (format == "snakemake" || format == "nextflow") {
+			return fmt.Errorf("%s and %s have the same name %s in the config", k, e.Key, name)
+		}
+		seen[name] = e.Key
+	}
+	switch format {
+	case "markdown":
+		return exportMarkdown(w, entries)
+	case "csv":
+		return exportCSV(w, entries)
+	case "snakemake":
+		return exportSnakemake(w, entries)
+	case "nextflow":
+		return exportNextflow(w, entries)
+	}
+	return fmt.Errorf("unknown export format %s (use %s)", format, strings.Join(ExportFormats, ", "))
+}
+
+func markdownCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func exportMarkdown(w io.Writer, entries []ExportEntry) error {
+	var b strings.Builder
+	b.WriteString("| Key | Version | Channel | Description | Tags | URLs |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, e := range entries {
+		urls := []string{}
+		for _, u := range e.URLs {
+			urls = append(urls, "<"+markdownCell(u)+">")
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n", markdownCell(e.Key), markdownCell(e.Version),
+			markdownCell(e.Channel), markdownCell(e.Description), markdownCell(strings.Join(e.Tags, ", ")),
+			strings.Join(urls, "<br>"))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// exportCSV writes one row per URL (one row without URL if a key has none)
+func exportCSV(w io.Writer, entries []ExportEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Key", "Version", "Channel", "Description", "Tags", "URL", "File"})
+	for _, e := range entries {
+		row := []string{e.Key, e.Version, e.Channel, e.Description, strings.Join(e.Tags, ";")}
+		if len(e.URLs) == 0 {
+			cw.Write(append(row, "", ""))
+		}
+		for i, u := range e.URLs {
+			fn := ""
+			if i < len(e.Files) {
+				fn = e.Files[i]
+			}
+			cw.Write(append(row, u, fn))
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func yamlList(b *strings.Builder, name string, values []string) {
+	if len(values) == 0 {
+		fmt.Fprintf(b, "    %s: []\n", name)
+		return
+	}
+	fmt.Fprintf(b, "    %s:\n", name)
+	for _, v := range values {
+		fmt.Fprintf(b, "      - %s\n", strconv.Quote(v))
+	}
+}
+
+// exportSnakemake writes a config of Snakemake (configfile:), the keys are
+// in config["bget"][name]
+func exportSnakemake(w io.Writer, entries []ExportEntry) error {
+	var b strings.Builder
+	b.WriteString("# Generated by bget i export, use it by configfile: and config[\"bget\"][name][\"files\"]\n")
+	if len(entries) == 0 {
+		b.WriteString("bget: {}\n")
+	} else {
+		b.WriteString("bget:\n")
+	}
+	for _, e := range entries {
+		fmt.Fprintf(&b, "  %s:\n", ExportName(e.Key))
+		fmt.Fprintf(&b, "    key: %s\n", strconv.Quote(e.Key))
+		fmt.Fprintf(&b, "    version: %s\n", strconv.Quote(e.Version))
+		fmt.Fprintf(&b, "    channel: %s\n", strconv.Quote(e.Channel))
+		fmt.Fprintf(&b, "    description: %s\n", strconv.Quote(e.Description))
+		yamlList(&b, "tags", e.Tags)
+		yamlList(&b, "urls", e.URLs)
+		yamlList(&b, "files", e.Files)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// groovyString returns s as a single quoted Groovy string (no interpolation)
+func groovyString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return "'" + r.Replace(s) + "'"
+}
+
+func groovyList(values []string) string {
+	items := []string{}
+	for _, v := range values {
+		items = append(items, groovyString(v))
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// exportNextflow writes a params config of Nextflow (includeConfig), the
+// keys are in params.bget.name
+func exportNextflow(w io.Writer, entries []ExportEntry) error {
+	var b strings.Builder
+	b.WriteString("// Generated by bget i export, use it by includeConfig and params.bget.<name>.files\n")
+	b.WriteString("params {\n  bget {\n")
+	for _, e := range entries {
+		fmt.Fprintf(&b, "    %s {\n", ExportName(e.Key))
+		fmt.Fprintf(&b, "      key = %s\n", groovyString(e.Key))
+		fmt.Fprintf(&b, "      version = %s\n", groovyString(e.Version))
+		fmt.Fprintf(&b, "      channel = %s\n", groovyString(e.Channel))
+		fmt.Fprintf(&b, "      description = %s\n", groovyString(e.Description))
+		fmt.Fprintf(&b, "      tags = %s\n", groovyList(e.Tags))
+		fmt.Fprintf(&b, "      urls = %s\n", groovyList(e.URLs))
+		fmt.Fprintf(&b, "      files = %s\n", groovyList(e.Files))
+		b.WriteString("    }\n")
+	}
+	b.WriteString("  }\n}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,53 @@
+package urlpool
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	entries := []ExportEntry{
+		{Key: "samtools", Version: "1.10", Channel: "bget", Description: "Tools for SAM|BAM", Tags: []string{"bam"},
+			URLs:  []string{"https://x.org/samtools-1.10.tar.bz2"},
+			Files: []string{"/data/samtools/samtools-1.10.tar.bz2"}},
+		{Key: "reffa/defuse", Version: "GRCh38", Description: "It's a reference"},
+	}
+	tests := map[string][]string{
+		"markdown": {"| samtools | 1.10 | bget | Tools for SAM\\|BAM | bam | <https://x.org/samtools-1.10.tar.bz2> |\n",
+			"| reffa/defuse | GRCh38 |  | It's a reference |  |  |\n"},
+		"csv": {"Key,Version,Channel,Description,Tags,URL,File\n",
+			"samtools,1.10,bget,Tools for SAM|BAM,bam,https://x.org/samtools-1.10.tar.bz2,/data/samtools/samtools-1.10.tar.bz2\n",
+			"reffa/defuse,GRCh38,,It's a reference,,,\n"},
+		"snakemake": {"bget:\n  samtools:\n    key: \"samtools\"\n    version: \"1.10\"\n",
+			"    files:\n      - \"/data/samtools/samtools-1.10.tar.bz2\"\n", "  reffa_defuse:\n", "    urls: []\n"},
+		"nextflow": {"params {\n  bget {\n    samtools {\n", "      urls = ['https://x.org/samtools-1.10.tar.bz2']\n",
+			"    reffa_defuse {\n", "      description = 'It\\'s a reference'\n", "      files = []\n    }\n  }\n}\n"},
+	}
+	for format, wants := range tests {
+		var buf bytes.Buffer
+		if err := Export(&buf, format, entries); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("Export(%s) = %s, want %q", format, buf.String(), want)
+			}
+		}
+	}
+	if err := Export(&bytes.Buffer{}, "yaml", entries); err == nil {
+		t.Error("expected an error of the unknown format")
+	}
+	entries = append(entries, ExportEntry{Key: "reffa-defuse"})
+	if err := Export(&bytes.Buffer{}, "nextflow", entries); err == nil {
+		t.Error("expected an error of the same names")
+	}
+}
+
+func TestExportName(t *testing.T) {
+	for key, want := range map[string]string{"bwa": "bwa", "reffa/defuse": "reffa_defuse", "db/annovar-hg38": "db_annovar_hg38", "3dgenome": "_3dgenome"} {
+		if got := ExportName(key); got != want {
+			t.Errorf("ExportName(%s) = %s, want %s", key, got, want)
+		}
+	}
+}
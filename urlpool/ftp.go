@@ -0,0 +1,144 @@
+package urlpool
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ftpTimeout is the timeout of FTP connections
+const ftpTimeout = 60 * time.Second
+
+// ftpList returns the LIST output of an anonymous FTP directory
+func ftpList(rawurl string) ([]byte, error) {
+	u, err := neturl.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "21")
+	}
+	conn, err := net.DialTimeout("tcp", host, ftpTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ftpTimeout))
+	c := textproto.NewConn(conn)
+	if _, _, err := c.ReadResponse(220); err != nil {
+		return nil, err
+	}
+	cmd := func(expect int, format string, args ...interface{}) (int, string, error) {
+		if _, err := c.Cmd(format, args...); err != nil {
+			return 0, "", err
+		}
+		return c.ReadResponse(expect)
+	}
+	user, pass := "anonymous", "anonymous@"
+	if u.User != nil {
+		user = u.User.Username()
+		if p, ok := u.User.Password(); ok {
+			pass = p
+		}
+	}
+	code, msg, err := cmd(0, "USER %s", user)
+	if err != nil {
+		return nil, err
+	}
+	switch code {
+	case 230:
+	case 331:
+		if _, _, err := cmd(230, "PASS %s", pass); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("FTP login %s: %d %s", u.Host, code, msg)
+	}
+	if _, _, err := cmd(200, "TYPE I"); err != nil {
+		return nil, err
+	}
+	if dir := u.Path; dir != "" {
+		if _, _, err := cmd(250, "CWD %s", dir); err != nil {
+			return nil, err
+		}
+	}
+	_, msg, err = cmd(227, "PASV")
+	if err != nil {
+		return nil, err
+	}
+	port, err := ftpPasvPort(msg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), strconv.Itoa(port)), ftpTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+	if _, err := c.Cmd("LIST"); err != nil {
+		return nil, err
+	}
+	if code, msg, err := c.ReadResponse(1); err != nil {
+		return nil, fmt.Errorf("LIST %s: %d %s", rawurl, code, msg)
+	}
+	body, err := ioutil.ReadAll(data)
+	if err != nil {
+		return nil, err
+	}
+	data.Close()
+	if _, _, err := c.ReadResponse(2); err != nil {
+		return nil, err
+	}
+	cmd(221, "QUIT")
+	return body, nil
+}
+
+// ftpPasvPort parses the data port of a PASV response, e.g.
+// Entering Passive Mode (130,14,250,7,195,80)
+func ftpPasvPort(msg string) (int, error) {
+	start, end := strings.Index(msg, "("), strings.Index(msg, ")")
+	if start < 0 || end < start {
+		return 0, fmt.Errorf("invalid PASV response %q", msg)
+	}
+	fields := strings.Split(msg[start+1:end], ",")
+	if len(fields) != 6 {
+		return 0, fmt.Errorf("invalid PASV response %q", msg)
+	}
+	p1, err1 := strconv.Atoi(strings.TrimSpace(fields[4]))
+	p2, err2 := strconv.Atoi(strings.TrimSpace(fields[5]))
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("invalid PASV response %q", msg)
+	}
+	return p1*256 + p2, nil
+}
+
+// ftpListNames returns the names of a LIST output (unix format), directory
+// names end with /
+func ftpListNames(data []byte) (names []string) {
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 9 {
+			if len(fields) == 1 {
+				names = append(names, fields[0])
+			}
+			continue
+		}
+		name := strings.Join(fields[8:], " ")
+		if i := strings.Index(name, " -> "); i > 0 {
+			name = name[:i]
+		}
+		if strings.HasPrefix(fields[0], "d") || strings.HasPrefix(fields[0], "l") {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return names
+}
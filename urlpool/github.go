@@ -0,0 +1,182 @@
+package urlpool
+
+import (
+	"context"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v27/github"
+	"golang.org/x/oauth2"
+)
+
+// GitHubHosts maps the hosts of GitHub (and GitHub Enterprise) to their API
+// base URLs, an empty base URL is the public API (github.com) or
+// https://<host>/api/v3/ (Enterprise)
+var GitHubHosts = map[string]string{"github.com": ""}
+
+// githubPerPage is the page size of GitHub API list requests (max 100)
+const githubPerPage = 100
+
+var anonymousOnce sync.Once
+
+// AddGitHubHost registers a GitHub Enterprise host, e.g.
+// AddGitHubHost("git.example.com", "https://git.example.com/api/v3/")
+func AddGitHubHost(host string, apiURL string) {
+	host = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://"))
+	host = strings.TrimSuffix(host, "/")
+	if host != "" {
+		GitHubHosts[host] = apiURL
+	}
+}
+
+// IsGitHubURL returns true if url is a repo URL of GitHubHosts
+func IsGitHubURL(url string) bool {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return false
+	}
+	_, ok := GitHubHosts[u.Host]
+	return ok
+}
+
+// githubToken returns GITHUB_TOKEN (or GITHUB_ENTERPRISE_TOKEN of Enterprise
+// hosts if set)
+func githubToken(host string) string {
+	if host != "github.com" && os.Getenv("GITHUB_ENTERPRISE_TOKEN") != "" {
+		return os.Getenv("GITHUB_ENTERPRISE_TOKEN")
+	}
+	return os.Getenv("GITHUB_TOKEN")
+}
+
+// setGitHubCtx returns the owner, repo and API client of a GitHub repo URL,
+// the client is anonymous (60 requests per hour) without GITHUB_TOKEN
+func setGitHubCtx(url string) (user, repo string, ctx context.Context, client *github.Client, err error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return "", "", nil, nil, err
+	}
+	apiURL, ok := GitHubHosts[u.Host]
+	if !ok {
+		return "", "", nil, nil, fmt.Errorf("%s is not a GitHub host", u.Host)
+	}
+	pathStr := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(pathStr) < 2 || pathStr[0] == "" || pathStr[1] == "" {
+		return "", "", nil, nil, fmt.Errorf("invalid GitHub repo URL %s", url)
+	}
+	user, repo = pathStr[0], strings.TrimSuffix(pathStr[1], ".git")
+	ctx = context.Background()
+	httpClient := versionHTTPClient(nil)
+	if token := githubToken(u.Host); token != "" {
+		ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+		httpClient = versionHTTPClient(oauth2.NewClient(ctx, ts))
+	} else {
+		anonymousOnce.Do(func() {
+			log.Warn("GITHUB_TOKEN is not set, using anonymous GitHub API (60 requests per hour).")
+		})
+	}
+	if u.Host == "github.com" {
+		return user, repo, ctx, github.NewClient(httpClient), nil
+	}
+	if apiURL == "" {
+		apiURL = fmt.Sprintf("https://%s/api/v3/", u.Host)
+	}
+	client, err = github.NewEnterpriseClient(apiURL, apiURL, httpClient)
+	return user, repo, ctx, client, err
+}
+
+// GitHubRefsSpider gets all tags and branches (all pages) of a GitHub repo
+func GitHubRefsSpider(url string) (tags []string, branches []string, err error) {
+	user, repo, ctx, client, err := setGitHubCtx(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	opt := &github.ListOptions{PerPage: githubPerPage}
+	for {
+		vers, resp, err := client.Repositories.ListTags(ctx, user, repo, opt)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get tags of %s/%s: %v", user, repo, err)
+		}
+		for i := range vers {
+			tags = append(tags, vers[i].GetName())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	opt = &github.ListOptions{PerPage: githubPerPage}
+	for {
+		brchs, resp, err := client.Repositories.ListBranches(ctx, user, repo, opt)
+		if err != nil {
+			return tags, branches, fmt.Errorf("failed to get branches of %s/%s: %v", user, repo, err)
+		}
+		for i := range brchs {
+			branches = append(branches, brchs[i].GetName())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	return tags, branches, nil
+}
+
+// GitHubVersionSpider get all tags (newest first) and branch
+func GitHubVersionSpider(url string, includeBranches bool) (versions []string, err error) {
+	tags, branches, err := GitHubRefsSpider(url)
+	versions = SortVersions(tags)
+	if includeBranches {
+		versions = append(versions, branches...)
+	}
+	return versions, err
+}
+
+// GitHubAssetsSpider gets the download URLs of all assets of a release (tag)
+func GitHubAssetsSpider(url, version string) (urls []string, err error) {
+	assets, err := GitHubReleaseAssets(url, version)
+	for _, a := range assets {
+		urls = append(urls, a.URL)
+	}
+	return urls, err
+}
+
+// GitHubRepo is the metadata of a GitHub repo and its latest release
+type GitHubRepo struct {
+	URL         string
+	Name        string
+	Description string
+	Topics      []string
+	// Tag and Assets are of the latest release (empty without releases)
+	Tag    string
+	Assets []ReleaseAsset
+}
+
+// GitHubRepoInfo gets the description, topics and latest release of a repo
+func GitHubRepoInfo(url string) (info GitHubRepo, err error) {
+	user, repo, ctx, client, err := setGitHubCtx(url)
+	if err != nil {
+		return info, err
+	}
+	u, _ := neturl.Parse(url)
+	r, _, err := client.Repositories.Get(ctx, user, repo)
+	if err != nil {
+		return info, fmt.Errorf("failed to get %s/%s: %v", user, repo, err)
+	}
+	info = GitHubRepo{URL: fmt.Sprintf("%s://%s/%s/%s", u.Scheme, u.Host, user, repo), Name: r.GetName(),
+		Description: r.GetDescription(), Topics: r.Topics}
+	rel, resp, err := client.Repositories.GetLatestRelease(ctx, user, repo)
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return info, nil
+		}
+		return info, fmt.Errorf("failed to get the latest release of %s/%s: %v", user, repo, err)
+	}
+	info.Tag = rel.GetTagName()
+	for _, a := range rel.Assets {
+		info.Assets = append(info.Assets, ReleaseAsset{Name: a.GetName(), URL: a.GetBrowserDownloadURL(), Size: int64(a.GetSize())})
+	}
+	return info, nil
+}
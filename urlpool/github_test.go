@@ -0,0 +1,88 @@
+package urlpool
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGitHubRefsSpiderPages(t *testing.T) {
+	os.Unsetenv("GITHUB_TOKEN")
+	oldDir := VersionCache.Dir
+	defer func() { VersionCache.Dir = oldDir }()
+	VersionCache.Dir = ""
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/repos/o/r/tags") && page != "2":
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=2&per_page=100>; rel="next"`, srv.URL, r.URL.Path))
+			fmt.Fprint(w, `[{"name": "v1.1"}, {"name": "v1.0"}]`)
+		case strings.HasSuffix(r.URL.Path, "/repos/o/r/tags"):
+			fmt.Fprint(w, `[{"name": "v0.9"}]`)
+		case strings.HasSuffix(r.URL.Path, "/repos/o/r/branches"):
+			fmt.Fprint(w, `[{"name": "master"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	AddGitHubHost(host, srv.URL+"/api/v3/")
+	defer delete(GitHubHosts, host)
+
+	if !IsGitHubURL(srv.URL + "/o/r") {
+		t.Fatalf("%s is not registered", host)
+	}
+	tags, branches, err := GitHubRefsSpider(srv.URL + "/o/r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tags, ",") != "v1.1,v1.0,v0.9" || strings.Join(branches, ",") != "master" {
+		t.Errorf("tags = %v, branches = %v", tags, branches)
+	}
+	if _, _, err := GitHubRefsSpider(srv.URL + "/o/missing"); err == nil {
+		t.Error("expected an error of missing repo")
+	}
+}
+
+func TestGitHubRepoInfo(t *testing.T) {
+	os.Unsetenv("GITHUB_TOKEN")
+	oldDir := VersionCache.Dir
+	defer func() { VersionCache.Dir = oldDir }()
+	VersionCache.Dir = ""
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/repos/o/Tool":
+			fmt.Fprint(w, `{"name": "Tool", "description": "A tool", "topics": ["ngs"]}`)
+		case "/api/v3/repos/o/Tool/releases/latest":
+			fmt.Fprint(w, `{"tag_name": "v1.0", "assets": [{"name": "tool-v1.0-linux.tar.gz", "size": 3,
+				"browser_download_url": "https://x.org/tool-v1.0-linux.tar.gz"}]}`)
+		case "/api/v3/repos/o/norelease":
+			fmt.Fprint(w, `{"name": "norelease"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	AddGitHubHost(host, srv.URL+"/api/v3/")
+	defer delete(GitHubHosts, host)
+
+	info, err := GitHubRepoInfo(srv.URL + "/o/Tool.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.URL != srv.URL+"/o/Tool" || info.Description != "A tool" || strings.Join(info.Topics, ",") != "ngs" ||
+		info.Tag != "v1.0" || len(info.Assets) != 1 || info.Assets[0].Size != 3 {
+		t.Errorf("GitHubRepoInfo = %+v", info)
+	}
+	if info, err = GitHubRepoInfo(srv.URL + "/o/norelease"); err != nil || info.Tag != "" {
+		t.Errorf("GitHubRepoInfo without releases = %+v, %v", info, err)
+	}
+}
@@ -0,0 +1,97 @@
+package urlpool
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// InstallType declares how bget install builds a tool and which executables
+// are linked into <prefix>/bin
+type InstallType struct {
+	// Build are the shell commands run in the unpacked dir, {{prefix}} is
+	// the install dir of the version and {{src}} is the unpacked source dir
+	Build []string `json:",omitempty"`
+	// Bin are the executables (globs relative to the install dir), default
+	// is the files in bin/ dirs or the executable files of the top dirs
+	Bin []string `json:",omitempty"`
+}
+
+var archiveRe = regexp.MustCompile(`(?i)\.(zip|rar|tar|tgz|tbz2|txz|tar\.(gz|bz2|xz|lz4|sz|zst|br)|gz|bz2|xz|zst)$`)
+
+// IsArchive returns true if fn is an archive or compressed file
+func IsArchive(fn string) bool {
+	return archiveRe.MatchString(fn)
+}
+
+// SourceDir returns the single top dir of an unpacked archive (e.g.
+// bwa-0.7.17/), or dir itself if it holds more than one entry
+func SourceDir(dir string) string {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return dir
+	}
+	entries := []os.FileInfo{}
+	for _, fi := range fis {
+		if !strings.HasPrefix(fi.Name(), ".") {
+			entries = append(entries, fi)
+		}
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name())
+	}
+	return dir
+}
+
+func isExecutable(fn string) bool {
+	fi, err := os.Stat(fn)
+	return err == nil && fi.Mode().IsRegular() && fi.Mode()&0111 != 0
+}
+
+// FindBins returns the executables (absolute paths) of an install dir
+// matched the Bin globs. Without globs, the executables of <dir>/bin and
+// <src>/bin (or the top of src if there are none) are used, src is the
+// SourceDir of the unpacked files.
+func FindBins(dir string, src string, patterns []string) (bins []string, err error) {
+	seen := make(map[string]bool)
+	add := func(fns []string) {
+		for _, fn := range fns {
+			if abs, err := filepath.Abs(fn); err == nil && !seen[abs] && isExecutable(abs) {
+				seen[abs] = true
+				bins = append(bins, abs)
+			}
+		}
+	}
+	for _, p := range patterns {
+		fns, err := filepath.Glob(filepath.Join(dir, p))
+		if err != nil {
+			return nil, fmt.Errorf("malformed Bin pattern %s: %v", p, err)
+		}
+		if len(fns) == 0 {
+			return nil, fmt.Errorf("no files matched Bin pattern %s", p)
+		}
+		add(fns)
+	}
+	if len(patterns) > 0 {
+		return bins, nil
+	}
+	for _, d := range []string{filepath.Join(dir, "bin"), filepath.Join(src, "bin")} {
+		fns, _ := filepath.Glob(filepath.Join(d, "*"))
+		add(fns)
+	}
+	if len(bins) == 0 {
+		fns, _ := filepath.Glob(filepath.Join(src, "*"))
+		add(fns)
+	}
+	sort.Strings(bins)
+	return bins, nil
+}
+
+// ShimScript returns the sh script of a bin/ shim which runs target
+func ShimScript(target string) string {
+	return fmt.Sprintf("#!/bin/sh\n# created by bget install\nexec %s \"$@\"\n", ShellQuote(target))
+}
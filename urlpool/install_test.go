@@ -0,0 +1,46 @@
+package urlpool
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindBins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bget-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "tool-1.0")
+	os.MkdirAll(filepath.Join(src, "scripts"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "tool"), []byte("#!/bin/sh\n"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "README"), []byte(""), 0644)
+	ioutil.WriteFile(filepath.Join(src, "scripts", "run.sh"), []byte("#!/bin/sh\n"), 0755)
+	if SourceDir(dir) != src {
+		t.Errorf("SourceDir: got %s", SourceDir(dir))
+	}
+	os.MkdirAll(filepath.Join(dir, "bin"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "bin", "built"), []byte("#!/bin/sh\n"), 0755)
+	bins, err := FindBins(dir, src, nil)
+	if err != nil || !reflect.DeepEqual(bins, []string{filepath.Join(dir, "bin", "built")}) {
+		t.Errorf("got %v %v", bins, err)
+	}
+	os.RemoveAll(filepath.Join(dir, "bin"))
+	bins, err = FindBins(dir, src, nil)
+	if err != nil || !reflect.DeepEqual(bins, []string{filepath.Join(src, "tool")}) {
+		t.Errorf("got %v %v", bins, err)
+	}
+	bins, err = FindBins(dir, src, []string{"*/scripts/*.sh"})
+	if err != nil || !reflect.DeepEqual(bins, []string{filepath.Join(src, "scripts", "run.sh")}) {
+		t.Errorf("got %v %v", bins, err)
+	}
+	if _, err := FindBins(dir, src, []string{"bin/missing"}); err == nil {
+		t.Error("expect error of unmatched Bin")
+	}
+	if !IsArchive("bwa-0.7.17.tar.bz2") || IsArchive("vcfanno_linux64") {
+		t.Error("IsArchive")
+	}
+}
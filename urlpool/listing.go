@@ -0,0 +1,194 @@
+package urlpool
+
+import (
+	"fmt"
+	"html"
+	neturl "net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultListingRe matches the version-like directories of a listing
+var defaultListingRe = regexp.MustCompile(`^[vV]?(\d+(?:[._]\d+)*)/$`)
+
+var hrefRe = regexp.MustCompile(`(?i)href\s*=\s*["']?([^"' >]+)`)
+
+// ListingProvider extracts versions from an HTTP or FTP directory listing,
+// e.g. "VersionsAPI": "listing:https://ftp.ensembl.org/pub/" and
+// "VersionsRegex": "^release-(\\d+)/$" (the first group is the version).
+// Directory names end with / and the versions are sorted numerically or by
+// date (see CompareVersions).
+type ListingProvider struct {
+	Regex string
+}
+
+// Name is the scheme of the provider in VersionsAPI
+func (ListingProvider) Name() string { return "listing" }
+
+// Match is false, listings are only used by the listing: scheme
+func (ListingProvider) Match(u *neturl.URL) bool { return false }
+
+func (p ListingProvider) regex() (*regexp.Regexp, error) {
+	if p.Regex == "" {
+		return defaultListingRe, nil
+	}
+	re, err := regexp.Compile(p.Regex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VersionsRegex %q: %v", p.Regex, err)
+	}
+	if re.NumSubexp() > 1 {
+		return nil, fmt.Errorf("VersionsRegex %q should have at most one group", p.Regex)
+	}
+	return re, nil
+}
+
+// Validate checks the listing URL and the regex
+func (p ListingProvider) Validate(ref string) error {
+	u, err := neturl.Parse(ref)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ftp") {
+		return fmt.Errorf("%s should be an http(s) or ftp directory URL", ref)
+	}
+	_, err = p.regex()
+	return err
+}
+
+// listingNames returns the entry names of an HTML (or plain text) listing
+func listingNames(body []byte) (names []string) {
+	matches := hrefRe.FindAllStringSubmatch(string(body), -1)
+	if len(matches) == 0 {
+		return strings.Fields(string(body))
+	}
+	for _, m := range matches {
+		href := html.UnescapeString(m[1])
+		if i := strings.IndexAny(href, "?#"); i >= 0 {
+			href = href[:i]
+		}
+		if unescaped, err := neturl.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		dir := strings.HasSuffix(href, "/")
+		href = strings.TrimSuffix(href, "/")
+		if href == "" || href == "." || href == ".." {
+			continue
+		}
+		name := href[strings.LastIndex(href, "/")+1:]
+		if dir {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// Refs gets the versions matched the regex in the directory listing of ref
+func (p ListingProvider) Refs(ref string) (tags []string, branches []string, err error) {
+	if err := p.Validate(ref); err != nil {
+		return nil, nil, err
+	}
+	re, _ := p.regex()
+	var names []string
+	if strings.HasPrefix(ref, "ftp://") {
+		body, err := cachedFetch(ref, func() ([]byte, error) { return ftpList(ref) })
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list %s: %v", ref, err)
+		}
+		names = ftpListNames(body)
+	} else {
+		body, _, err := getVersionAPI(ref, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list %s: %v", ref, err)
+		}
+		names = listingNames(body)
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		m := re.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		v := m[len(m)-1]
+		if v != "" && !seen[v] {
+			seen[v] = true
+			tags = append(tags, v)
+		}
+	}
+	return SortVersions(tags), nil, nil
+}
+
+// renderListing renders the {{var}} of a listing URL or regex (values are
+// quoted) with env and the Default of vars, ok is false if a variable has no
+// value
+func renderListing(s string, quote bool, env map[string]string, vars map[string]BgetVarType) (string, bool) {
+	ok := true
+	s = templateVarRe.ReplaceAllStringFunc(s, func(m string) string {
+		k := templateVarRe.FindStringSubmatch(m)[1]
+		v := env[k]
+		if v == "" {
+			v = vars[k].Default
+		}
+		if v == "" || strings.Contains(v, ",") {
+			ok = false
+		}
+		if quote {
+			return regexp.QuoteMeta(v)
+		}
+		return v
+	})
+	return s, ok
+}
+
+// listVersions renders the {{var}} of VersionsAPI and VersionsRegex (e.g.
+// "^{{builder}}_([^.]+)\\.txt\\.idx\\.gz$") before listing the versions,
+// the static versions are used if a variable has no value
+func listVersions(api string, regex string, urls []string, static []string, env *map[string]string,
+	vars map[string]BgetVarType) (versions []string, fetched bool) {
+	apiNew, ok := renderListing(api, false, *env, vars)
+	regexNew, ok2 := renderListing(regex, true, *env, vars)
+	if !ok || !ok2 {
+		log.Warnf("Missing variables of %s, using the Versions of meta data.", strings.TrimSpace(api+" "+regex))
+		return static, false
+	}
+	return keyVersions(apiNew, regexNew, urls, static, env)
+}
+
+// resolveListedVars sets the variables with ValuesAPI: a given value should
+// be listed, the newest listed value is used if there is no value and Default
+func resolveListedVars(env *map[string]string, vars map[string]BgetVarType) error {
+	names := []string{}
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		def := vars[k]
+		if def.ValuesAPI == "" || ((*env)[k] == "" && def.Default != "") {
+			continue
+		}
+		api, ok := renderListing(def.ValuesAPI, false, *env, vars)
+		regex, ok2 := renderListing(def.ValuesRegex, true, *env, vars)
+		p, ref := FindVersionProvider(api)
+		if _, isListing := p.(ListingProvider); !isListing || !ok || !ok2 {
+			return fmt.Errorf("invalid ValuesAPI %s of %s (use listing:<dir URL>)", def.ValuesAPI, k)
+		}
+		values, _, err := ListingProvider{Regex: regex}.Refs(ref)
+		if err != nil || len(values) == 0 {
+			log.Warnf("No values of %s listed from %s: %v", k, ref, err)
+			continue
+		}
+		if (*env)[k] == "" {
+			(*env)[k] = values[0]
+			continue
+		}
+		for _, v := range splitList((*env)[k]) {
+			listed := false
+			for _, l := range values {
+				listed = listed || l == v
+			}
+			if !listed {
+				return fmt.Errorf("invalid value %q of %s (listed: %s)", v, k, strings.Join(values, ", "))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package urlpool
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestListingProvider(t *testing.T) {
+	oldDir := VersionCache.Dir
+	defer func() { VersionCache.Dir = oldDir }()
+	VersionCache.Dir = ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><a href="../">../</a>
+<a href="release-99/">release-99/</a> <a href="/pub/release-100/">release-100/</a>
+<a href="release-100/">release-100/</a> <a href="current_README">current_README</a>
+<a href="release-98.txt">release-98.txt</a></body></html>`)
+	}))
+	defer srv.Close()
+	p, ref := FindVersionProvider("listing:" + srv.URL + "/pub/")
+	if _, ok := p.(ListingProvider); !ok {
+		t.Fatalf("listing: is not a ListingProvider")
+	}
+	tags, _, err := ListingProvider{Regex: `^release-(\d+)/$`}.Refs(ref)
+	if err != nil || !reflect.DeepEqual(tags, []string{"100", "99"}) {
+		t.Errorf("Refs = %v, %v", tags, err)
+	}
+	if err := (ListingProvider{Regex: `(a)(b)`}).Validate(ref); err == nil {
+		t.Error("expected an error of two groups")
+	}
+}
+
+func TestFTPListNames(t *testing.T) {
+	data := []byte(`drwxr-xr-x   3 ftp anonymous  4096 Apr 14  2017 biosystems.20170414
+drwxr-xr-x   3 ftp anonymous  4096 Apr 21  2017 biosystems.20170421
+lrwxrwxrwx   1 ftp anonymous    19 Apr 21  2017 CURRENT -> biosystems.20170421
+-r--r--r--   1 ftp anonymous  1024 Apr 21  2017 README.txt
+`)
+	want := []string{"biosystems.20170414/", "biosystems.20170421/", "CURRENT/", "README.txt"}
+	if got := ftpListNames(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("ftpListNames = %v, want %v", got, want)
+	}
+	if port, err := ftpPasvPort("227 Entering Passive Mode (130,14,250,7,195,80)."); err != nil || port != 50000 {
+		t.Errorf("ftpPasvPort = %d, %v", port, err)
+	}
+}
+
+func TestListedVersionsAndVars(t *testing.T) {
+	oldDir := VersionCache.Dir
+	defer func() { VersionCache.Dir = oldDir }()
+	VersionCache.Dir = ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/annovar/download/":
+			fmt.Fprint(w, `<a href="hg19_avsnp150.txt.gz">x</a> <a href="hg19_avsnp150.txt.idx.gz">x</a>
+<a href="hg19_clinvar_20180603.txt.idx.gz">x</a> <a href="hg38_avsnp147.txt.idx.gz">x</a>
+<a href="hg38_clinvar_20200316.txt.idx.gz">x</a> <a href="hg38_dbnsfp35a.txt.idx.gz">x</a>`)
+		case "/pub/":
+			fmt.Fprint(w, `<a href="release-99/">x</a> <a href="release-100/">x</a> <a href="README">x</a>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	pool := []BgetFilesURLType{{
+		Name:          "db/annovar",
+		URL:           []string{srv.URL + "/annovar/download/{{builder}}_{{version}}.txt.gz"},
+		Versions:      []string{"avsnp150"},
+		VersionsAPI:   "listing:" + srv.URL + "/annovar/download/",
+		VersionsRegex: `^{{builder}}_([^.]+)\.txt\.idx\.gz$`,
+		Vars:          map[string]BgetVarType{"builder": {Values: []string{"hg19", "hg38"}, Required: true}},
+	}, {
+		Name: "reffa/ensemble",
+		URL:  []string{srv.URL + "/pub/release-{{release}}/Homo_sapiens.{{version}}.fa.gz"},
+		Vars: map[string]BgetVarType{
+			"release": {ValuesAPI: "listing:" + srv.URL + "/pub/", ValuesRegex: `^release-(\d+)/$`},
+			"version": {Default: "GRCh38"},
+		},
+	}}
+	env := map[string]string{"builder": "hg38"}
+	_, _, versions, err := QueryBgetFiles("db/annovar", &env, &pool)
+	if want := []string{"clinvar_20200316", "avsnp147", "dbnsfp35a"}; err != nil || !reflect.DeepEqual(versions, want) {
+		t.Errorf("hg38 versions = %v, %v, want %v", versions, err, want)
+	}
+	// builder is not given, the Versions are used
+	env = map[string]string{}
+	if _, _, versions, _ = QueryBgetFiles("db/annovar", &env, &pool); !reflect.DeepEqual(versions, []string{"avsnp150"}) {
+		t.Errorf("versions without builder = %v", versions)
+	}
+	env = map[string]string{}
+	urls, _, _, err := QueryBgetFiles("reffa/ensemble", &env, &pool)
+	if want := srv.URL + "/pub/release-100/Homo_sapiens.GRCh38.fa.gz"; err != nil || len(urls) != 1 || urls[0] != want {
+		t.Errorf("newest release = %v, %v, want %s", urls, err, want)
+	}
+	env = map[string]string{"release": "97", "version": "GRCh37"}
+	if _, _, _, err = QueryBgetFiles("reffa/ensemble", &env, &pool); err == nil {
+		t.Error("expected an error of a release not listed")
+	}
+}
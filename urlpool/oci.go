@@ -0,0 +1,591 @@
+package urlpool
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DockerHubRegistry is the registry of image refs without a registry host
+const DockerHubRegistry = "registry-1.docker.io"
+
+// media types of image manifests and indexes
+const (
+	MediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+var manifestAccept = strings.Join([]string{MediaTypeOCIManifest, MediaTypeOCIIndex, MediaTypeDockerManifest,
+	MediaTypeDockerList}, ", ")
+
+// ImageRef is a container image reference, e.g.
+// docker://quay.io/biocontainers/bwa:0.7.17--h5bf99c6_8
+type ImageRef struct {
+	// Scheme is docker (images) or oras (artifacts, e.g. Singularity SIF)
+	Scheme     string
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// OCIPlatform is the platform of a manifest in an image index
+type OCIPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// OCIDescriptor describes a manifest, config or layer blob
+type OCIDescriptor struct {
+	MediaType   string            `json:"mediaType,omitempty"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *OCIPlatform      `json:"platform,omitempty"`
+}
+
+// OCIManifest is an image manifest or index (OCI or Docker v2)
+type OCIManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType,omitempty"`
+	Config        OCIDescriptor   `json:"config"`
+	Layers        []OCIDescriptor `json:"layers"`
+	Manifests     []OCIDescriptor `json:"manifests,omitempty"`
+}
+
+// Image is a resolved image manifest of a platform
+type Image struct {
+	Ref      ImageRef
+	Manifest OCIManifest
+	// Raw is the manifest as served, Digest is its sha256 digest
+	Raw    []byte
+	Digest string
+}
+
+var imageSchemes = []string{"docker://", "oras://"}
+
+// IsImageRef returns true if key is a docker:// or oras:// reference
+func IsImageRef(key string) bool {
+	for _, s := range imageSchemes {
+		if strings.HasPrefix(strings.TrimSpace(key), s) {
+			return true
+		}
+	}
+	return false
+}
+
+var imageRepoRe = regexp.MustCompile(`^[a-z0-9]+([._-][a-z0-9]+)*(/[a-z0-9]+([._-][a-z0-9]+)*)*$`)
+
+// ParseImageRef parses a docker:// or oras:// reference, the registry is
+// Docker Hub if the first component is not a host and the tag is latest if
+// neither a tag nor a digest is given
+func ParseImageRef(key string) (ref ImageRef, err error) {
+	s := strings.TrimSpace(key)
+	i := strings.Index(s, "://")
+	if i < 0 || !IsImageRef(s) {
+		return ref, fmt.Errorf("malformed image reference %s (e.g. docker://quay.io/biocontainers/bwa:0.7.17--h5bf99c6_8)", key)
+	}
+	ref.Scheme, s = s[:i], s[i+3:]
+	if j := strings.Index(s, "@"); j >= 0 {
+		s, ref.Digest = s[:j], s[j+1:]
+		if !digestRe.MatchString(ref.Digest) {
+			return ref, fmt.Errorf("malformed digest %s of %s", ref.Digest, key)
+		}
+	}
+	if j := strings.LastIndex(s, ":"); j > strings.LastIndex(s, "/") {
+		s, ref.Tag = s[:j], s[j+1:]
+	}
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry, s = parts[0], parts[1]
+	} else {
+		ref.Registry = DockerHubRegistry
+	}
+	if ref.Registry == "docker.io" || ref.Registry == "index.docker.io" {
+		ref.Registry = DockerHubRegistry
+	}
+	if ref.Registry == DockerHubRegistry && !strings.Contains(s, "/") {
+		s = "library/" + s
+	}
+	ref.Repository = s
+	if !imageRepoRe.MatchString(ref.Repository) {
+		return ref, fmt.Errorf("malformed repository %s of %s", ref.Repository, key)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// Reference returns the digest (if set) or the tag of ref
+func (ref ImageRef) Reference() string {
+	if ref.Digest != "" {
+		return ref.Digest
+	}
+	return ref.Tag
+}
+
+// String returns ref without the scheme, e.g. quay.io/biocontainers/bwa:0.7.17
+func (ref ImageRef) String() string {
+	s := ref.Registry + "/" + ref.Repository
+	if ref.Tag != "" {
+		s += ":" + ref.Tag
+	}
+	if ref.Digest != "" {
+		s += "@" + ref.Digest
+	}
+	return s
+}
+
+// FileName returns the tarball name of ref, e.g. bwa_0.7.17--h5bf99c6_8.tar
+func (ref ImageRef) FileName() string {
+	name := path.Base(ref.Repository)
+	if ref.Tag != "" {
+		name += "_" + ref.Tag
+	} else {
+		name += "_" + strings.TrimPrefix(ref.Digest, "sha256:")[:12]
+	}
+	return name + ".tar"
+}
+
+// registryURL returns the base URL of a registry, localhost registries
+// (e.g. a local stand-in) are plain HTTP like docker does
+func registryURL(registry string) string {
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+	if host == "localhost" || net.ParseIP(host).IsLoopback() {
+		return "http://" + registry
+	}
+	return "https://" + registry
+}
+
+type registryClient struct {
+	ref    ImageRef
+	client *http.Client
+	token  string
+}
+
+func newRegistryClient(ref ImageRef) *registryClient {
+	return &registryClient{ref: ref, client: &http.Client{}}
+}
+
+// authChallenge parses a WWW-Authenticate header, e.g.
+// Bearer realm="https://quay.io/v2/auth",service="quay.io"
+func authChallenge(header string) (scheme string, params map[string]string) {
+	params = make(map[string]string)
+	header = strings.TrimSpace(header)
+	i := strings.Index(header, " ")
+	if i < 0 {
+		return header, params
+	}
+	scheme = header[:i]
+	re := regexp.MustCompile(`(\w+)="([^"]*)"`)
+	for _, m := range re.FindAllStringSubmatch(header[i+1:], -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	return scheme, params
+}
+
+// fetchToken requests an anonymous pull token of the challenge
+func (r *registryClient) fetchToken(header string) error {
+	scheme, params := authChallenge(header)
+	if !strings.EqualFold(scheme, "Bearer") || params["realm"] == "" {
+		return fmt.Errorf("%s: unsupported authentication %s", r.ref.Registry, header)
+	}
+	u, err := neturl.Parse(params["realm"])
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + r.ref.Repository + ":pull"
+	}
+	q.Set("scope", scope)
+	u.RawQuery = q.Encode()
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: token request failed: %s", r.ref.Registry, resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	r.token = token.Token
+	if r.token == "" {
+		r.token = token.AccessToken
+	}
+	return nil
+}
+
+// get requests a /v2/<repository>/ path of the registry, a bearer token is
+// fetched on 401 and the request is retried once
+func (r *registryClient) get(p string, accept string) (*http.Response, error) {
+	u := registryURL(r.ref.Registry) + "/v2/" + r.ref.Repository + "/" + p
+	for retry := 0; ; retry++ {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if r.token != "" {
+			req.Header.Set("Authorization", "Bearer "+r.token)
+		}
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && retry == 0 && resp.Header.Get("WWW-Authenticate") != "" {
+			resp.Body.Close()
+			if err := r.fetchToken(resp.Header.Get("WWW-Authenticate")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+		}
+		return resp, nil
+	}
+}
+
+// digestRe matches the digests of manifests and blobs, they are used in
+// URLs and file paths (blobs/sha256/<hex>) and must not hold anything else
+var digestRe = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// manifest fetches a manifest by tag or digest, the content is verified if
+// reference is a digest
+func (r *registryClient) manifest(reference string) (m OCIManifest, raw []byte, digest string, err error) {
+	resp, err := r.get("manifests/"+reference, manifestAccept)
+	if err != nil {
+		return m, nil, "", err
+	}
+	defer resp.Body.Close()
+	if raw, err = ioutil.ReadAll(resp.Body); err != nil {
+		return m, nil, "", err
+	}
+	digest = sha256Digest(raw)
+	if strings.HasPrefix(reference, "sha256:") && digest != reference {
+		return m, nil, "", fmt.Errorf("manifest %s: digest %s, want %s", reference, digest, reference)
+	}
+	if err = json.Unmarshal(raw, &m); err != nil {
+		return m, nil, "", fmt.Errorf("manifest %s: %v", reference, err)
+	}
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+	if m.SchemaVersion != 2 {
+		return m, nil, "", fmt.Errorf("manifest %s: unsupported schemaVersion %d", reference, m.SchemaVersion)
+	}
+	return m, raw, digest, nil
+}
+
+// imagePlatform returns the OCI os and architecture of OS and arch keys,
+// images of Linux are used on Mac (containers run in a Linux VM)
+func imagePlatform(ostype string, arch string) (string, string) {
+	imageOS := "linux"
+	if NormOS(ostype) == "Win" {
+		imageOS = "windows"
+	}
+	if a := NormArch(arch); a != "" {
+		arch = a
+	}
+	return imageOS, arch
+}
+
+// ResolveImage fetches the manifest of ref, the manifest of the platform is
+// selected if ref is an image index (multi-arch)
+func ResolveImage(ref ImageRef, ostype string, arch string) (img *Image, err error) {
+	r := newRegistryClient(ref)
+	m, raw, digest, err := r.manifest(ref.Reference())
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Manifests) > 0 {
+		imageOS, imageArch := imagePlatform(ostype, arch)
+		platforms := []string{}
+		var selected *OCIDescriptor
+		for i, d := range m.Manifests {
+			if d.Platform == nil {
+				continue
+			}
+			platforms = append(platforms, d.Platform.OS+"/"+d.Platform.Architecture)
+			if d.Platform.OS == imageOS && d.Platform.Architecture == imageArch && selected == nil {
+				selected = &m.Manifests[i]
+			}
+		}
+		if selected == nil {
+			return nil, fmt.Errorf("%s has no image of %s/%s (available: %s)", ref, imageOS, imageArch,
+				strings.Join(platforms, ", "))
+		}
+		if !digestRe.MatchString(selected.Digest) {
+			return nil, fmt.Errorf("%s: malformed digest %q of the %s/%s manifest", ref, selected.Digest, imageOS, imageArch)
+		}
+		if m, raw, digest, err = r.manifest(selected.Digest); err != nil {
+			return nil, err
+		}
+	}
+	if len(m.Layers) == 0 && m.Config.Digest == "" {
+		return nil, fmt.Errorf("%s: manifest %s has neither config nor layers", ref, digest)
+	}
+	if m.Config.Digest != "" && !digestRe.MatchString(m.Config.Digest) {
+		return nil, fmt.Errorf("%s: malformed digest %q of the config", ref, m.Config.Digest)
+	}
+	for _, l := range m.Layers {
+		if !digestRe.MatchString(l.Digest) {
+			return nil, fmt.Errorf("%s: malformed digest %q of a layer", ref, l.Digest)
+		}
+	}
+	return &Image{Ref: ref, Manifest: m, Raw: raw, Digest: digest}, nil
+}
+
+// Blobs returns the config and layers of img
+func (img *Image) Blobs() (blobs []OCIDescriptor) {
+	if img.Manifest.Config.Digest != "" {
+		blobs = append(blobs, img.Manifest.Config)
+	}
+	return append(blobs, img.Manifest.Layers...)
+}
+
+// blobPath returns the path of a blob in an OCI layout dir, digest is a
+// digest of digestRe
+func blobPath(dir string, digest string) string {
+	return filepath.Join(dir, "blobs", strings.Replace(digest, ":", string(os.PathSeparator), 1))
+}
+
+// FetchImageBlobs downloads the config and layers of img into the OCI
+// layout dir (blobs/sha256/<hex>), blobs already present and verified are
+// reused. Every blob is verified by its size and digest.
+func FetchImageBlobs(img *Image, dir string) error {
+	r := newRegistryClient(img.Ref)
+	for _, b := range img.Blobs() {
+		if !digestRe.MatchString(b.Digest) {
+			return fmt.Errorf("%s: malformed digest %q (want sha256:<64 hex>)", img.Ref, b.Digest)
+		}
+		fn := blobPath(dir, b.Digest)
+		asset := ReleaseAsset{Name: b.Digest, Size: b.Size, Digest: b.Digest}
+		if _, err := os.Stat(fn); err == nil && VerifyAsset(fn, asset) == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			return err
+		}
+		log.Infof("Fetching %s (%d bytes) of %s", b.Digest, b.Size, img.Ref)
+		resp, err := r.get("blobs/"+b.Digest, "")
+		if err != nil {
+			return err
+		}
+		tmp := fn + ".part"
+		out, err := os.Create(tmp)
+		if err != nil {
+			resp.Body.Close()
+			return err
+		}
+		_, err = io.Copy(out, resp.Body)
+		resp.Body.Close()
+		out.Close()
+		if err == nil {
+			err = VerifyAsset(tmp, asset)
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("blob %s of %s: %v", b.Digest, img.Ref, err)
+		}
+		if err := os.Rename(tmp, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tarBytes(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Unix(0, 0)}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func tarFile(tw *tar.Writer, name string, fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: time.Unix(0, 0)}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// writeTar writes dest via a temporary file, add writes the entries
+func writeTar(dest string, add func(tw *tar.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp := dest + ".part"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(out)
+	err = add(tw)
+	if cerr := tw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// WriteDockerArchive writes img (blobs fetched into dir) as a docker-archive
+// tarball for docker load (or singularity build x.sif docker-archive://dest)
+func WriteDockerArchive(img *Image, dir string, dest string) error {
+	hexOf := func(digest string) string { return strings.TrimPrefix(digest, "sha256:") }
+	entry := struct {
+		Config   string
+		RepoTags []string
+		Layers   []string
+	}{Config: hexOf(img.Manifest.Config.Digest) + ".json", RepoTags: []string{}}
+	if img.Ref.Tag != "" {
+		repo := img.Ref.Repository
+		if img.Ref.Registry != DockerHubRegistry {
+			repo = img.Ref.Registry + "/" + repo
+		}
+		entry.RepoTags = append(entry.RepoTags, repo+":"+img.Ref.Tag)
+	}
+	for _, l := range img.Manifest.Layers {
+		entry.Layers = append(entry.Layers, hexOf(l.Digest)+"/layer.tar")
+	}
+	return writeTar(dest, func(tw *tar.Writer) error {
+		if err := tarFile(tw, entry.Config, blobPath(dir, img.Manifest.Config.Digest)); err != nil {
+			return err
+		}
+		for i, l := range img.Manifest.Layers {
+			if err := tarFile(tw, entry.Layers[i], blobPath(dir, l.Digest)); err != nil {
+				return err
+			}
+		}
+		manifest, err := json.Marshal([]interface{}{entry})
+		if err != nil {
+			return err
+		}
+		return tarBytes(tw, "manifest.json", manifest)
+	})
+}
+
+// WriteOCILayout writes img (blobs fetched into dir) as an OCI image layout
+// tarball (oci-archive) with the tag as ref name
+func WriteOCILayout(img *Image, dir string, dest string) error {
+	desc := OCIDescriptor{MediaType: img.Manifest.MediaType, Digest: img.Digest, Size: int64(len(img.Raw))}
+	if desc.MediaType == "" {
+		desc.MediaType = MediaTypeOCIManifest
+	}
+	if img.Ref.Tag != "" {
+		desc.Annotations = map[string]string{"org.opencontainers.image.ref.name": img.Ref.Tag}
+	}
+	index, err := json.Marshal(struct {
+		SchemaVersion int             `json:"schemaVersion"`
+		MediaType     string          `json:"mediaType"`
+		Manifests     []OCIDescriptor `json:"manifests"`
+	}{2, MediaTypeOCIIndex, []OCIDescriptor{desc}})
+	if err != nil {
+		return err
+	}
+	return writeTar(dest, func(tw *tar.Writer) error {
+		if err := tarBytes(tw, "oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+			return err
+		}
+		if err := tarBytes(tw, "index.json", index); err != nil {
+			return err
+		}
+		if err := tarBytes(tw, "blobs/sha256/"+strings.TrimPrefix(img.Digest, "sha256:"), img.Raw); err != nil {
+			return err
+		}
+		for _, b := range img.Blobs() {
+			if err := tarFile(tw, "blobs/sha256/"+strings.TrimPrefix(b.Digest, "sha256:"), blobPath(dir, b.Digest)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WriteArtifactFiles copies the layers of an ORAS artifact (e.g. a
+// Singularity SIF) into destDir named by their title annotations
+func WriteArtifactFiles(img *Image, dir string, destDir string) (files []string, err error) {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, err
+	}
+	for _, l := range img.Manifest.Layers {
+		name := l.Annotations["org.opencontainers.image.title"]
+		if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			return files, fmt.Errorf("%s: layer %s has no valid title annotation", img.Ref, l.Digest)
+		}
+		dest := filepath.Join(destDir, name)
+		src, err := os.Open(blobPath(dir, l.Digest))
+		if err != nil {
+			return files, err
+		}
+		out, err := os.Create(dest)
+		if err == nil {
+			_, err = io.Copy(out, src)
+			out.Close()
+		}
+		src.Close()
+		if err != nil {
+			return files, err
+		}
+		files = append(files, dest)
+	}
+	return files, nil
+}
@@ -0,0 +1,177 @@
+package urlpool
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseImageRef(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	tests := []struct {
+		key  string
+		want ImageRef
+	}{
+		{"docker://quay.io/biocontainers/bwa:0.7.17--h5bf99c6_8", ImageRef{"docker", "quay.io", "biocontainers/bwa", "0.7.17--h5bf99c6_8", ""}},
+		{"docker://ubuntu", ImageRef{"docker", DockerHubRegistry, "library/ubuntu", "latest", ""}},
+		{"docker://docker.io/biocontainers/fastqc", ImageRef{"docker", DockerHubRegistry, "biocontainers/fastqc", "latest", ""}},
+		{"docker://localhost:5000/bwa@" + digest, ImageRef{"docker", "localhost:5000", "bwa", "", digest}},
+		{"oras://ghcr.io/org/bwa-sif:0.7.17", ImageRef{"oras", "ghcr.io", "org/bwa-sif", "0.7.17", ""}},
+	}
+	for _, tt := range tests {
+		if got, err := ParseImageRef(tt.key); err != nil || got != tt.want {
+			t.Errorf("ParseImageRef(%s) = %+v, %v, want %+v", tt.key, got, err, tt.want)
+		}
+	}
+	for _, key := range []string{"docker://quay.io/BWA", "docker://bwa@sha256:abc", "https://quay.io/bwa"} {
+		if _, err := ParseImageRef(key); err == nil {
+			t.Errorf("expected an error of %s", key)
+		}
+	}
+	ref, _ := ParseImageRef("docker://quay.io/biocontainers/bwa:0.7.17--h5bf99c6_8")
+	if got := ref.FileName(); got != "bwa_0.7.17--h5bf99c6_8.tar" {
+		t.Errorf("FileName = %s", got)
+	}
+}
+
+// testRegistry serves an image index of linux/amd64 and linux/arm64 with
+// anonymous bearer tokens, corrupt corrupts the layer blob
+func testRegistry(t *testing.T, corrupt *bool) *httptest.Server {
+	config, layer := []byte(`{"architecture":"amd64","os":"linux"}`), []byte("layer-amd64")
+	manifest, _ := json.Marshal(OCIManifest{SchemaVersion: 2, MediaType: MediaTypeOCIManifest,
+		Config: OCIDescriptor{MediaType: "application/vnd.oci.image.config.v1+json", Digest: sha256Digest(config), Size: int64(len(config))},
+		Layers: []OCIDescriptor{{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: sha256Digest(layer), Size: int64(len(layer))}}})
+	index, _ := json.Marshal(OCIManifest{SchemaVersion: 2, MediaType: MediaTypeOCIIndex, Manifests: []OCIDescriptor{
+		{MediaType: MediaTypeOCIManifest, Digest: "sha256:" + strings.Repeat("0", 64), Size: 1, Platform: &OCIPlatform{"arm64", "linux", ""}},
+		{MediaType: MediaTypeOCIManifest, Digest: sha256Digest(manifest), Size: int64(len(manifest)), Platform: &OCIPlatform{"amd64", "linux", ""}},
+	}})
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:biocontainers/bwa:pull" {
+				t.Errorf("unexpected scope %s", r.URL.Query().Get("scope"))
+			}
+			fmt.Fprint(w, `{"token": "t0k3n"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		blobs := map[string][]byte{
+			"/v2/biocontainers/bwa/manifests/0.7.17":                    index,
+			"/v2/biocontainers/bwa/manifests/" + sha256Digest(manifest): manifest,
+			"/v2/biocontainers/bwa/blobs/" + sha256Digest(config):       config,
+			"/v2/biocontainers/bwa/blobs/" + sha256Digest(layer):        layer,
+		}
+		data, ok := blobs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if *corrupt && r.URL.Path == "/v2/biocontainers/bwa/blobs/"+sha256Digest(layer) {
+			data = []byte("layer-arm64")
+		}
+		w.Write(data)
+	}))
+	return srv
+}
+
+func tarNames(t *testing.T, fn string) (names []string, files map[string][]byte) {
+	f, err := os.Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	files = make(map[string][]byte)
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(tr)
+		names, files[hdr.Name] = append(names, hdr.Name), data
+	}
+	sort.Strings(names)
+	return names, files
+}
+
+func TestPullImage(t *testing.T) {
+	corrupt := false
+	srv := testRegistry(t, &corrupt)
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "bget-oci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ref, err := ParseImageRef("docker://" + strings.TrimPrefix(srv.URL, "http://") + "/biocontainers/bwa:0.7.17")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := ResolveImage(ref, "darwin", "x86_64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(img.Manifest.Layers) != 1 || img.Digest != sha256Digest(img.Raw) {
+		t.Fatalf("unexpected image %+v", img)
+	}
+	if _, err := ResolveImage(ref, "linux", "ppc64le"); err == nil || !strings.Contains(err.Error(), "linux/amd64") {
+		t.Errorf("expected an error of the available platforms, got %v", err)
+	}
+	blobDir := filepath.Join(dir, "blobs")
+	if err := FetchImageBlobs(img, blobDir); err != nil {
+		t.Fatal(err)
+	}
+	layerHex := strings.TrimPrefix(img.Manifest.Layers[0].Digest, "sha256:")
+	configHex := strings.TrimPrefix(img.Manifest.Config.Digest, "sha256:")
+	if err := WriteDockerArchive(img, blobDir, filepath.Join(dir, ref.FileName())); err != nil {
+		t.Fatal(err)
+	}
+	names, files := tarNames(t, filepath.Join(dir, ref.FileName()))
+	if want := []string{configHex + ".json", layerHex + "/layer.tar", "manifest.json"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("docker-archive entries = %v, want %v", names, want)
+	}
+	if !strings.Contains(string(files["manifest.json"]), `"RepoTags":["`+ref.Registry+`/biocontainers/bwa:0.7.17"]`) {
+		t.Errorf("unexpected manifest.json %s", files["manifest.json"])
+	}
+	if err := WriteOCILayout(img, blobDir, filepath.Join(dir, "oci.tar")); err != nil {
+		t.Fatal(err)
+	}
+	names, files = tarNames(t, filepath.Join(dir, "oci.tar"))
+	if len(names) != 5 || !strings.Contains(string(files["index.json"]), img.Digest) {
+		t.Errorf("unexpected OCI layout %v %s", names, files["index.json"])
+	}
+
+	for _, d := range []string{"sha256:../../../../tmp/x", "sha256:" + strings.Repeat("A", 64), "sha512:" + layerHex} {
+		bad := *img
+		bad.Manifest.Layers = []OCIDescriptor{{Digest: d, Size: 1}}
+		if err := FetchImageBlobs(&bad, blobDir); err == nil || !strings.Contains(err.Error(), "malformed digest") {
+			t.Errorf("FetchImageBlobs of digest %s = %v", d, err)
+		}
+	}
+
+	corrupt = true
+	os.RemoveAll(blobDir)
+	if err := FetchImageBlobs(img, blobDir); err == nil || !strings.Contains(err.Error(), layerHex) {
+		t.Errorf("expected a digest error, got %v", err)
+	}
+	if _, err := os.Stat(blobPath(blobDir, img.Manifest.Layers[0].Digest)); err == nil {
+		t.Error("the corrupt blob is kept")
+	}
+}
@@ -0,0 +1,81 @@
+package urlpool
+
+import (
+	"sort"
+	"strings"
+)
+
+// OsKeys are the OS keys of BgetToolsURLType.URL
+var OsKeys = []string{"Linux", "Mac", "Win"}
+
+// ArchKeys are the architectures of BgetToolsURLType.URL keys, e.g. "Linux/arm64"
+var ArchKeys = []string{"amd64", "arm64", "386", "arm", "ppc64le", "s390x"}
+
+// NormOS converts GOOS or its aliases (e.g. darwin, macos) into an OS key,
+// an empty string is returned for unknown OS
+func NormOS(s string) string {
+	switch strings.ToLower(s) {
+	case "linux":
+		return "Linux"
+	case "darwin", "mac", "macos", "osx":
+		return "Mac"
+	case "windows", "win":
+		return "Win"
+	}
+	return ""
+}
+
+// NormArch converts GOARCH or uname -m aliases (e.g. x86_64, aarch64) into an
+// arch key, an empty string is returned for unknown arch
+func NormArch(s string) string {
+	switch strings.ToLower(s) {
+	case "amd64", "x86_64", "x64":
+		return "amd64"
+	case "arm64", "aarch64", "armv8":
+		return "arm64"
+	case "386", "i386", "i686", "x86":
+		return "386"
+	case "arm", "armv7", "armv7l", "armv6l":
+		return "arm"
+	case "ppc64le", "s390x":
+		return strings.ToLower(s)
+	}
+	return ""
+}
+
+// URLKeys returns the URL keys of os and arch in order of fallback: the exact
+// "OS/arch", amd64 builds on arm64 Mac and Win (emulated), and the bare OS key
+func URLKeys(os string, arch string) (keys []string) {
+	keys = append(keys, os+"/"+arch)
+	if arch == "arm64" && (os == "Mac" || os == "Win") {
+		keys = append(keys, os+"/amd64")
+	}
+	return append(keys, os)
+}
+
+// SplitURLKey splits a URL key into OS and arch (empty if not set)
+func SplitURLKey(key string) (os string, arch string) {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return key, ""
+}
+
+// SelectURLs returns the URL templates of the first matched key of URLKeys
+func SelectURLs(urls map[string][]string, os string, arch string) (key string, selected []string) {
+	for _, k := range URLKeys(os, arch) {
+		if len(urls[k]) > 0 {
+			return k, urls[k]
+		}
+	}
+	return "", nil
+}
+
+// SortedURLKeys returns the sorted keys of urls
+func SortedURLKeys(urls map[string][]string) (keys []string) {
+	for k := range urls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
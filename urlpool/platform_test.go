@@ -0,0 +1,24 @@
+package urlpool
+
+import "testing"
+
+func TestSelectURLs(t *testing.T) {
+	urls := map[string][]string{
+		"Linux":       {"linux-x64"},
+		"Linux/arm64": {"linux-arm64"},
+		"Mac/amd64":   {"mac-x64"},
+	}
+	for _, v := range []struct{ os, arch, want string }{
+		{"Linux", "arm64", "Linux/arm64"},
+		{"Linux", "amd64", "Linux"},
+		{"Mac", "arm64", "Mac/amd64"},
+		{"Win", "amd64", ""},
+	} {
+		if key, _ := SelectURLs(urls, v.os, v.arch); key != v.want {
+			t.Errorf("%s/%s: got %q, want %q", v.os, v.arch, key, v.want)
+		}
+	}
+	if NormOS("darwin") != "Mac" || NormArch("aarch64") != "arm64" || NormArch("sparc") != "" {
+		t.Error("unexpected platform aliases")
+	}
+}
@@ -0,0 +1,205 @@
+package urlpool
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// VersionProvider fetches the versions of a key from a release channel
+// (e.g. GitHub tags, PyPI releases). A provider is selected by the URL of
+// VersionsAPI (Match) or an explicit scheme, e.g. pypi:multiqc,
+// gitlab:https://git.example.com/group/tool
+type VersionProvider interface {
+	// Name is the scheme of the provider in VersionsAPI
+	Name() string
+	// Match returns true if u is a URL of the provider
+	Match(u *neturl.URL) bool
+	// Validate checks a ref (URL or name) of the provider
+	Validate(ref string) error
+	// Refs returns the tags (versions) and branches of a ref
+	Refs(ref string) (tags []string, branches []string, err error)
+}
+
+// VersionProviders are the registered providers in match order
+var VersionProviders = []VersionProvider{githubProvider{}, bitbucketProvider{}, gitlabProvider{}, giteaProvider{},
+	sourceforgeProvider{}, pypiProvider{}, cranProvider{}, bioconductorProvider{}, ListingProvider{}}
+
+// urlVersionProviders are the providers also used with the download URLs of
+// keys without VersionsAPI
+var urlVersionProviders = []string{"github", "bitbucket"}
+
+// RegisterVersionProvider adds (or replaces) a provider with the same name
+func RegisterVersionProvider(p VersionProvider) {
+	for i := range VersionProviders {
+		if VersionProviders[i].Name() == p.Name() {
+			VersionProviders[i] = p
+			return
+		}
+	}
+	VersionProviders = append(VersionProviders, p)
+}
+
+// VersionProviderNames returns the names of VersionProviders
+func VersionProviderNames() (names []string) {
+	for _, p := range VersionProviders {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// FindVersionProvider returns the provider and ref of a VersionsAPI (or a
+// download URL), nil if no provider supports it
+func FindVersionProvider(api string) (VersionProvider, string) {
+	api = strings.TrimSpace(api)
+	if api == "" {
+		return nil, ""
+	}
+	if i := strings.Index(api, ":"); i > 0 && !strings.HasPrefix(api[i:], "://") {
+		for _, p := range VersionProviders {
+			if p.Name() == strings.ToLower(api[:i]) {
+				return p, strings.TrimSpace(api[i+1:])
+			}
+		}
+		return nil, ""
+	}
+	u, err := neturl.Parse(api)
+	if err != nil || u.Host == "" {
+		return nil, ""
+	}
+	for _, p := range VersionProviders {
+		if p.Match(u) {
+			return p, api
+		}
+	}
+	return nil, ""
+}
+
+// FetchVersions returns the tags (newest first) and branches of a VersionsAPI
+func FetchVersions(api string, includeBranches bool) (versions []string, err error) {
+	p, ref := FindVersionProvider(api)
+	if p == nil {
+		return nil, fmt.Errorf("unsupported VersionsAPI %s (supported: %s)", api, strings.Join(VersionProviderNames(), ", "))
+	}
+	tags, branches, err := p.Refs(ref)
+	versions = SortVersions(tags)
+	if includeBranches {
+		versions = append(versions, branches...)
+	}
+	return versions, err
+}
+
+// getVersionAPI gets url by the client of version APIs (with VersionCache)
+func getVersionAPI(url string, header map[string]string) (body []byte, respHeader http.Header, err error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := versionHTTPClient(nil).Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.Header, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	body, err = ioutil.ReadAll(resp.Body)
+	return body, resp.Header, err
+}
+
+// repoURL expands owner/repo refs with base (e.g. https://gitlab.com/) and
+// returns the URL and the repo path
+func repoURL(ref string, base string) (u *neturl.URL, repoPath string, err error) {
+	if !strings.Contains(ref, "://") {
+		ref = base + strings.TrimPrefix(ref, "/")
+	}
+	u, err = neturl.Parse(ref)
+	if err != nil {
+		return nil, "", err
+	}
+	repoPath = strings.Trim(u.Path, "/")
+	if i := strings.Index(repoPath, "/-/"); i > 0 {
+		repoPath = repoPath[:i]
+	}
+	repoPath = strings.TrimSuffix(repoPath, ".git")
+	if len(strings.Split(repoPath, "/")) < 2 {
+		return nil, "", fmt.Errorf("%s should be %sowner/repo", ref, base)
+	}
+	return u, repoPath, nil
+}
+
+// packageName returns the name of a package ref, a URL is parsed by fn
+func packageName(ref string, fn func(segs []string) string) (string, error) {
+	name := ref
+	if strings.Contains(ref, "://") {
+		u, err := neturl.Parse(ref)
+		if err != nil {
+			return "", err
+		}
+		name = fn(strings.Split(strings.Trim(u.Path, "/"), "/"))
+	}
+	if name == "" || strings.ContainsAny(name, "/ ") {
+		return "", fmt.Errorf("invalid package %s", ref)
+	}
+	return name, nil
+}
+
+// segAfter returns the path segment after key (e.g. project of /project/NAME)
+func segAfter(segs []string, key string) string {
+	for i := range segs {
+		if segs[i] == key && i+1 < len(segs) {
+			return segs[i+1]
+		}
+	}
+	return ""
+}
+
+type githubProvider struct{}
+
+func (githubProvider) Name() string { return "github" }
+
+func (githubProvider) Match(u *neturl.URL) bool {
+	_, ok := GitHubHosts[u.Host]
+	return ok
+}
+
+func (githubProvider) Validate(ref string) error {
+	_, _, err := repoURL(ref, "https://github.com/")
+	return err
+}
+
+func (githubProvider) Refs(ref string) ([]string, []string, error) {
+	u, _, err := repoURL(ref, "https://github.com/")
+	if err != nil {
+		return nil, nil, err
+	}
+	return GitHubRefsSpider(u.String())
+}
+
+type bitbucketProvider struct{}
+
+func (bitbucketProvider) Name() string { return "bitbucket" }
+
+func (bitbucketProvider) Match(u *neturl.URL) bool { return u.Host == "bitbucket.org" }
+
+func (bitbucketProvider) Validate(ref string) error {
+	_, _, err := repoURL(ref, "https://bitbucket.org/")
+	return err
+}
+
+func (bitbucketProvider) Refs(ref string) ([]string, []string, error) {
+	u, repoPath, err := repoURL(ref, "https://bitbucket.org/")
+	if err != nil {
+		return nil, nil, err
+	}
+	tags, branches := BitbucketRefsSpider(u.String())
+	if len(tags)+len(branches) == 0 {
+		return nil, nil, fmt.Errorf("failed to get tags of %s", repoPath)
+	}
+	return tags, branches, nil
+}
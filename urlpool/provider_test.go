@@ -0,0 +1,103 @@
+package urlpool
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFindVersionProvider(t *testing.T) {
+	tests := []struct {
+		api, name, ref string
+	}{
+		{"https://github.com/lh3/bwa", "github", "https://github.com/lh3/bwa"},
+		{"https://gitlab.com/group/tool", "gitlab", "https://gitlab.com/group/tool"},
+		{"gitlab:https://git.example.com/group/tool", "gitlab", "https://git.example.com/group/tool"},
+		{"https://codeberg.org/owner/tool", "gitea", "https://codeberg.org/owner/tool"},
+		{"https://sourceforge.net/projects/bowtie-bio/files/", "sourceforge", "https://sourceforge.net/projects/bowtie-bio/files/"},
+		{"pypi:multiqc", "pypi", "multiqc"},
+		{"CRAN: Seurat", "cran", "Seurat"},
+		{"bioconductor:DESeq2", "bioconductor", "DESeq2"},
+		{"https://example.com/tool", "", ""},
+		{"npm:tool", "", ""},
+	}
+	for _, tt := range tests {
+		p, ref := FindVersionProvider(tt.api)
+		name := ""
+		if p != nil {
+			name = p.Name()
+		}
+		if name != tt.name || ref != tt.ref {
+			t.Errorf("FindVersionProvider(%q) = %s, %q, want %s, %q", tt.api, name, ref, tt.name, tt.ref)
+		}
+	}
+}
+
+func TestPackageRefs(t *testing.T) {
+	tests := []struct {
+		fn   func(string) (string, error)
+		ref  string
+		want string
+	}{
+		{pypiPackage, "https://pypi.org/project/multiqc/", "multiqc"},
+		{cranPackage, "https://cran.r-project.org/package=Seurat", "Seurat"},
+		{cranPackage, "https://cran.r-project.org/web/packages/Seurat/index.html", "Seurat"},
+		{bioconductorPackage, "https://bioconductor.org/packages/release/bioc/html/DESeq2.html", "DESeq2"},
+		{bioconductorPackage, "https://bioconductor.org/packages/DESeq2", "DESeq2"},
+		{sourceforgeProject, "https://sourceforge.net/projects/bowtie-bio/files/", "bowtie-bio"},
+	}
+	for _, tt := range tests {
+		if got, err := tt.fn(tt.ref); err != nil || got != tt.want {
+			t.Errorf("package of %s = %q, %v, want %q", tt.ref, got, err, tt.want)
+		}
+	}
+	if _, err := pypiPackage("https://pypi.org/"); err == nil {
+		t.Error("expected an error of https://pypi.org/")
+	}
+}
+
+func TestVersionParsers(t *testing.T) {
+	for file, want := range map[string]string{
+		"/bowtie2/2.4.1/bowtie2-2.4.1-linux-x86_64.zip": "2.4.1",
+		"/tophat-2.1.1.tar.gz":                          "2.1.1",
+		"/README.txt":                                   "",
+	} {
+		if got := sourceforgeVersion(file); got != want {
+			t.Errorf("sourceforgeVersion(%s) = %q, want %q", file, got, want)
+		}
+	}
+	pkgs := []byte("Package: ABAData\nVersion: 1.20.0\n\nPackage: DESeq2\nVersion: 1.30.1\nDepends: R\n")
+	if got := dcfField(pkgs, "DESeq2", "Version"); got != "1.30.1" {
+		t.Errorf("dcfField = %q, want 1.30.1", got)
+	}
+	listing := []byte(`<a href="DESeq2_1.28.0.tar.gz">DESeq2_1.28.0.tar.gz</a> <a href="DESeq2_1.29.1.tar.gz">`)
+	if got := strings.Join(archiveVersions(listing, "DESeq2"), ","); got != "1.28.0,1.29.1" {
+		t.Errorf("archiveVersions = %s", got)
+	}
+}
+
+func TestGitLabRefs(t *testing.T) {
+	oldDir := VersionCache.Dir
+	defer func() { VersionCache.Dir = oldDir }()
+	VersionCache.Dir = ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.EscapedPath() == "/api/v4/projects/group%2Ftool/repository/tags" && r.URL.Query().Get("page") == "1":
+			w.Header().Set("X-Next-Page", "2")
+			fmt.Fprint(w, `[{"name": "v2.0"}]`)
+		case r.URL.EscapedPath() == "/api/v4/projects/group%2Ftool/repository/tags":
+			fmt.Fprint(w, `[{"name": "v1.0"}]`)
+		case r.URL.EscapedPath() == "/api/v4/projects/group%2Ftool/repository/branches":
+			fmt.Fprint(w, `[{"name": "main"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	versions, err := FetchVersions("gitlab:"+srv.URL+"/group/tool", true)
+	if err != nil || strings.Join(versions, ",") != "v2.0,v1.0,main" {
+		t.Errorf("FetchVersions = %v, %v", versions, err)
+	}
+}
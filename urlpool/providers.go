@@ -0,0 +1,375 @@
+package urlpool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// nameRef is a tag or branch of GitLab and Gitea APIs
+type nameRef struct {
+	Name string `json:"name"`
+}
+
+// maxPages limits the pages of a list API
+const maxPages = 50
+
+type gitlabProvider struct{}
+
+func (gitlabProvider) Name() string { return "gitlab" }
+
+// Match accepts gitlab.com and self-hosted gitlab.* hosts, other self-hosted
+// instances use gitlab:https://host/group/repo
+func (gitlabProvider) Match(u *neturl.URL) bool {
+	return u.Host == "gitlab.com" || strings.HasPrefix(u.Host, "gitlab.")
+}
+
+func (gitlabProvider) Validate(ref string) error {
+	_, _, err := repoURL(ref, "https://gitlab.com/")
+	return err
+}
+
+// Refs gets the tags and branches by the GitLab API v4 (GITLAB_TOKEN for
+// private projects)
+func (gitlabProvider) Refs(ref string) (tags []string, branches []string, err error) {
+	u, repoPath, err := repoURL(ref, "https://gitlab.com/")
+	if err != nil {
+		return nil, nil, err
+	}
+	header := map[string]string{}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		header["PRIVATE-TOKEN"] = token
+	}
+	list := func(entry string) (names []string, err error) {
+		page := "1"
+		for i := 0; i < maxPages && page != ""; i++ {
+			api := fmt.Sprintf("%s://%s/api/v4/projects/%s/repository/%s?per_page=100&page=%s", u.Scheme, u.Host,
+				neturl.PathEscape(repoPath), entry, page)
+			body, respHeader, err := getVersionAPI(api, header)
+			if err != nil {
+				return names, err
+			}
+			refs := []nameRef{}
+			if err := json.Unmarshal(body, &refs); err != nil {
+				return names, err
+			}
+			for _, r := range refs {
+				names = append(names, r.Name)
+			}
+			page = respHeader.Get("X-Next-Page")
+		}
+		return names, nil
+	}
+	if tags, err = list("tags"); err != nil {
+		return nil, nil, fmt.Errorf("failed to get tags of %s: %v", repoPath, err)
+	}
+	if branches, err = list("branches"); err != nil {
+		return tags, nil, fmt.Errorf("failed to get branches of %s: %v", repoPath, err)
+	}
+	return tags, branches, nil
+}
+
+type giteaProvider struct{}
+
+func (giteaProvider) Name() string { return "gitea" }
+
+// Match accepts codeberg.org, gitea.com and gitea.* hosts, other instances
+// use gitea:https://host/owner/repo
+func (giteaProvider) Match(u *neturl.URL) bool {
+	return u.Host == "codeberg.org" || u.Host == "gitea.com" || strings.HasPrefix(u.Host, "gitea.")
+}
+
+func (giteaProvider) Validate(ref string) error {
+	_, _, err := repoURL(ref, "https://gitea.com/")
+	return err
+}
+
+// Refs gets the tags and branches by the Gitea API v1 (GITEA_TOKEN for
+// private repos)
+func (giteaProvider) Refs(ref string) (tags []string, branches []string, err error) {
+	u, repoPath, err := repoURL(ref, "https://gitea.com/")
+	if err != nil {
+		return nil, nil, err
+	}
+	header := map[string]string{}
+	if token := os.Getenv("GITEA_TOKEN"); token != "" {
+		header["Authorization"] = "token " + token
+	}
+	list := func(entry string) (names []string, err error) {
+		const limit = 50
+		for page := 1; page <= maxPages; page++ {
+			api := fmt.Sprintf("%s://%s/api/v1/repos/%s/%s?limit=%d&page=%d", u.Scheme, u.Host, repoPath, entry, limit, page)
+			body, _, err := getVersionAPI(api, header)
+			if err != nil {
+				return names, err
+			}
+			refs := []nameRef{}
+			if err := json.Unmarshal(body, &refs); err != nil {
+				return names, err
+			}
+			for _, r := range refs {
+				names = append(names, r.Name)
+			}
+			if len(refs) < limit {
+				break
+			}
+		}
+		return names, nil
+	}
+	if tags, err = list("tags"); err != nil {
+		return nil, nil, fmt.Errorf("failed to get tags of %s: %v", repoPath, err)
+	}
+	if branches, err = list("branches"); err != nil {
+		return tags, nil, fmt.Errorf("failed to get branches of %s: %v", repoPath, err)
+	}
+	return tags, branches, nil
+}
+
+type sourceforgeProvider struct{}
+
+func (sourceforgeProvider) Name() string { return "sourceforge" }
+
+func (sourceforgeProvider) Match(u *neturl.URL) bool {
+	return u.Host == "sourceforge.net" || strings.HasSuffix(u.Host, ".sourceforge.net")
+}
+
+func sourceforgeProject(ref string) (string, error) {
+	return packageName(ref, func(segs []string) string {
+		if name := segAfter(segs, "projects"); name != "" {
+			return name
+		}
+		return segAfter(segs, "p")
+	})
+}
+
+func (sourceforgeProvider) Validate(ref string) error {
+	_, err := sourceforgeProject(ref)
+	return err
+}
+
+var sourceforgeDirVersionRe = regexp.MustCompile(`^[vV]?\d+(\.\d+)+[a-z0-9.-]*$`)
+var sourceforgeFileVersionRe = regexp.MustCompile(`[-_.][vV]?(\d+(?:\.\d+)+)`)
+
+// sourceforgeVersion extracts the version of a file path of the RSS, e.g.
+// /bowtie2/2.4.1/bowtie2-2.4.1-linux-x86_64.zip (2.4.1)
+func sourceforgeVersion(file string) string {
+	segs := strings.Split(strings.Trim(file, "/"), "/")
+	for _, seg := range segs[:len(segs)-1] {
+		if sourceforgeDirVersionRe.MatchString(seg) {
+			return seg
+		}
+	}
+	if m := sourceforgeFileVersionRe.FindStringSubmatch(segs[len(segs)-1]); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Refs gets the versions from the file paths of the project RSS
+func (sourceforgeProvider) Refs(ref string) (tags []string, branches []string, err error) {
+	name, err := sourceforgeProject(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, _, err := getVersionAPI(fmt.Sprintf("https://sourceforge.net/projects/%s/rss?path=/&limit=1000", name), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get files of %s: %v", name, err)
+	}
+	var rss struct {
+		Items []struct {
+			Title string `xml:"title"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(body, &rss); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse files of %s: %v", name, err)
+	}
+	seen := make(map[string]bool)
+	for _, item := range rss.Items {
+		if v := sourceforgeVersion(item.Title); v != "" && !seen[v] {
+			seen[v] = true
+			tags = append(tags, v)
+		}
+	}
+	return tags, nil, nil
+}
+
+type pypiProvider struct{}
+
+func (pypiProvider) Name() string { return "pypi" }
+
+func (pypiProvider) Match(u *neturl.URL) bool { return u.Host == "pypi.org" }
+
+func pypiPackage(ref string) (string, error) {
+	return packageName(ref, func(segs []string) string {
+		if name := segAfter(segs, "project"); name != "" {
+			return name
+		}
+		return segAfter(segs, "pypi")
+	})
+}
+
+func (pypiProvider) Validate(ref string) error {
+	_, err := pypiPackage(ref)
+	return err
+}
+
+// Refs gets the releases (with files) of the PyPI JSON API
+func (pypiProvider) Refs(ref string) (tags []string, branches []string, err error) {
+	name, err := pypiPackage(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, _, err := getVersionAPI(fmt.Sprintf("https://pypi.org/pypi/%s/json", name), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get releases of %s: %v", name, err)
+	}
+	var info struct {
+		Releases map[string][]json.RawMessage `json:"releases"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse releases of %s: %v", name, err)
+	}
+	for v, files := range info.Releases {
+		if len(files) > 0 {
+			tags = append(tags, v)
+		}
+	}
+	return SortVersions(tags), nil, nil
+}
+
+// dcfField returns the field of the package block in a DCF file (e.g.
+// DESCRIPTION and PACKAGES of R repositories)
+func dcfField(data []byte, pkg string, field string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	current := pkg
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "Package:") {
+			current = strings.TrimSpace(strings.TrimPrefix(line, "Package:"))
+		} else if current == pkg && strings.HasPrefix(line, field+":") {
+			return strings.TrimSpace(strings.TrimPrefix(line, field+":"))
+		}
+	}
+	return ""
+}
+
+// archiveVersions returns the versions of <pkg>_<version>.tar.gz in a
+// directory listing
+func archiveVersions(data []byte, pkg string) (versions []string) {
+	re := regexp.MustCompile(regexp.QuoteMeta(pkg) + `_([0-9][0-9.-]*)\.tar\.gz`)
+	seen := make(map[string]bool)
+	for _, m := range re.FindAllStringSubmatch(string(data), -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			versions = append(versions, m[1])
+		}
+	}
+	return versions
+}
+
+type cranProvider struct{}
+
+func (cranProvider) Name() string { return "cran" }
+
+func (cranProvider) Match(u *neturl.URL) bool {
+	return u.Host == "cran.r-project.org" || u.Host == "cloud.r-project.org"
+}
+
+func cranPackage(ref string) (string, error) {
+	return packageName(ref, func(segs []string) string {
+		for _, seg := range segs {
+			if strings.HasPrefix(seg, "package=") {
+				return strings.TrimPrefix(seg, "package=")
+			}
+		}
+		if name := segAfter(segs, "packages"); name != "" {
+			return name
+		}
+		return segAfter(segs, "Archive")
+	})
+}
+
+func (cranProvider) Validate(ref string) error {
+	_, err := cranPackage(ref)
+	return err
+}
+
+// Refs gets the current version (DESCRIPTION) and the archived versions of
+// a CRAN package
+func (cranProvider) Refs(ref string) (tags []string, branches []string, err error) {
+	name, err := cranPackage(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	desc, _, err := getVersionAPI(fmt.Sprintf("https://cran.r-project.org/web/packages/%s/DESCRIPTION", name), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get DESCRIPTION of %s: %v", name, err)
+	}
+	if v := dcfField(desc, name, "Version"); v != "" {
+		tags = append(tags, v)
+	}
+	// new packages have no archive
+	if archive, _, err := getVersionAPI(fmt.Sprintf("https://cran.r-project.org/src/contrib/Archive/%s/", name), nil); err == nil {
+		tags = append(tags, archiveVersions(archive, name)...)
+	}
+	return tags, nil, nil
+}
+
+type bioconductorProvider struct{}
+
+func (bioconductorProvider) Name() string { return "bioconductor" }
+
+func (bioconductorProvider) Match(u *neturl.URL) bool {
+	return u.Host == "bioconductor.org" || u.Host == "www.bioconductor.org"
+}
+
+func bioconductorPackage(ref string) (string, error) {
+	return packageName(ref, func(segs []string) string {
+		if len(segs) == 0 {
+			return ""
+		}
+		if name := segAfter(segs, "html"); name != "" {
+			return strings.TrimSuffix(name, ".html")
+		}
+		return strings.TrimSuffix(segs[len(segs)-1], ".html")
+	})
+}
+
+func (bioconductorProvider) Validate(ref string) error {
+	_, err := bioconductorPackage(ref)
+	return err
+}
+
+// bioconductorRepos are the package repositories of a Bioconductor release
+var bioconductorRepos = []string{"bioc", "data/annotation", "data/experiment", "workflows"}
+
+// Refs gets the version of the current Bioconductor release (PACKAGES) and
+// the archived versions of a package
+func (bioconductorProvider) Refs(ref string) (tags []string, branches []string, err error) {
+	name, err := bioconductorPackage(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, repo := range bioconductorRepos {
+		base := fmt.Sprintf("https://bioconductor.org/packages/release/%s/src/contrib/", repo)
+		pkgs, _, err := getVersionAPI(base+"PACKAGES", nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get versions of %s: %v", name, err)
+		}
+		if v := dcfField(pkgs, name, "Version"); v != "" {
+			tags = append(tags, v)
+			if archive, _, err := getVersionAPI(base+"Archive/"+name+"/", nil); err == nil {
+				tags = append(tags, archiveVersions(archive, name)...)
+			}
+			return tags, nil, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("package %s not found in Bioconductor", name)
+}
@@ -0,0 +1,149 @@
+package urlpool
+
+import (
+	"fmt"
+	neturl "net/url"
+	"regexp"
+	"strings"
+)
+
+// RepoSpec is a git repo with an optional ref (tag, branch or commit sha),
+// e.g. owner/repo@v1.0, https://gitlab.com/group/repo@main or
+// git@github.com:owner/repo.git@1a2b3c4
+type RepoSpec struct {
+	Host  string
+	Owner string
+	Repo  string
+	Ref   string
+	// clone is the git URL of ssh specs (git@host:owner/repo.git)
+	clone string
+}
+
+var shaRe = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// ParseRepoSpec parses owner/repo[@ref], host/owner/repo[@ref], a repo URL of
+// any host or a git@host:owner/repo ssh URL, owner/repo is a GitHub repo
+func ParseRepoSpec(spec string) (r RepoSpec, err error) {
+	s := strings.TrimSpace(spec)
+	if strings.HasPrefix(s, "git@") {
+		s = strings.TrimPrefix(s, "git@")
+		i := strings.Index(s, ":")
+		if i <= 0 {
+			return r, fmt.Errorf("malformed repo %s", spec)
+		}
+		r.Host, s = s[:i], s[i+1:]
+		r.clone = "git@" + r.Host + ":"
+	} else if strings.Contains(s, "://") {
+		u, err := neturl.Parse(s)
+		if err != nil {
+			return r, fmt.Errorf("malformed repo %s: %v", spec, err)
+		}
+		r.Host, s = u.Host, strings.TrimPrefix(u.Path, "/")
+	} else if parts := strings.SplitN(s, "/", 2); len(parts) == 2 && strings.Contains(parts[0], ".") {
+		r.Host, s = parts[0], parts[1]
+	} else {
+		r.Host = "github.com"
+	}
+	if i := strings.Index(s, "@"); i >= 0 {
+		s, r.Ref = s[:i], s[i+1:]
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "/"), ".git")
+	i := strings.LastIndex(s, "/")
+	if r.Host == "" || i <= 0 || i == len(s)-1 {
+		return r, fmt.Errorf("malformed repo %s (use owner/repo[@ref] or a repo URL)", spec)
+	}
+	r.Owner, r.Repo = s[:i], s[i+1:]
+	if r.clone != "" {
+		r.clone += s + ".git"
+	}
+	return r, nil
+}
+
+// Key is owner/repo of GitHub repos and host/owner/repo of others
+func (r RepoSpec) Key() string {
+	if r.Host == "github.com" {
+		return r.Owner + "/" + r.Repo
+	}
+	return r.Host + "/" + r.Owner + "/" + r.Repo
+}
+
+// URL is the web URL of repo
+func (r RepoSpec) URL() string {
+	return "https://" + r.Host + "/" + r.Owner + "/" + r.Repo
+}
+
+// CloneURL is the git URL of repo
+func (r RepoSpec) CloneURL() string {
+	if r.clone != "" {
+		return r.clone
+	}
+	return r.URL() + ".git"
+}
+
+// IsSHA returns true if Ref is a commit sha
+func (r RepoSpec) IsSHA() bool {
+	return shaRe.MatchString(r.Ref)
+}
+
+// TarballURL is the source tarball URL of Ref (HEAD of the default branch
+// if Ref is empty) on github.com (codeload), gitlab.com and bitbucket.org,
+// it is empty for other hosts
+func (r RepoSpec) TarballURL() string {
+	ref := r.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	switch r.Host {
+	case "github.com":
+		return fmt.Sprintf("https://codeload.github.com/%s/%s/tar.gz/%s", r.Owner, r.Repo, ref)
+	case "gitlab.com":
+		if r.Ref == "" {
+			return ""
+		}
+		return fmt.Sprintf("https://gitlab.com/%s/%s/-/archive/%s/%s-%s.tar.gz", r.Owner, r.Repo, ref, r.Repo,
+			strings.ReplaceAll(ref, "/", "-"))
+	case "bitbucket.org":
+		if r.Ref == "" {
+			return ""
+		}
+		return fmt.Sprintf("https://bitbucket.org/%s/%s/get/%s.tar.gz", r.Owner, r.Repo, ref)
+	}
+	return ""
+}
+
+// TarballName is the file name of the source tarball, e.g. repo-v1.0.tar.gz
+func (r RepoSpec) TarballName() string {
+	ref := r.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	return r.Repo + "-" + strings.ReplaceAll(strings.TrimPrefix(ref, "refs/tags/"), "/", "-") + ".tar.gz"
+}
+
+// GitCloneArgs returns the git commands for a shallow clone of Ref into
+// dest: clone --depth 1 --branch of tags and branches, or init and fetch of
+// a commit sha (the default branch if Ref is empty)
+func (r RepoSpec) GitCloneArgs(dest string, submodules bool) (cmds [][]string) {
+	subArgs := []string{}
+	if submodules {
+		subArgs = []string{"--recurse-submodules", "--shallow-submodules"}
+	}
+	if !r.IsSHA() {
+		args := []string{"clone", "--depth", "1"}
+		if r.Ref != "" {
+			args = append(args, "--branch", r.Ref)
+		}
+		args = append(append(args, subArgs...), r.CloneURL(), dest)
+		return [][]string{args}
+	}
+	cmds = [][]string{
+		{"init", dest},
+		{"-C", dest, "remote", "add", "origin", r.CloneURL()},
+		{"-C", dest, "fetch", "--depth", "1", "origin", r.Ref},
+		{"-C", dest, "checkout", "FETCH_HEAD"},
+	}
+	if submodules {
+		cmds = append(cmds, []string{"-C", dest, "submodule", "update", "--init", "--recursive", "--depth", "1"})
+	}
+	return cmds
+}
@@ -0,0 +1,58 @@
+package urlpool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRepoSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		key     string
+		ref     string
+		clone   string
+		tarball string
+	}{
+		{"clindet/bget", "clindet/bget", "", "https://github.com/clindet/bget.git",
+			"https://codeload.github.com/clindet/bget/tar.gz/HEAD"},
+		{"PapenfussLab/gridss@v2.7.2", "PapenfussLab/gridss", "v2.7.2", "https://github.com/PapenfussLab/gridss.git",
+			"https://codeload.github.com/PapenfussLab/gridss/tar.gz/v2.7.2"},
+		{"https://github.com/clindet/bget.git@feature/x", "clindet/bget", "feature/x", "https://github.com/clindet/bget.git",
+			"https://codeload.github.com/clindet/bget/tar.gz/feature/x"},
+		{"https://gitlab.com/group/sub/repo@v1.0", "gitlab.com/group/sub/repo", "v1.0", "https://gitlab.com/group/sub/repo.git",
+			"https://gitlab.com/group/sub/repo/-/archive/v1.0/repo-v1.0.tar.gz"},
+		{"bitbucket.org/team/tool@1a2b3c4", "bitbucket.org/team/tool", "1a2b3c4", "https://bitbucket.org/team/tool.git",
+			"https://bitbucket.org/team/tool/get/1a2b3c4.tar.gz"},
+		{"git@git.example.com:team/tool.git@main", "git.example.com/team/tool", "main", "git@git.example.com:team/tool.git", ""},
+	}
+	for _, tt := range tests {
+		r, err := ParseRepoSpec(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		if r.Key() != tt.key || r.Ref != tt.ref || r.CloneURL() != tt.clone || r.TarballURL() != tt.tarball {
+			t.Errorf("%s: got %s %s %s %s", tt.spec, r.Key(), r.Ref, r.CloneURL(), r.TarballURL())
+		}
+	}
+	for _, spec := range []string{"bget", "https://github.com/clindet", "git@github.com"} {
+		if _, err := ParseRepoSpec(spec); err == nil {
+			t.Errorf("%s: expect error", spec)
+		}
+	}
+}
+
+func TestGitCloneArgs(t *testing.T) {
+	r, _ := ParseRepoSpec("samtools/htslib@1.10")
+	want := [][]string{{"clone", "--depth", "1", "--branch", "1.10", "--recurse-submodules", "--shallow-submodules",
+		"https://github.com/samtools/htslib.git", "htslib"}}
+	if got := r.GitCloneArgs("htslib", true); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	r, _ = ParseRepoSpec("samtools/htslib@1a2b3c4d")
+	if got := r.GitCloneArgs("htslib", false); len(got) != 4 || got[2][6] != "1a2b3c4d" {
+		t.Errorf("got %v", got)
+	}
+	if r.TarballName() != "htslib-1a2b3c4d.tar.gz" {
+		t.Errorf("got %s", r.TarballName())
+	}
+}
@@ -0,0 +1,122 @@
+package urlpool
+
+import (
+	"sort"
+	"strings"
+)
+
+// SearchResult is one matched key of SearchKeys
+type SearchResult struct {
+	Key         string
+	Channel     string
+	Description string
+	Tags        []string
+	Score       int
+}
+
+// fuzzyMatch returns true if all runes of q appear in s in order within a
+// window of len(q)+2 runes (e.g. "smtools" matches "samtools")
+func fuzzyMatch(s string, q string) bool {
+	sr, qr := []rune(s), []rune(q)
+	if len(qr) < 3 {
+		return false
+	}
+	for start := range sr {
+		if sr[start] != qr[0] {
+			continue
+		}
+		i := 1
+		for j := start + 1; j < len(sr) && j-start < len(qr)+2 && i < len(qr); j++ {
+			if sr[j] == qr[i] {
+				i++
+			}
+		}
+		if i == len(qr) {
+			return true
+		}
+	}
+	return false
+}
+
+func scoreTerm(name string, desc string, tags []string, term string) (score int) {
+	switch {
+	case name == term:
+		score = 100
+	case strings.HasPrefix(name, term) || strings.HasSuffix(name, "/"+term):
+		score = 80
+	case strings.Contains(name, term):
+		score = 60
+	}
+	for _, t := range tags {
+		if strings.ToLower(t) == term && score < 50 {
+			score = 50
+		}
+	}
+	if score < 30 && strings.Contains(strings.ToLower(desc), term) {
+		score = 30
+	}
+	if score == 0 && fuzzyMatch(name, term) {
+		score = 10
+	}
+	return score
+}
+
+func hasTags(tags []string, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, t := range tags {
+			found = found || strings.EqualFold(t, w)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchKeys returns the keys matched all terms of query (by name, description
+// and tags) and with all tags, ordered by score
+func SearchKeys(query string, tags []string, tools []BgetToolsURLType, files []BgetFilesURLType) (results []SearchResult) {
+	terms := strings.Fields(strings.ToLower(query))
+	idx := make(map[string]int)
+	add := func(name, channel, desc string, keyTags []string) {
+		key := strings.ReplaceAll(strings.ToLower(name), "_", "-")
+		if !hasTags(keyTags, tags) {
+			return
+		}
+		score := 0
+		for _, term := range terms {
+			s := scoreTerm(key, desc, keyTags, term)
+			if s == 0 {
+				return
+			}
+			score += s
+		}
+		if i, ok := idx[key]; ok {
+			if score > results[i].Score {
+				results[i].Score = score
+			}
+			if results[i].Description == "" {
+				results[i].Description = desc
+			}
+			results[i].Tags = append(results[i].Tags, keyTags...)
+			return
+		}
+		idx[key] = len(results)
+		results = append(results, SearchResult{Key: key, Channel: channel, Description: desc,
+			Tags: append([]string{}, keyTags...), Score: score})
+	}
+	for _, t := range tools {
+		add(t.Name, t.Channel, t.Description, t.Tags)
+	}
+	for _, f := range files {
+		add(f.Name, f.Channel, f.Description, f.Tags)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Key < results[j].Key
+	})
+	return results
+}
@@ -0,0 +1,20 @@
+package urlpool
+
+import "testing"
+
+func TestSearchKeys(t *testing.T) {
+	tools := []BgetToolsURLType{{Name: "samtools", Tags: []string{"bam"}}, {Name: "bwa", Description: "BWA mem aligner", Tags: []string{"aligner"}}}
+	files := []BgetFilesURLType{{Name: "github/samtools"}, {Name: "github/smallgenometools"}}
+	res := SearchKeys("smtools", nil, tools, files)
+	if len(res) != 2 || res[0].Key != "github/samtools" && res[0].Key != "samtools" {
+		t.Fatalf("unexpected results %v", res)
+	}
+	res = SearchKeys("samtools", nil, tools, files)
+	if len(res) != 2 || res[0].Key != "samtools" {
+		t.Fatalf("unexpected results %v", res)
+	}
+	res = SearchKeys("mem", []string{"aligner"}, tools, files)
+	if len(res) != 1 || res[0].Key != "bwa" {
+		t.Fatalf("unexpected results %v", res)
+	}
+}
@@ -0,0 +1,22 @@
+package urlpool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ShellQuote quotes s for sh so that it is always passed as a single word
+func ShellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// RenderShellCmd replaces {{key}} in a post shell command with shell quoted env values
+func RenderShellCmd(cmd string, env *map[string]string) string {
+	for k, v := range *env {
+		cmd = strings.Replace(cmd, fmt.Sprintf("{{%s}}", k), ShellQuote(v), 10000)
+	}
+	return cmd
+}
@@ -0,0 +1,36 @@
+package urlpool
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	for _, v := range []string{
+		"",
+		"hg38",
+		"a b  c",
+		"it's",
+		"''",
+		"$(touch /tmp/bget-pwned)",
+		"`id`",
+		"$HOME ${PATH}",
+		"a;b|c&d>e<f",
+		"line1\nline2",
+		`back\slash "double"`,
+	} {
+		out, err := exec.Command("sh", "-c", "printf %s "+ShellQuote(v)).Output()
+		if err != nil || string(out) != v {
+			t.Errorf("ShellQuote(%q) = %s: got %q, %v", v, ShellQuote(v), out, err)
+		}
+	}
+}
+
+func TestRenderShellCmd(t *testing.T) {
+	env := map[string]string{"version": "1.0'; echo pwned; '", "dest": "my file.tar.gz"}
+	cmd := RenderShellCmd("printf '%s|%s' {{version}} {{dest}}", &env)
+	out, err := exec.Command("sh", "-c", cmd).Output()
+	if want := env["version"] + "|" + env["dest"]; err != nil || string(out) != want {
+		t.Errorf("RenderShellCmd = %s: got %q, %v, want %q", cmd, out, err, want)
+	}
+}
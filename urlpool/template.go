@@ -0,0 +1,139 @@
+package urlpool
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// BgetVarType declares a template variable of a key, e.g.
+// "Vars": {"release": {"Default": "100", "Values": ["99", "100"]}}
+type BgetVarType struct {
+	Description string
+	Default     string
+	// Values are the allowed values (any value if empty)
+	Values []string
+	// List expands comma separated values into multiple URLs
+	List bool
+	// Required fails the rendering if the variable has no value
+	Required bool
+	// ValuesAPI and ValuesRegex list the values like a listing: VersionsAPI
+	// (e.g. the releases of a download dir), a given value is checked against
+	// them and the newest one is used if there is no value and Default
+	ValuesAPI   string `json:",omitempty"`
+	ValuesRegex string `json:",omitempty"`
+}
+
+// isListVar returns true if comma separated values of k are expanded,
+// version always accepts a list (e.g. resolved from clinvar_*)
+func isListVar(k string, def BgetVarType) bool {
+	return def.List || k == "version"
+}
+
+var templateVarRe = regexp.MustCompile(`{{\s*([A-Za-z0-9_]+)\s*}}`)
+
+// TemplateVars returns the {{var}} names used in s
+func TemplateVars(s string) (vars []string) {
+	for _, m := range templateVarRe.FindAllStringSubmatch(s, -1) {
+		vars = append(vars, m[1])
+	}
+	return vars
+}
+
+func splitList(v string) (values []string) {
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// ResolveVars returns a copy of env with the defaults of vars and checks
+// the required and allowed values
+func ResolveVars(env map[string]string, vars map[string]BgetVarType) (map[string]string, error) {
+	envNew := make(map[string]string)
+	for k, v := range env {
+		envNew[k] = v
+	}
+	names := []string{}
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		def := vars[k]
+		if envNew[k] == "" {
+			envNew[k] = def.Default
+		}
+		if envNew[k] == "" && def.Required {
+			return nil, fmt.Errorf("variable %s is required (e.g. %s=value)", k, k)
+		}
+		if envNew[k] == "" || len(def.Values) == 0 {
+			continue
+		}
+		values := []string{envNew[k]}
+		if isListVar(k, def) {
+			values = splitList(envNew[k])
+		}
+		for _, v := range values {
+			allowed := false
+			for _, a := range def.Values {
+				allowed = allowed || a == v
+			}
+			if !allowed {
+				return nil, fmt.Errorf("invalid value %q of %s (allowed: %s)", v, k, strings.Join(def.Values, ", "))
+			}
+		}
+	}
+	return envNew, nil
+}
+
+// RenderURL renders the {{var}} of tpl with env (resolved by ResolveVars),
+// comma separated values of list variables (or undeclared variables) are
+// expanded into multiple URLs.
+func RenderURL(tpl string, env map[string]string, vars map[string]BgetVarType) (urls []string, err error) {
+	names := []string{}
+	values := make(map[string][]string)
+	for _, k := range TemplateVars(tpl) {
+		if _, ok := values[k]; ok {
+			continue
+		}
+		v := env[k]
+		if v == "" {
+			return nil, fmt.Errorf("missing variable {{%s}} in %s (declare a default in Vars or pass %s=value)", k, tpl, k)
+		}
+		if def, ok := vars[k]; isListVar(k, def) || (!ok && strings.Contains(v, ",")) {
+			values[k] = splitList(v)
+		} else {
+			values[k] = []string{v}
+		}
+		names = append(names, k)
+	}
+	combs := []map[string]string{{}}
+	for _, k := range names {
+		tmp := []map[string]string{}
+		for _, comb := range combs {
+			for _, v := range values[k] {
+				c := map[string]string{k: v}
+				for k2, v2 := range comb {
+					c[k2] = v2
+				}
+				tmp = append(tmp, c)
+			}
+		}
+		combs = tmp
+	}
+	for _, comb := range combs {
+		url := tpl
+		if strings.Contains(url, "v{{version}}") {
+			url = strings.ReplaceAll(url, "v{{version}}", "v"+strings.TrimPrefix(comb["version"], "v"))
+		}
+		url = templateVarRe.ReplaceAllStringFunc(url, func(m string) string {
+			return comb[templateVarRe.FindStringSubmatch(m)[1]]
+		})
+		urls = append(urls, url)
+	}
+	return urls, nil
+}
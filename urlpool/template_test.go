@@ -0,0 +1,35 @@
+package urlpool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderURL(t *testing.T) {
+	vars := map[string]BgetVarType{
+		"chrom":   {Default: "1,X", List: true},
+		"release": {Required: true},
+		"site":    {Default: "ucsc", Values: []string{"ucsc", "ensembl"}},
+	}
+	env, err := ResolveVars(map[string]string{"version": "v1.0", "release": "97"}, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls, err := RenderURL("https://x.org/{{site}}/r{{release}}/v{{version}}/chr{{chrom}}.fa", env, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://x.org/ucsc/r97/v1.0/chr1.fa", "https://x.org/ucsc/r97/v1.0/chrX.fa"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Fatalf("got %v, want %v", urls, want)
+	}
+	if _, err := ResolveVars(map[string]string{}, vars); err == nil {
+		t.Fatal("expected error of required variable")
+	}
+	if _, err := ResolveVars(map[string]string{"release": "97", "site": "ncbi"}, vars); err == nil {
+		t.Fatal("expected error of invalid value")
+	}
+	if _, err := RenderURL("https://x.org/{{builder}}.tar.gz", env, vars); err == nil {
+		t.Fatal("expected error of missing variable")
+	}
+}
@@ -4,206 +4,241 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"os"
-	"sort"
-	"strconv"
 	"strings"
 
-	"context"
 	"net/http"
 	neturl "net/url"
 
 	"github.com/openbiox/ligo/stringo"
 
-	"github.com/google/go-github/v27/github"
 	glog "github.com/openbiox/ligo/log"
-	"golang.org/x/oauth2"
 )
 
 var log = glog.Logger
 
 type BgetToolsURLType struct {
-	Name         string
-	Description  string
-	Versions     []string
-	VersionsAPI  string
-	Tags         []string
-	URL          map[string][]string
-	PostShellCmd []string
+	Name        string
+	Description string
+	Versions    []string
+	VersionsAPI string
+	// VersionsRegex extracts the versions of a listing: VersionsAPI
+	VersionsRegex string `json:",omitempty"`
+	Tags          []string
+	URL           map[string][]string
+	PostShellCmd  []string
+	// AssetsInclude and AssetsExclude select the GitHub release assets (globs
+	// or regexes with ~ prefix) of --with-assets
+	AssetsInclude []string `json:",omitempty"`
+	AssetsExclude []string `json:",omitempty"`
+	// Requires are the keys (with version constraints, e.g. htslib@>=1.10)
+	// downloaded or installed before the key
+	Requires []string `json:",omitempty"`
+	// Install declares the build steps and executables of bget install
+	Install *InstallType `json:",omitempty"`
+	// Vars declares the template variables of URL and PostShellCmd
+	Vars map[string]BgetVarType `json:",omitempty"`
+	// Channel is the name of channel that the key is loaded from
+	Channel string `json:"-"`
 }
 
 type BgetFilesURLType struct {
-	Name         string
-	Description  string
-	URL          []string
-	Versions     []string
-	VersionsAPI  string
-	Tags         []string
-	PostShellCmd []string
+	Name        string
+	Description string
+	URL         []string
+	Versions    []string
+	VersionsAPI string
+	// VersionsRegex extracts the versions of a listing: VersionsAPI
+	VersionsRegex string `json:",omitempty"`
+	Tags          []string
+	PostShellCmd  []string
+	// AssetsInclude and AssetsExclude select the GitHub release assets (globs
+	// or regexes with ~ prefix) of --with-assets
+	AssetsInclude []string `json:",omitempty"`
+	AssetsExclude []string `json:",omitempty"`
+	// Requires are the keys (with version constraints, e.g. htslib@>=1.10)
+	// downloaded or installed before the key
+	Requires []string `json:",omitempty"`
+	// Vars declares the template variables of URL and PostShellCmd
+	Vars map[string]BgetVarType `json:",omitempty"`
+	// Channel is the name of channel that the key is loaded from
+	Channel string `json:"-"`
 }
 
-func setOsStr(env *map[string]string) (ostype string) {
-	if (*env)["osType"] == "linux" {
-		ostype = "Linux"
-	} else if (*env)["osType"] == "windows" {
-		ostype = "Win"
-	} else {
+func setOsStr(env *map[string]string) (ostype string, arch string) {
+	ostype = NormOS((*env)["osType"])
+	if ostype == "" {
 		ostype = "Mac"
 	}
-	return ostype
+	arch = NormArch((*env)["arch"])
+	if arch == "" {
+		arch = "amd64"
+	}
+	return ostype, arch
 }
 
-func QueryBgetTools(name string, env *map[string]string, BgetToolsPool *[]BgetToolsURLType) (urls, postShellCmd, versions []string) {
-	ostype := setOsStr(env)
-	for i := range *BgetToolsPool {
-		if strings.ReplaceAll(strings.ToLower((*BgetToolsPool)[i].Name), "_", "-") == name {
-			if (*BgetToolsPool)[i].VersionsAPI != "" && strings.Contains((*BgetToolsPool)[i].VersionsAPI, "://github.com") {
-				versions = GitHubVersionSpider((*BgetToolsPool)[i].VersionsAPI, true)
-			} else if (*BgetToolsPool)[i].VersionsAPI != "" && strings.Contains((*BgetToolsPool)[i].VersionsAPI, "://bitbucket.org") {
-				versions = BitbucketVersionSpider((*BgetToolsPool)[i].VersionsAPI)
-			} else if (*BgetToolsPool)[i].URL["Linux"] != nil && strings.Contains((*BgetToolsPool)[i].URL["Linux"][0], "github.com") {
-				versions = GitHubVersionSpider((*BgetToolsPool)[i].URL["Linux"][0], true)
-			} else if strings.Contains((*BgetToolsPool)[i].URL["Linux"][0], "bitbucket.org") {
-				versions = BitbucketVersionSpider((*BgetToolsPool)[i].URL["Linux"][0])
-			} else if (*BgetToolsPool)[i].URL["Mac"] != nil && strings.Contains((*BgetToolsPool)[i].URL["Mac"][0], "github.com") {
-				versions = GitHubVersionSpider((*BgetToolsPool)[i].URL["Mac"][0], true)
-			} else if (*BgetToolsPool)[i].URL["Mac"] != nil && strings.Contains((*BgetToolsPool)[i].URL["Mac"][0], "bitbucket.org") {
-				versions = BitbucketVersionSpider((*BgetToolsPool)[i].URL["Mac"][0])
-			} else if (*BgetToolsPool)[i].URL["Win"] != nil && strings.Contains((*BgetToolsPool)[i].URL["Win"][0], "github.com") {
-				versions = GitHubVersionSpider((*BgetToolsPool)[i].URL["Win"][0], true)
-			} else if (*BgetToolsPool)[i].URL["Win"] != nil && strings.Contains((*BgetToolsPool)[i].URL["Win"][0], "bitbucket.org") {
-				versions = BitbucketVersionSpider((*BgetToolsPool)[i].URL["Win"][0])
-			} else {
-				versions = (*BgetToolsPool)[i].Versions
-			}
-			if (*env)["version"] == "" && len(versions) > 0 {
-				(*env)["version"] = versions[0]
-			}
-			tmpUrls := []string{}
-			for k, v := range *env {
-				kstr := fmt.Sprintf("{{%s}}", k)
-				for j := range (*BgetToolsPool)[i].URL[ostype] {
-					if k == "version" && strings.Contains((*BgetToolsPool)[i].URL[ostype][j], "v{{version}}") && stringo.StrDetect(v, "^v") {
-						v = stringo.StrReplaceAll(v, "^v", "")
-					}
-					(*BgetToolsPool)[i].URL[ostype][j] = strings.Replace((*BgetToolsPool)[i].URL[ostype][j], kstr, v, 10000)
-				}
-				tmpUrls = (*BgetToolsPool)[i].URL[ostype]
-			}
-			urls = append(urls, tmpUrls...)
-			tmp := ""
-			for j := range (*BgetToolsPool)[i].PostShellCmd {
-				for k, v := range *env {
-					kstr := fmt.Sprintf("{{%s}}", k)
-					if tmp == "" {
-						tmp = strings.Replace((*BgetToolsPool)[i].PostShellCmd[j], kstr, v, 10000)
-					} else {
-						tmp = strings.Replace(tmp, kstr, v, 10000)
-					}
-				}
-				postShellCmd = append(postShellCmd, tmp)
-			}
+// keyVersions returns the versions of a key: the tags (newest first) of the
+// VersionProvider of VersionsAPI (or the GitHub/Bitbucket URL), or the static
+// Versions. Pre-release tags and branches are only included if env prerelease
+// and withBranches are yes.
+func keyVersions(api string, regex string, urls []string, static []string, env *map[string]string) (versions []string, fetched bool) {
+	for i, u := range append([]string{api}, urls...) {
+		p, ref := FindVersionProvider(u)
+		if _, ok := p.(ListingProvider); ok && i == 0 {
+			p = ListingProvider{Regex: regex}
 		}
-		if len(urls) > 0 {
+		if p == nil && i == 0 && api != "" {
+			log.Warnf("Unsupported VersionsAPI %s (supported: %s).", api, strings.Join(VersionProviderNames(), ", "))
+		}
+		if p == nil || (i > 0 && !isURLVersionProvider(p)) {
+			continue
+		}
+		tags, branches, err := p.Refs(ref)
+		if err != nil {
+			log.Warn(err)
+		}
+		if len(tags)+len(branches) == 0 {
+			log.Warnf("No versions fetched from %s, using the Versions of meta data.", u)
 			break
 		}
+		if (*env)["prerelease"] != "yes" {
+			tags = FilterPrerelease(tags)
+		}
+		versions = SortVersions(tags)
+		if (*env)["withBranches"] == "yes" {
+			versions = append(versions, branches...)
+		}
+		return versions, true
+	}
+	return static, false
+}
+
+func isURLVersionProvider(p VersionProvider) bool {
+	for _, name := range urlVersionProviders {
+		if p.Name() == name {
+			return true
+		}
 	}
-	return urls, postShellCmd, versions
+	return false
 }
 
-func formatURL(tmp string, key string, rep string, url string) string {
-	kstr := fmt.Sprintf("{{%s}}", key)
-	if tmp == "" {
-		tmp = strings.Replace(url,
-			kstr, rep, 10000)
-	} else {
-		tmp = strings.Replace(tmp,
-			kstr, rep, 10000)
+// KeyAssetFilter returns the AssetsInclude and AssetsExclude of a key
+func KeyAssetFilter(name string, BgetToolsPool *[]BgetToolsURLType, BgetFilesPool *[]BgetFilesURLType) (include, exclude []string) {
+	for _, t := range *BgetToolsPool {
+		if strings.ReplaceAll(strings.ToLower(t.Name), "_", "-") == name {
+			include = append(include, t.AssetsInclude...)
+			exclude = append(exclude, t.AssetsExclude...)
+		}
+	}
+	for _, f := range *BgetFilesPool {
+		if strings.ReplaceAll(strings.ToLower(f.Name), "_", "-") == name {
+			include = append(include, f.AssetsInclude...)
+			exclude = append(exclude, f.AssetsExclude...)
+		}
 	}
-	return tmp
+	return include, exclude
 }
 
-func formatURLSlice(tmpSlice []string, env *map[string]string) (urls []string) {
-	chrom := []string{}
-	for i := 1; i < 23; i++ {
-		chrom = append(chrom, strconv.Itoa(i))
+// resolveEnvVersion resolves the version constraint of env (e.g. >=1.2)
+func resolveEnvVersion(env *map[string]string, versions []string, fetched bool) error {
+	v, err := ResolveVersion((*env)["version"], versions, !fetched, (*env)["prerelease"] == "yes")
+	if err != nil {
+		return err
 	}
-	chrom = append(chrom, "X", "Y", "MT")
-	for _, v := range tmpSlice {
-		if stringo.StrDetect(v, "{{chrom}}") {
-			raw := v
-			for k := range chrom {
-				v = strings.Replace(raw, "{{chrom}}", chrom[k], 10000)
-				urls = append(urls, v)
+	(*env)["version"] = v
+	return nil
+}
+
+// QueryBgetTools renders the URLs and post shell commands of a tools key
+func QueryBgetTools(name string, env *map[string]string, BgetToolsPool *[]BgetToolsURLType) (urls, postShellCmd, versions []string, err error) {
+	ostype, arch := setOsStr(env)
+	for i := range *BgetToolsPool {
+		if strings.ReplaceAll(strings.ToLower((*BgetToolsPool)[i].Name), "_", "-") == name {
+			firstURLs := []string{}
+			for _, k := range OsKeys {
+				if len((*BgetToolsPool)[i].URL[k]) > 0 {
+					firstURLs = append(firstURLs, (*BgetToolsPool)[i].URL[k][0])
+				}
+			}
+			var fetched bool
+			versions, fetched = listVersions((*BgetToolsPool)[i].VersionsAPI, (*BgetToolsPool)[i].VersionsRegex, firstURLs, (*BgetToolsPool)[i].Versions, env,
+				(*BgetToolsPool)[i].Vars)
+			if err := resolveEnvVersion(env, versions, fetched); err != nil {
+				return nil, nil, versions, fmt.Errorf("%s: %v", name, err)
+			}
+			if err := resolveListedVars(env, (*BgetToolsPool)[i].Vars); err != nil {
+				return nil, nil, versions, fmt.Errorf("%s: %v", name, err)
+			}
+			envNew, err := ResolveVars(*env, (*BgetToolsPool)[i].Vars)
+			if err != nil {
+				return nil, nil, versions, fmt.Errorf("%s: %v", name, err)
+			}
+			urlKey, tpls := SelectURLs((*BgetToolsPool)[i].URL, ostype, arch)
+			if len(tpls) == 0 {
+				return nil, nil, versions, fmt.Errorf("%s: no URLs for %s/%s (available: %s)", name, ostype, arch,
+					strings.Join(SortedURLKeys((*BgetToolsPool)[i].URL), ", "))
+			} else if urlKey == ostype && arch != "amd64" && arch != "386" {
+				log.Warnf("%s: no %s/%s URLs, using the %s URLs.", name, ostype, arch, ostype)
+			}
+			for _, tpl := range tpls {
+				tmp, err := RenderURL(tpl, envNew, (*BgetToolsPool)[i].Vars)
+				if err != nil {
+					return nil, nil, versions, fmt.Errorf("%s: %v", name, err)
+				}
+				urls = append(urls, tmp...)
+			}
+			for j := range (*BgetToolsPool)[i].PostShellCmd {
+				postShellCmd = append(postShellCmd, RenderShellCmd((*BgetToolsPool)[i].PostShellCmd[j], &envNew))
 			}
 		}
-		if !stringo.StrDetect(v, "{{chrom}}") {
-			urls = append(urls, v)
+		if len(urls) > 0 {
+			break
 		}
 	}
-	return urls
+	return urls, postShellCmd, versions, nil
 }
 
-func QueryBgetFiles(name string, env *map[string]string, BgetFilesPool *[]BgetFilesURLType) (urls []string, postShellCmd []string, versions []string) {
+// QueryBgetFiles renders the URLs and post shell commands of a files key
+func QueryBgetFiles(name string, env *map[string]string, BgetFilesPool *[]BgetFilesURLType) (urls []string, postShellCmd []string, versions []string, err error) {
 	for f := range *BgetFilesPool {
 		if strings.ReplaceAll(strings.ToLower((*BgetFilesPool)[f].Name), "_", "-") == name {
-			if (*BgetFilesPool)[f].VersionsAPI != "" && strings.Contains((*BgetFilesPool)[f].VersionsAPI, "github.com") {
-				versions = GitHubVersionSpider((*BgetFilesPool)[f].VersionsAPI, true)
-			} else if (*BgetFilesPool)[f].VersionsAPI != "" && strings.Contains((*BgetFilesPool)[f].VersionsAPI, "bitbucket.org") {
-				versions = BitbucketVersionSpider((*BgetFilesPool)[f].VersionsAPI)
-			} else if (*BgetFilesPool)[f].URL != nil && strings.Contains((*BgetFilesPool)[f].URL[0], "github.com") {
-				versions = GitHubVersionSpider((*BgetFilesPool)[f].URL[0], true)
-			} else if (*BgetFilesPool)[f].URL != nil && strings.Contains((*BgetFilesPool)[f].URL[0], "bitbucket.org") {
-				versions = BitbucketVersionSpider((*BgetFilesPool)[f].URL[0])
-			} else {
-				versions = (*BgetFilesPool)[f].Versions
+			firstURLs := []string{}
+			if len((*BgetFilesPool)[f].URL) > 0 {
+				firstURLs = append(firstURLs, (*BgetFilesPool)[f].URL[0])
 			}
-			if (*env)["version"] == "" && len(versions) > 0 {
-				(*env)["version"] = versions[0]
+			var fetched bool
+			versions, fetched = listVersions((*BgetFilesPool)[f].VersionsAPI, (*BgetFilesPool)[f].VersionsRegex, firstURLs, (*BgetFilesPool)[f].Versions, env,
+				(*BgetFilesPool)[f].Vars)
+			if err := resolveEnvVersion(env, versions, fetched); err != nil {
+				return nil, nil, versions, fmt.Errorf("%s: %v", name, err)
 			}
-			for _, url := range (*BgetFilesPool)[f].URL {
-				(*env)["version"] = genomeVersionConvertor(url, (*env)["version"])
-				tmp := ""
-				tmpSlice := []string{}
-				for k, v := range *env {
-					if strings.Contains(v, ",") {
-						v = stringo.StrReplaceAll(v, " ", "")
-						vSlice := strings.Split(v, ",")
-						for _, v2 := range vSlice {
-							tmpSlice = append(tmpSlice, formatURL(tmp, k, v2, url))
-						}
-					} else {
-						tmp = formatURL(tmp, k, v, url)
-					}
-					for k2 := range tmpSlice {
-						tmpSlice[k2] = formatURL(tmpSlice[k2], k, v, url)
-					}
+			if err := resolveListedVars(env, (*BgetFilesPool)[f].Vars); err != nil {
+				return nil, nil, versions, fmt.Errorf("%s: %v", name, err)
+			}
+			envNew, err := ResolveVars(*env, (*BgetFilesPool)[f].Vars)
+			if err != nil {
+				return nil, nil, versions, fmt.Errorf("%s: %v", name, err)
+			}
+			for _, tpl := range (*BgetFilesPool)[f].URL {
+				envURL := make(map[string]string)
+				for k, v := range envNew {
+					envURL[k] = v
 				}
-				if len(tmpSlice) == 0 {
-					tmpSlice = append(tmpSlice, tmp)
+				envURL["version"] = genomeVersionConvertor(tpl, envURL["version"])
+				tmp, err := RenderURL(tpl, envURL, (*BgetFilesPool)[f].Vars)
+				if err != nil {
+					return nil, nil, versions, fmt.Errorf("%s: %v", name, err)
 				}
-				urls = append(urls, formatURLSlice(tmpSlice, env)...)
+				urls = append(urls, tmp...)
 			}
 			for j := range (*BgetFilesPool)[f].PostShellCmd {
-				tmp := ""
-				for k, v := range *env {
-					kstr := fmt.Sprintf("{{%s}}", k)
-					if tmp == "" {
-						tmp = strings.Replace((*BgetFilesPool)[f].PostShellCmd[j],
-							kstr, v, 10000)
-					} else {
-						tmp = strings.Replace(tmp,
-							kstr, v, 10000)
-					}
-				}
-				postShellCmd = append(postShellCmd, tmp)
+				postShellCmd = append(postShellCmd, RenderShellCmd((*BgetFilesPool)[f].PostShellCmd[j], &envNew))
 			}
 		}
 		if len(urls) > 0 {
 			break
 		}
 	}
-	return urls, postShellCmd, versions
+	return urls, postShellCmd, versions, nil
 }
 
 func genomeVersionConvertor(url string, version string) string {
@@ -221,107 +256,51 @@ func genomeVersionConvertor(url string, version string) string {
 	return version
 }
 
-func setGitHubCtx(url string) (user, repo string, ctx context.Context, client *github.Client, opt *github.ListOptions) {
-	accessToken := os.Getenv("GITHUB_TOKEN")
-	if accessToken == "" {
-		log.Fatal("Please set GITHUB_TOKEN environment variable.")
-	}
+// BitbucketRefsSpider gets the tags and branches of a Bitbucket repo
+func BitbucketRefsSpider(url string) (tags []string, branches []string) {
 	u, err := neturl.Parse(url)
 	if err != nil {
-		log.Fatal(err)
-	}
-	if u.Host != "github.com" {
+		log.Warn(err)
 		return
 	}
 	pathStr := strings.Split(u.Path, "/")
-	user, repo = pathStr[1], pathStr[2]
-	ctx = context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: accessToken},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	client = github.NewClient(tc)
-	opt = &github.ListOptions{}
-	return user, repo, ctx, client, opt
-}
-
-// GitHubVersionSpider get all tags and branch
-func GitHubVersionSpider(url string, includeBranches bool) (versions []string) {
-	user, repo, ctx, client, opt := setGitHubCtx(url)
-	vers, _, err := client.Repositories.ListTags(ctx, user, repo, opt)
-	if err != nil {
-		log.Fatal(err)
-	}
-	brchs, _, err := client.Repositories.ListBranches(ctx, user, repo, opt)
-	if err != nil {
-		log.Fatal(err)
-	}
-	for i := range vers {
-		versions = append(versions, vers[i].GetName())
-	}
-	if includeBranches {
-		for i := range brchs {
-			versions = append(versions, brchs[i].GetName())
-		}
-	}
-	return versions
-}
-
-func GitHubAssetsSpider(url, version string) (urls []string) {
-	user, repo, ctx, client, opt := setGitHubCtx(url)
-	rels, _, err := client.Repositories.ListReleases(ctx, user, repo, opt)
-	if err != nil {
-		log.Fatal(err)
-	}
-	for i := range rels {
-		if rels[i].GetTagName() == version {
-			assets, _, err := client.Repositories.ListReleaseAssets(ctx, user, repo, rels[i].GetID(), opt)
-			if err != nil {
-				log.Fatal(err)
-			}
-			for j := range assets {
-				urls = append(urls, assets[j].GetBrowserDownloadURL())
-			}
-			return urls
-		}
-	}
-	return urls
-}
-
-// BitbucketVersionSpider query Bitbucket versions
-func BitbucketVersionSpider(url string) (versions []string) {
-	u, err := neturl.Parse(url)
-	if err != nil {
-		log.Fatal(err)
-	}
-	if u.Host != "bitbucket.org" {
+	if u.Host != "bitbucket.org" || len(pathStr) < 3 {
 		return
 	}
-	pathStr := strings.Split(u.Path, "/")
 	user, repo := pathStr[1], pathStr[2]
 	tagsBody := bitbucketRepoAPI(user, repo, "tags")
 	var s BitbucketObj
 	json.Unmarshal(tagsBody, &s)
 	for i := range s.Values {
-		versions = append(versions, s.Values[i].Name)
+		tags = append(tags, s.Values[i].Name)
 	}
-	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+	var b BitbucketObj
 	brcsBody := bitbucketRepoAPI(user, repo, "branches")
-	json.Unmarshal(brcsBody, &s)
-	for i := range s.Values {
-		versions = append(versions, s.Values[i].Name)
+	json.Unmarshal(brcsBody, &b)
+	for i := range b.Values {
+		branches = append(branches, b.Values[i].Name)
 	}
-	return versions
+	return tags, branches
+}
+
+// BitbucketVersionSpider query Bitbucket versions (tags newest first and branches)
+func BitbucketVersionSpider(url string) (versions []string) {
+	tags, branches := BitbucketRefsSpider(url)
+	return append(SortVersions(tags), branches...)
 }
 
 func bitbucketRepoAPI(user, repo, entry string) []byte {
 	url := fmt.Sprintf("https://api.bitbucket.org/2.0/repositories/%s/%s/refs/%s", user, repo, entry)
-	resp, err := http.Get(url)
+	resp, err := versionHTTPClient(nil).Get(url)
 	if err != nil {
 		log.Warn(err)
 		return nil
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Warnf("Failed to get %s of %s/%s: %s", entry, user, repo, resp.Status)
+		return nil
+	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		log.Warn(err)
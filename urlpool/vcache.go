@@ -0,0 +1,224 @@
+package urlpool
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// VersionCacheT is the on-disk cache of version API responses (GitHub and
+// Bitbucket tags/branches), Dir is empty to disable the cache
+type VersionCacheT struct {
+	Dir string
+	TTL time.Duration
+}
+
+// VersionCache is the cache used by the version spiders
+var VersionCache = &VersionCacheT{TTL: 6 * time.Hour}
+
+// rateLimitWarn is the remaining requests to warn the API budget
+const rateLimitWarn = 10
+
+type cachedResponse struct {
+	URL  string
+	ETag string
+	Link string
+	Body []byte
+	Time time.Time
+}
+
+// rateLimitT records the API budget per host
+type rateLimitT struct {
+	sync.Mutex
+	remaining map[string]int
+	reset     map[string]time.Time
+	warned    map[string]bool
+}
+
+var rateLimits = &rateLimitT{remaining: make(map[string]int), reset: make(map[string]time.Time),
+	warned: make(map[string]bool)}
+
+func (c *VersionCacheT) file(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return path.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *VersionCacheT) load(url string) *cachedResponse {
+	if c.Dir == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(c.file(url))
+	if err != nil {
+		return nil
+	}
+	cached := &cachedResponse{}
+	if err := json.Unmarshal(data, cached); err != nil || cached.URL != url {
+		return nil
+	}
+	return cached
+}
+
+func (c *VersionCacheT) save(cached *cachedResponse) {
+	if c.Dir == "" {
+		return
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		log.Warn(err)
+		return
+	}
+	data, _ := json.Marshal(cached)
+	if err := ioutil.WriteFile(c.file(cached.URL), data, 0644); err != nil {
+		log.Warn(err)
+	}
+}
+
+func (cached *cachedResponse) response(req *http.Request) *http.Response {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-From-Cache", "1")
+	if cached.Link != "" {
+		header.Set("Link", cached.Link)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+		Request:       req,
+	}
+}
+
+// updateRateLimit records X-RateLimit-Remaining/Reset of resp and warns
+// if the budget is low
+func updateRateLimit(host string, resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset := time.Now().Add(time.Hour)
+	if sec, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		reset = time.Unix(sec, 0)
+	}
+	rateLimits.Lock()
+	defer rateLimits.Unlock()
+	rateLimits.remaining[host] = remaining
+	rateLimits.reset[host] = reset
+	if remaining < rateLimitWarn && !rateLimits.warned[host] {
+		rateLimits.warned[host] = true
+		log.Warnf("%s API rate limit: %d requests left (reset at %s).", host, remaining, reset.Format("15:04:05"))
+	}
+}
+
+// rateLimited returns true if the API budget of host is used up
+func rateLimited(host string) (bool, time.Time) {
+	rateLimits.Lock()
+	defer rateLimits.Unlock()
+	remaining, ok := rateLimits.remaining[host]
+	return ok && remaining <= 0 && time.Now().Before(rateLimits.reset[host]), rateLimits.reset[host]
+}
+
+// cacheTransport serves GET requests of version APIs from VersionCache:
+// fresh responses (within TTL) are used directly, stale ones are revalidated
+// with ETag and used when the API fails or the rate limit is exceeded.
+type cacheTransport struct {
+	base http.RoundTripper
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Method != "GET" {
+		return base.RoundTrip(req)
+	}
+	url := req.URL.String()
+	host := req.URL.Host
+	cached := VersionCache.load(url)
+	if cached != nil && time.Since(cached.Time) < VersionCache.TTL {
+		return cached.response(req), nil
+	}
+	if limited, reset := rateLimited(host); limited {
+		if cached != nil {
+			log.Warnf("%s API rate limit exceeded (reset at %s), using cached versions.", host, reset.Format("15:04:05"))
+			return cached.response(req), nil
+		}
+		return nil, fmt.Errorf("%s API rate limit exceeded (reset at %s)", host, reset.Format("15:04:05"))
+	}
+	if cached != nil && cached.ETag != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		if cached != nil {
+			log.Warnf("%v, using cached versions.", err)
+			return cached.response(req), nil
+		}
+		return nil, err
+	}
+	updateRateLimit(host, resp)
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		resp.Body.Close()
+		cached.Time = time.Now()
+		VersionCache.save(cached)
+		return cached.response(req), nil
+	case resp.StatusCode == http.StatusOK:
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		VersionCache.save(&cachedResponse{URL: url, ETag: resp.Header.Get("ETag"), Link: resp.Header.Get("Link"),
+			Body: body, Time: time.Now()})
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	case (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) && cached != nil:
+		resp.Body.Close()
+		log.Warnf("%s API returned %s, using cached versions.", host, resp.Status)
+		return cached.response(req), nil
+	}
+	return resp, nil
+}
+
+// versionHTTPClient returns a client of version APIs with VersionCache
+func versionHTTPClient(client *http.Client) *http.Client {
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	client.Transport = &cacheTransport{base: client.Transport}
+	return client
+}
+
+// cachedFetch caches the data of non-HTTP version sources (e.g. FTP
+// listings) in VersionCache, the stale data is used if fetch fails
+func cachedFetch(url string, fetch func() ([]byte, error)) ([]byte, error) {
+	cached := VersionCache.load(url)
+	if cached != nil && time.Since(cached.Time) < VersionCache.TTL {
+		return cached.Body, nil
+	}
+	body, err := fetch()
+	if err != nil {
+		if cached != nil {
+			log.Warnf("%v, using cached versions.", err)
+			return cached.Body, nil
+		}
+		return nil, err
+	}
+	VersionCache.save(&cachedResponse{URL: url, Body: body, Time: time.Now()})
+	return body, nil
+}
@@ -0,0 +1,64 @@
+package urlpool
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCacheTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bget-vcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldCache := VersionCache
+	defer func() { VersionCache = oldCache }()
+	VersionCache = &VersionCacheT{Dir: dir, TTL: time.Hour}
+
+	hits, status := 0, http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`["v1.0"]`))
+	}))
+	defer srv.Close()
+
+	get := func() string {
+		resp, err := versionHTTPClient(nil).Get(srv.URL + "/tags")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+	if body := get(); body != `["v1.0"]` || hits != 1 {
+		t.Fatalf("first request: body=%s hits=%d", body, hits)
+	}
+	// fresh cache within TTL
+	if body := get(); body != `["v1.0"]` || hits != 1 {
+		t.Fatalf("cached request: body=%s hits=%d", body, hits)
+	}
+	// stale cache is revalidated with ETag
+	VersionCache.TTL = 0
+	if body := get(); body != `["v1.0"]` || hits != 2 {
+		t.Fatalf("revalidated request: body=%s hits=%d", body, hits)
+	}
+	// rate limited API falls back to the stale cache
+	status = http.StatusForbidden
+	if body := get(); body != `["v1.0"]` || hits != 3 {
+		t.Fatalf("rate limited request: body=%s hits=%d", body, hits)
+	}
+}
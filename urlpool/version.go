@@ -0,0 +1,324 @@
+package urlpool
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var versionCoreRe = regexp.MustCompile(`^\D*?(\d+(?:[._]\d+)*)(.*)$`)
+var preReleaseRe = regexp.MustCompile(`(?i)(alpha|beta|rc|pre|dev|preview|snapshot|nightly)`)
+
+// shortPreReleaseRe matches a and b pre-releases (2.0b1, 1.0a), only of
+// dotted versions, dbnsfp35a is a data release
+var shortPreReleaseRe = regexp.MustCompile(`(?i)(^[ab]\d*$|^[.-]?[ab]\d+)`)
+
+// parseVersion splits v (e.g. v1.2.3-rc1) into the numeric core and the rest
+func parseVersion(v string) (core []int, rest string, ok bool) {
+	m := versionCoreRe.FindStringSubmatch(v)
+	if m == nil {
+		return nil, v, false
+	}
+	for _, s := range strings.FieldsFunc(m[1], func(r rune) bool { return r == '.' || r == '_' }) {
+		n, _ := strconv.Atoi(s)
+		core = append(core, n)
+	}
+	return core, m[2], true
+}
+
+// IsPrerelease returns true for pre-release versions (e.g. 1.0-rc1, 2.0b1)
+func IsPrerelease(v string) bool {
+	core, rest, ok := parseVersion(v)
+	if !ok || rest == "" {
+		return false
+	}
+	return preReleaseRe.MatchString(rest) || (len(core) > 1 && shortPreReleaseRe.MatchString(rest))
+}
+
+var monthDateRe = regexp.MustCompile(`(?i)^(.*?)((?:\d{1,2}[-_ ]?)?(?:jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*[-_ ]?(?:\d{1,2}[-_, ]+)?\d{4})$`)
+var monthDateLayouts = []string{"Jan2006", "Jan-2006", "January2006", "January-2006", "02Jan2006", "02-Jan-2006",
+	"2-Jan-2006", "Jan-02-2006", "Jan-2-2006", "January-2-2006"}
+
+// parseMonthDate parses versions with month names (e.g. Jun2020,
+// 15-Jun-2020) that are not ordered by the numbers, it returns the prefix
+// and the date
+func parseMonthDate(v string) (prefix string, t time.Time, ok bool) {
+	m := monthDateRe.FindStringSubmatch(v)
+	if m == nil {
+		return "", t, false
+	}
+	date := strings.NewReplacer("_", "-", " ", "-", ",", "").Replace(m[2])
+	for _, layout := range monthDateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return m[1], t, true
+		}
+	}
+	return "", t, false
+}
+
+// CompareVersions compares two versions by semver-like natural ordering
+// (e.g. 1.10 > 1.9 > 1.9-rc1) or by dates with month names (Jun2020 >
+// Dec2019), it returns -1, 0 or 1
+func CompareVersions(a string, b string) int {
+	if pa, ta, oka := parseMonthDate(a); oka {
+		if pb, tb, okb := parseMonthDate(b); okb && pa == pb {
+			switch {
+			case ta.Before(tb):
+				return -1
+			case ta.After(tb):
+				return 1
+			}
+			return 0
+		}
+	}
+	ca, ra, oka := parseVersion(a)
+	cb, rb, okb := parseVersion(b)
+	if !oka || !okb {
+		switch {
+		case oka:
+			return 1
+		case okb:
+			return -1
+		}
+		return strings.Compare(a, b)
+	}
+	for i := 0; i < len(ca) || i < len(cb); i++ {
+		x, y := 0, 0
+		if i < len(ca) {
+			x = ca[i]
+		}
+		if i < len(cb) {
+			y = cb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	pa, pb := IsPrerelease(a), IsPrerelease(b)
+	switch {
+	case pa && !pb:
+		return -1
+	case !pa && pb:
+		return 1
+	}
+	return strings.Compare(ra, rb)
+}
+
+// SortVersions sorts versions from the newest to the oldest
+func SortVersions(versions []string) []string {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return CompareVersions(versions[i], versions[j]) > 0
+	})
+	return versions
+}
+
+// FilterPrerelease removes the pre-release versions
+func FilterPrerelease(versions []string) (stable []string) {
+	for _, v := range versions {
+		if !IsPrerelease(v) {
+			stable = append(stable, v)
+		}
+	}
+	return stable
+}
+
+// IsVersionConstraint returns true if v is a constraint (e.g. >=1.2, ~1.2,
+// ^1.2, latest) rather than an exact version (or a list of versions)
+func IsVersionConstraint(v string) bool {
+	v = strings.TrimSpace(v)
+	return v == "latest" || (v != "" && strings.ContainsAny(v[:1], "<>=~^!") && !isRegexSelector(v))
+}
+
+// tildeConstraintRe matches the tilde constraints (~1.2, ~ v1.2.3), ~
+// followed by anything else is a regex (~^avsnp)
+var tildeConstraintRe = regexp.MustCompile(`^~\s*[vV]?\d`)
+
+// isRegexSelector returns true for ~<regex> (not a tilde constraint) and
+// re:<regex>
+func isRegexSelector(v string) bool {
+	return strings.HasPrefix(v, "re:") || (strings.HasPrefix(v, "~") && !tildeConstraintRe.MatchString(v))
+}
+
+// IsVersionSelector returns true if v selects multiple versions: a glob
+// (clinvar_*), a regex (~^avsnp or re:^avsnp) or newest:N (optionally with a
+// glob or regex, e.g. newest:3:clinvar_*)
+func IsVersionSelector(v string) bool {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "newest:") || isRegexSelector(v) {
+		return true
+	}
+	return !IsVersionConstraint(v) && strings.ContainsAny(v, "*?[")
+}
+
+// SelectVersions returns the versions matched a selector of IsVersionSelector,
+// globs and regexes keep the order of versions and newest:N is newest first.
+// Pre-releases are only skipped by newest:N without a glob or regex.
+func SelectVersions(selector string, versions []string, pre bool) (selected []string, err error) {
+	selector = strings.TrimSpace(selector)
+	newest := 0
+	if strings.HasPrefix(selector, "newest:") {
+		fields := strings.SplitN(selector, ":", 3)
+		if newest, err = strconv.Atoi(fields[1]); err != nil || newest < 1 {
+			return nil, fmt.Errorf("invalid version selector %q (e.g. newest:3)", selector)
+		}
+		selector = ""
+		if len(fields) == 3 {
+			selector = fields[2]
+		}
+	}
+	var re *regexp.Regexp
+	if isRegexSelector(selector) {
+		expr := strings.TrimPrefix(strings.TrimPrefix(selector, "re:"), "~")
+		if re, err = regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("invalid version regex %q: %v", expr, err)
+		}
+	} else if selector != "" {
+		if _, err = path.Match(selector, ""); err != nil {
+			return nil, fmt.Errorf("invalid version glob %q: %v", selector, err)
+		}
+	}
+	for _, v := range versions {
+		if !pre && selector == "" && IsPrerelease(v) {
+			continue
+		}
+		matched := selector == ""
+		if re != nil {
+			matched = re.MatchString(v)
+		} else if selector != "" {
+			matched, _ = path.Match(selector, v)
+		}
+		if matched {
+			selected = append(selected, v)
+		}
+	}
+	if newest > 0 {
+		selected = SortVersions(selected)
+		if len(selected) > newest {
+			selected = selected[:newest]
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no version matches %q", selector)
+	}
+	return selected, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// bumpVersion returns the upper bound of ~ (minor, ~1.2.3 < 1.3) and ^
+// (major, ^1.2 < 2, ^0.2 < 0.3) constraints
+func bumpVersion(v string, op string) string {
+	core, _, _ := parseVersion(v)
+	if len(core) == 0 {
+		return v
+	}
+	idx := 0
+	if op == "~" && len(core) > 1 {
+		idx = 1
+	} else if op == "^" && core[0] == 0 && len(core) > 1 {
+		idx = 1
+	}
+	core[idx]++
+	parts := []string{}
+	for _, n := range core[:idx+1] {
+		parts = append(parts, strconv.Itoa(n))
+	}
+	return strings.Join(parts, ".")
+}
+
+// MatchConstraint checks v against a constraint, e.g. >=0.7.17, <2,
+// ~1.2 (>=1.2 <1.3), ^1.2 (>=1.2 <2) and >=1.0,<2.0
+func MatchConstraint(v string, constraint string) (bool, error) {
+	for _, c := range strings.Split(constraint, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" || c == "latest" || c == "*" {
+			continue
+		}
+		op := strings.TrimRight(c[:min(len(c), 2)], "0123456789vV. ")
+		want := strings.TrimSpace(c[len(op):])
+		if want == "" {
+			return false, fmt.Errorf("invalid version constraint %q", c)
+		}
+		cmp := CompareVersions(v, want)
+		ok := false
+		switch op {
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		case "<=":
+			ok = cmp <= 0
+		case "<":
+			ok = cmp < 0
+		case "=", "==", "":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case "~", "^":
+			ok = cmp >= 0 && CompareVersions(v, bumpVersion(want, op)) < 0
+		default:
+			return false, fmt.Errorf("invalid version constraint %q", c)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ResolveVersion resolves a version constraint against versions. An exact
+// version (or list) is returned as is, a selector of IsVersionSelector is
+// resolved into a comma separated list; an empty constraint selects the first static
+// version (the order of meta data) or the newest fetched version. Static
+// versions are not pre-releases.
+func ResolveVersion(constraint string, versions []string, static bool, pre bool) (string, error) {
+	constraint = strings.TrimSpace(constraint)
+	pre = pre || static
+	if IsVersionSelector(constraint) {
+		selected, err := SelectVersions(constraint, versions, pre)
+		return strings.Join(selected, ","), err
+	}
+	if constraint != "" && !IsVersionConstraint(constraint) {
+		return constraint, nil
+	}
+	if len(versions) == 0 {
+		if constraint == "" || constraint == "latest" {
+			return constraint, nil
+		}
+		return "", fmt.Errorf("no known versions to resolve %s", constraint)
+	}
+	if constraint == "" && static {
+		return versions[0], nil
+	}
+	candidates := []string{}
+	for _, v := range versions {
+		if !pre && IsPrerelease(v) {
+			continue
+		}
+		if ok, err := MatchConstraint(v, constraint); err != nil {
+			return "", err
+		} else if ok {
+			candidates = append(candidates, v)
+		}
+	}
+	if len(candidates) == 0 {
+		shown := versions
+		if len(shown) > 10 {
+			shown = shown[:10]
+		}
+		return "", fmt.Errorf("no version matches %q (available: %s)", constraint, strings.Join(shown, ", "))
+	}
+	return SortVersions(candidates)[0], nil
+}
@@ -0,0 +1,105 @@
+package urlpool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortVersions(t *testing.T) {
+	got := SortVersions([]string{"v0.7.9", "0.7.17", "v0.7.17-rc1", "master", "0.7.10"})
+	want := []string{"0.7.17", "v0.7.17-rc1", "0.7.10", "v0.7.9", "master"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestResolveVersion(t *testing.T) {
+	versions := []string{"1.10", "1.9", "1.2.1", "1.2", "2.0-beta1", "0.9"}
+	for _, v := range []struct {
+		constraint string
+		pre        bool
+		want       string
+	}{
+		{"", false, "1.10"},
+		{"latest", true, "2.0-beta1"},
+		{">=1.2,<1.9", false, "1.2.1"},
+		{"~1.2", false, "1.2.1"},
+		{"^0.9", false, "0.9"},
+		{"1.9", false, "1.9"},
+	} {
+		got, err := ResolveVersion(v.constraint, versions, false, v.pre)
+		if err != nil || got != v.want {
+			t.Errorf("%s: got %q (%v), want %q", v.constraint, got, err, v.want)
+		}
+	}
+	if _, err := ResolveVersion(">=3", versions, false, false); err == nil {
+		t.Error("expected error of unmatched constraint")
+	}
+	if got, _ := ResolveVersion("", []string{"STAR", "1.0"}, true, false); got != "STAR" {
+		t.Errorf("static versions: got %q, want STAR", got)
+	}
+}
+
+func TestIsVersionConstraint(t *testing.T) {
+	for v, want := range map[string]bool{">=1.2,<1.9": true, "~1.2": true, "latest": true, "1.9": false, "~^avsnp": false, "1.9,1.10": false, "clinvar_20131105,avsnp150": false} {
+		if got := IsVersionConstraint(v); got != want {
+			t.Errorf("IsVersionConstraint(%s) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestSelectVersions(t *testing.T) {
+	versions := []string{"clinvar_20131105", "clinvar_20180603", "clinvar_20170501", "avsnp150", "avsnp147", "cosmic70", "dbnsfp35a", "dbnsfp30a"}
+	for _, v := range []struct {
+		selector string
+		want     []string
+	}{
+		{"clinvar_2017*", []string{"clinvar_20170501"}},
+		{"~^avsnp", []string{"avsnp150", "avsnp147"}},
+		{"re:^avsnp", []string{"avsnp150", "avsnp147"}},
+		{"dbnsfp3*", []string{"dbnsfp35a", "dbnsfp30a"}},
+		{"~^clinvar_2018|^dbnsfp35", []string{"clinvar_20180603", "dbnsfp35a"}},
+		{"newest:2:clinvar_*", []string{"clinvar_20180603", "clinvar_20170501"}},
+		{"newest:1:re:^clinvar_2017", []string{"clinvar_20170501"}},
+	} {
+		if !IsVersionSelector(v.selector) {
+			t.Errorf("%s should be a selector", v.selector)
+		}
+		got, err := SelectVersions(v.selector, versions, false)
+		if err != nil || !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%s: got %v (%v), want %v", v.selector, got, err, v.want)
+		}
+	}
+	for _, v := range []string{"~1.2", "~ v1.2.3", "1.2"} {
+		if IsVersionSelector(v) {
+			t.Errorf("%s is not a selector", v)
+		}
+	}
+	if got, _ := SelectVersions("newest:1", []string{"1.0", "2.0b1"}, false); !reflect.DeepEqual(got, []string{"1.0"}) {
+		t.Errorf("newest:1 should skip 2.0b1: got %v", got)
+	}
+	if _, err := SelectVersions("~(", versions, false); err == nil {
+		t.Error("expected an error of the invalid regex")
+	}
+}
+
+func TestIsPrerelease(t *testing.T) {
+	for v, want := range map[string]bool{"1.0-rc1": true, "2.0b1": true, "1.0a": true, "v3.0.0-beta.2": true,
+		"dbnsfp35a": false, "dbnsfp30a": false, "hg38": false, "1.10": false} {
+		if got := IsPrerelease(v); got != want {
+			t.Errorf("IsPrerelease(%s) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestSortDateVersions(t *testing.T) {
+	got := SortVersions([]string{"release_Dec2019", "release_Jun2020", "release_Feb2020"})
+	want := []string{"release_Jun2020", "release_Feb2020", "release_Dec2019"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	got = SortVersions([]string{"2019-12-01", "2020-06-15", "2020-02-01"})
+	if got[0] != "2020-06-15" || got[2] != "2019-12-01" {
+		t.Errorf("ISO dates: got %v", got)
+	}
+}
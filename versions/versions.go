@@ -10,38 +10,20 @@ import (
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/clindet/bget/urlpool"
+	glog "github.com/openbiox/ligo/log"
 	"github.com/openbiox/ligo/stringo"
 )
 
-// DefaultVersions set default key string versions
-func DefaultVersions(key string, env *map[string]string) {
-	if (*env)["version"] == "" && strings.Contains(key, "miniconda") {
-		(*env)["version"] = "latest"
-	}
-	if key == "reffa" && (*env)["site"] == "" {
-		(*env)["site"] = "genecode"
-	}
-	if key == "reffa" && (*env)["version"] == "" &&
-		((*env)["site"] == "genecode" || (*env)["site"] == "ensemble" ||
-			(*env)["site"] == "defuse") {
-		(*env)["version"] = "GRCh38"
-	} else if key == "reffa" && (*env)["version"] == "" {
-		(*env)["version"] = "hg38"
-	}
-	if key == "reffa" && (*env)["site"] == "genecode" && (*env)["release"] == "" {
-		(*env)["release"] = "34"
-	} else if key == "reffa" && (*env)["site"] == "ensemble" && (*env)["release"] == "" {
-		(*env)["release"] = "100"
-	}
-}
+var log = glog.Logger
 
-// QueryKeysInfo get keys URL and post shell command
+// QueryKeysInfo get keys URL, post shell command, versions and the resolved version
 func QueryKeysInfo(keys []string, env *map[string]string,
 	BgetToolsPool *[]urlpool.BgetToolsURLType,
-	BgetFilesPool *[]urlpool.BgetFilesURLType) (urls, postShellCmd, vers map[string][]string) {
+	BgetFilesPool *[]urlpool.BgetFilesURLType) (urls, postShellCmd, vers map[string][]string, resolved map[string]string) {
 	urls = make(map[string][]string)
 	postShellCmd = make(map[string][]string)
 	vers = make(map[string][]string)
+	resolved = make(map[string]string)
 	for k := range keys {
 		envNew := make(map[string]string)
 		for k, v := range *env {
@@ -57,36 +39,75 @@ func QueryKeysInfo(keys []string, env *map[string]string,
 		if envNew["release"] == "" {
 			envNew["release"] = release
 		}
-		DefaultVersions(key, &envNew)
-		_, _, defaultVers := urlpool.QueryBgetTools(key, &envNew, BgetToolsPool)
-		if envNew["version"] == "" && len(defaultVers) > 0 {
-			envNew["version"] = defaultVers[0]
+		envTools := make(map[string]string)
+		for k, v := range envNew {
+			envTools[k] = v
+		}
+		tmp, tmp2, defaultVers, err := urlpool.QueryBgetTools(key, &envTools, BgetToolsPool)
+		if err != nil {
+			log.Error(err)
 		}
-		tmp, tmp2, _ := urlpool.QueryBgetTools(key, &envNew, BgetToolsPool)
 		if len(tmp) > 0 {
 			urls[key] = append(urls[key], tmp...)
 			postShellCmd[key] = append(postShellCmd[key], tmp2...)
 			vers[key] = append(vers[key], defaultVers...)
+			resolved[key] = envTools["version"]
 		}
-		_, _, defaultVers = urlpool.QueryBgetFiles(key, &envNew, BgetFilesPool)
-		if envNew["version"] == "" && len(defaultVers) > 0 {
-			envNew["version"] = defaultVers[0]
+		tmp, tmp2, defaultVers, err = urlpool.QueryBgetFiles(key, &envNew, BgetFilesPool)
+		if err != nil {
+			log.Error(err)
 		}
-		tmp, tmp2, _ = urlpool.QueryBgetFiles(key, &envNew, BgetFilesPool)
 		if len(tmp) > 0 {
 			urls[key] = append(urls[key], tmp...)
 			postShellCmd[key] = append(postShellCmd[key], tmp2...)
 			vers[key] = append(vers[key], defaultVers...)
+			if resolved[key] == "" {
+				resolved[key] = envNew["version"]
+			}
+		} else if err != nil && len(vers[key]) == 0 {
+			// e.g. a required variable is not set, the versions are still known
+			vers[key] = append(vers[key], defaultVers...)
 		}
 
-		if len(urls[key]) > 0 && strings.Contains(urls[key][0], "github.com") && envNew["withAssets"] == "yes" {
-			assetsUrls := urlpool.GitHubAssetsSpider(urls[key][0], vers[key][0])
-			if len(assetsUrls) > 0 {
-				urls[key] = append(urls[key], assetsUrls...)
+		if len(urls[key]) > 0 && urlpool.IsGitHubURL(urls[key][0]) && envNew["withAssets"] == "yes" && resolved[key] != "" {
+			assets, err := urlpool.GitHubReleaseAssets(urls[key][0], resolved[key])
+			if err != nil {
+				log.Warn(err)
+			}
+			filter := AssetFilterOfEnv(envNew)
+			include, exclude := urlpool.KeyAssetFilter(key, BgetToolsPool, BgetFilesPool)
+			if len(filter.Include) == 0 {
+				filter.Include = include
+			}
+			filter.Exclude = append(filter.Exclude, exclude...)
+			selected := urlpool.SelectAssets(assets, filter)
+			if len(assets) > 0 && len(selected) == 0 {
+				log.Warnf("No assets of %s@%s matched %s/%s (use --all-assets or --assets-include).", key, resolved[key],
+					envNew["osType"], envNew["arch"])
+			}
+			for _, a := range selected {
+				urls[key] = append(urls[key], a.URL)
 			}
 		}
 	}
-	return urls, postShellCmd, vers
+	return urls, postShellCmd, vers, resolved
+}
+
+// AssetFilterOfEnv returns the asset filter of env: osType, arch,
+// assetsInclude and assetsExclude (comma separated) and allAssets
+func AssetFilterOfEnv(env map[string]string) urlpool.AssetFilter {
+	filter := urlpool.AssetFilter{OS: env["osType"], Arch: env["arch"], All: env["allAssets"] == "yes"}
+	for _, p := range strings.Split(env["assetsInclude"], ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			filter.Include = append(filter.Include, p)
+		}
+	}
+	for _, p := range strings.Split(env["assetsExclude"], ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			filter.Exclude = append(filter.Exclude, p)
+		}
+	}
+	return filter
 }
 
 // QueryKeysVersions get keys versions
@@ -94,9 +115,10 @@ func QueryKeysVersions(keys []string, env *map[string]string,
 	BgetToolsPool *[]urlpool.BgetToolsURLType,
 	BgetFilesPool *[]urlpool.BgetFilesURLType) map[string][]string {
 	versions := make(map[string][]string)
+	resolved := make(map[string]string)
 	table := tablewriter.NewWriter(os.Stdout)
 	if (*env)["PrintFormat"] == "table" {
-		table.SetHeader([]string{"Key", "Versions"})
+		table.SetHeader([]string{"Key", "Resolved", "Versions"})
 		table.SetRowLine(true)
 		table.SetRowSeparator("-")
 		table.SetAlignment(tablewriter.ALIGN_LEFT)
@@ -104,8 +126,9 @@ func QueryKeysVersions(keys []string, env *map[string]string,
 	wg := sync.WaitGroup{}
 	for i := range keys {
 		wg.Add(1)
-		urls, _, vers := QueryKeysInfo([]string{keys[i]}, env, BgetToolsPool, BgetFilesPool)
+		urls, _, vers, res := QueryKeysInfo([]string{keys[i]}, env, BgetToolsPool, BgetFilesPool)
 		key, _, _, _ := ParseMeta(keys[i])
+		resolved[key] = res[key]
 		if len(vers[key]) > 0 {
 			versions[key] = vers[key]
 			wg.Done()
@@ -116,7 +139,11 @@ func QueryKeysVersions(keys []string, env *map[string]string,
 			}
 			url := urls[key][0]
 			go func(url string) {
-				if tmp := urlpool.GitHubVersionSpider(url, true); len(tmp) > 0 {
+				if urlpool.IsGitHubURL(url) {
+					tmp, err := urlpool.GitHubVersionSpider(url, true)
+					if err != nil {
+						log.Warn(err)
+					}
 					versions[key] = tmp
 				} else if tmp := urlpool.BitbucketVersionSpider(url); len(tmp) > 0 {
 					versions[key] = tmp
@@ -129,17 +156,24 @@ func QueryKeysVersions(keys []string, env *map[string]string,
 	for k := range versions {
 		if len(versions[k]) > 0 {
 			if (*env)["PrintFormat"] == "table" {
-				table.Append([]string{k, strings.Join(versions[k], ", ")})
+				table.Append([]string{k, resolved[k], strings.Join(versions[k], ", ")})
 			} else if (*env)["PrintFormat"] == "text" {
-				fmt.Println(fmt.Sprintf("key> %s\nversions> %s\n-----------", k, strings.Join(versions[k], ", ")))
+				fmt.Println(fmt.Sprintf("key> %s\nresolved> %s\nversions> %s\n-----------", k, resolved[k], strings.Join(versions[k], ", ")))
 			}
 		}
 	}
 	if (*env)["PrintFormat"] == "table" {
 		table.Render()
-	} else if (*env)["PrintFormat"] == "json" {
+	} else if (*env)["PrintFormat"] == "json" || (*env)["PrintFormat"] == "json-resolved" {
 		var str bytes.Buffer
-		mapVersions, _ := json.Marshal(versions)
+		// json keeps the {key: versions} output, json-resolved adds the
+		// resolved versions of constraints
+		var mapVersions []byte
+		if (*env)["PrintFormat"] == "json" {
+			mapVersions, _ = json.Marshal(versions)
+		} else {
+			mapVersions, _ = json.Marshal(map[string]interface{}{"versions": versions, "resolved": resolved})
+		}
 		json.Indent(&str, mapVersions, "", "  ")
 		fmt.Println(str.String())
 	}